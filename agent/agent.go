@@ -20,6 +20,10 @@ type PrepareInput func(args tool.Arguments, sess *Session) ([]model.Message, err
 // returns an error if parsing fails.
 type ResponseParser func(output string) (any, error)
 
+// StreamHandler receives model stream events as they arrive. The context
+// is the agent's own node, allowing callers to tell nested agents apart.
+type StreamHandler func(ctx *tool.Context, event model.StreamEvent)
+
 const (
 	StopOnFirstToolError string = "stop_on_first_error"
 	PassErrorsToModel    string = "pass_errors_to_model"
@@ -36,6 +40,14 @@ type AgentConfig struct {
 	// or an error to stringify and pass to the model for the tool's failure. Note that the ResultInterface
 	// can optionally still contain the error, allowing default parsing to handle it.
 	OnToolErrorFunction func(tool.ResultInterface) (tool.ResultInterface, error) `json:"on_error_function"`
+	// ModelConfig is sent with every model request the agent makes.
+	ModelConfig model.ModelConfig `json:"model_config"`
+	// Budget limits the model usage of the agent and every agent and
+	// tool it calls. ExecutionBudget limits that of the whole execution
+	// the agent runs in. Both are checked before each model call, by the
+	// agent and by those beneath it.
+	Budget          Budget `json:"budget"`
+	ExecutionBudget Budget `json:"execution_budget"`
 }
 
 // IterationChecker returns a function that is called to check if the agent should continue or
@@ -69,6 +81,8 @@ type Agent struct {
 	// tools is the registry of tools the agent can call.
 	tools map[string]tool.Tool
 
+	// prompt, if set, is rendered into the session's system prompt.
+	prompt *systemPrompt
 	// prepareInput converts arguments + session into model messages.
 	prepareInput PrepareInput
 	// parseResponse parses the final assistant text into a typed result.
@@ -79,6 +93,20 @@ type Agent struct {
 	// messages to feed into the next iteration.
 	extractResult ExtractResult
 
+	// streamHandler, if set, switches model calls to streaming and
+	// receives every event as it arrives.
+	streamHandler StreamHandler
+
+	// tokenizer counts tokens for the context window check; nil uses
+	// model.DefaultTokenizer.
+	tokenizer model.Tokenizer
+	// truncate, if set, shortens the conversation when it outgrows the
+	// model's context window.
+	truncate TruncationStrategy
+	// summarization, if set, folds older steps of long sessions into a
+	// rolling summary.
+	summarization *SummarizationConfig
+
 	config AgentConfig
 }
 
@@ -193,11 +221,35 @@ func (a *Agent) Execute(ctx *tool.Context, args tool.Arguments) tool.ResultInter
 	// code smell can be reworked to just write it outright
 	defer ctx.Data().SetData(SessionKey, session)
 
+	// Bound the whole call (model requests and tools alike) by the
+	// configured timeout, on top of whatever deadline the caller set,
+	// leaving the context as it was once the call returns.
+	if a.config.Timeout > 0 {
+		defer ctx.NarrowTimeout(a.config.Timeout)()
+	}
+
+	if err := a.config.ModelConfig.Validate(); err != nil {
+		return tool.NewError(fmt.Errorf("agent %s: %w", a.name, err))
+	}
+	if err := a.storeBudgets(ctx); err != nil {
+		return tool.NewError(fmt.Errorf("agent %s: %w", a.name, err))
+	}
+	if a.prompt != nil {
+		if err := a.prompt.validate(a.parameters); err != nil {
+			return tool.NewError(fmt.Errorf("agent %s: %w", a.name, err))
+		}
+	}
+
 	// 3) Get the iteration checker from config
 	shouldContinue := a.config.IterationChecker()
 
 	// Main iteration loop - continues until checker returns false
 	for {
+		// Stop promptly if the caller cancelled or the deadline passed
+		if err := ctx.Err(); err != nil {
+			return tool.NewError(fmt.Errorf("agent %s stopped: %w", a.name, err))
+		}
+
 		// Check if we should continue before starting this iteration
 		if err := shouldContinue(session); err != nil {
 			return tool.NewError(err)
@@ -206,21 +258,46 @@ func (a *Agent) Execute(ctx *tool.Context, args tool.Arguments) tool.ResultInter
 		//todo - no magic strings, consts for names
 		ctx.Stats().Incr("iterations")
 
-		// 1) Build messages from args + session.
+		// Stop gracefully once a budget is spent
+		if err := CheckBudgets(ctx); err != nil {
+			return a.budgetExceeded(session, err)
+		}
+
+		// Fold older steps into the session's summary if it has grown
+		// too long.
+		if err := a.summarize(ctx, session); err != nil {
+			return tool.NewError(fmt.Errorf("agent %s: %w", a.name, err))
+		}
+
+		// 1) Build messages from args + session, opening with the
+		// system prompt if the agent has one.
+		if err := a.renderPrompt(args, session); err != nil {
+			return tool.NewError(fmt.Errorf("agent %s: %w", a.name, err))
+		}
 		messages, err := a.prepareInput(args, session)
 		if err != nil {
 			return tool.NewError(fmt.Errorf("building messages: %w", err))
 		}
 
-		// 2) Create Step.
-		step := NewStep(messages)
-
-		// 3) Call model.
-		resp, err := a.model.Complete(ctx, model.CompletionRequest{
+		// Make sure the request fits the model's context window before
+		// it is sent, truncating the conversation if configured to.
+		request, err := a.fitContext(model.CompletionRequest{
 			Messages: messages,
 			Tools:    a.toolsSlice(),
-			Config:   model.ModelConfig{},
+			Config:   a.config.ModelConfig,
 		})
+		if err != nil {
+			return tool.NewError(fmt.Errorf("agent %s: %w", a.name, err))
+		}
+
+		// 2) Create Step.
+		step := NewStep(request.Messages)
+
+		// 3) Call model, unless summarizing spent the budget.
+		if err := CheckBudgets(ctx); err != nil {
+			return a.budgetExceeded(session, err)
+		}
+		resp, err := a.complete(ctx, request)
 
 		if err != nil {
 			// Record error in session and return an error result.
@@ -286,6 +363,53 @@ func (a *Agent) Execute(ctx *tool.Context, args tool.Arguments) tool.ResultInter
 	}
 }
 
+// budgetExceeded ends a run whose budget is spent, returning the last
+// answer the model gave, if any, alongside the BudgetExceededError.
+func (a *Agent) budgetExceeded(session *Session, err error) tool.ResultInterface {
+	var partial any
+	steps := session.Steps()
+	for i := len(steps) - 1; i >= 0; i-- {
+		if content := steps[i].GetResponse().Output.Content; content != "" {
+			partial = content
+			break
+		}
+	}
+	return tool.BlankResult(partial, fmt.Errorf("agent %s stopped: %w", a.name, err))
+}
+
+// complete calls the agent's model, streaming the response through the
+// configured StreamHandler when one is set. Either way the fully assembled
+// response is returned so the Session records the same Step. Requests
+// with a ResponseFormat are checked against it and re-prompted on a
+// mismatch.
+func (a *Agent) complete(ctx *tool.Context, request model.CompletionRequest) (model.CompletionResponse, error) {
+	m := a.model
+	if request.Config.ResponseFormat != nil {
+		// Hold the model to the requested format, whether or not it
+		// supports it natively
+		m = model.WithStructuredOutput(model.DefaultStructuredOutputRetries)(m)
+	}
+	var resp model.CompletionResponse
+	var err error
+	if a.streamHandler == nil {
+		resp, err = m.Complete(ctx, request)
+	} else {
+		var events <-chan model.StreamEvent
+		events, err = model.Stream(ctx, m, request)
+		if err != nil {
+			return model.CompletionResponse{}, err
+		}
+		resp, err = model.CollectStream(events, func(event model.StreamEvent) {
+			a.streamHandler(ctx, event)
+		})
+	}
+	if err != nil {
+		return model.CompletionResponse{}, err
+	}
+	model.RecordUsage(ctx, a.model, resp)
+	return resp, nil
+}
+
 // toolsSlice returns the agent's tools in a deterministic (sorted-by-name) slice.
 // TODO - tools should be presented in the order the user specified
 // them, preventing any decision making from being done based on either
@@ -312,9 +436,11 @@ func (a *Agent) toolsSlice() []tool.Tool {
 func ResponseFromModel(resp model.CompletionResponse) Response {
 	return Response{
 		Output: model.Message{
-			Role:    model.RoleAssistant,
-			Content: resp.Text,
+			Role:      model.RoleAssistant,
+			Content:   resp.Text,
+			ToolCalls: resp.ToolCalls,
 		},
 		ToolCalls: resp.ToolCalls,
+		Model:     resp.Model,
 	}
 }
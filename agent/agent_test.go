@@ -1,7 +1,12 @@
 package agent
 
 import (
+	"context"
+	"errors"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hlfshell/gotonomy/model"
 	"github.com/hlfshell/gotonomy/tool"
@@ -270,3 +275,249 @@ func TestResponseFromModel(t *testing.T) {
 		t.Fatalf("unexpected ToolCalls: %#v", r.ToolCalls)
 	}
 }
+
+// TestExecute_WithStreamHandler verifies that a configured StreamHandler
+// receives the model's events while the session still records the final
+// response.
+func TestExecute_WithStreamHandler(t *testing.T) {
+	m := &mockModel{
+		desc: model.ModelDescription{
+			Model:            "test-model",
+			Provider:         "test",
+			MaxContextTokens: 1024,
+		},
+		responses: []model.CompletionResponse{
+			{Text: "streamed"},
+		},
+	}
+
+	var events []model.StreamEvent
+	var nodeID tool.ContextID
+	agent := NewAgent(
+		"stream-agent",
+		"Stream Agent",
+		m,
+		WithStreamHandler(func(ctx *tool.Context, event model.StreamEvent) {
+			nodeID = ctx.ID()
+			events = append(events, event)
+		}),
+	)
+
+	result := agent.Execute(nil, tool.Arguments{
+		"input": "ignored",
+	})
+	if result.Errored() {
+		t.Fatalf("expected non-error result, got: %v", result.GetError())
+	}
+	if result.GetResult() != "streamed" {
+		t.Fatalf("expected result %q, got %v", "streamed", result.GetResult())
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected text and done events, got %d", len(events))
+	}
+	if events[0].Type != model.StreamEventText || events[0].Text != "streamed" {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[1].Type != model.StreamEventDone || events[1].Response == nil {
+		t.Errorf("unexpected final event: %+v", events[1])
+	}
+	if nodeID == "" {
+		t.Errorf("expected handler to receive the agent's context")
+	}
+}
+
+// waitingModel blocks each completion until the call's context is done,
+// mimicking a slow provider that honors cancellation.
+type waitingModel struct {
+	mockModel
+}
+
+func (m *waitingModel) Complete(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error) {
+	m.calls++
+	<-ctx.Done()
+	return model.CompletionResponse{}, ctx.Err()
+}
+
+// TestExecute_TimeoutCancelsModelCall verifies that the configured
+// Timeout is applied as a deadline on the context handed to the model.
+func TestExecute_TimeoutCancelsModelCall(t *testing.T) {
+	m := &waitingModel{}
+	agent := NewAgent("slow-agent", "Slow Agent", m)
+	agent.config.Timeout = 20 * time.Millisecond
+
+	done := make(chan tool.ResultInterface, 1)
+	go func() { done <- agent.Execute(nil, tool.Arguments{"input": "hi"}) }()
+
+	select {
+	case result := <-done:
+		if !result.Errored() {
+			t.Fatal("expected an error result")
+		}
+		if !errors.Is(result.GetError(), context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", result.GetError())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("agent did not stop at its deadline")
+	}
+}
+
+// TestExecute_TimeoutLeavesContext verifies that the configured Timeout
+// bounds only the call, not the context the caller passed in.
+func TestExecute_TimeoutLeavesContext(t *testing.T) {
+	m := &mockModel{responses: []model.CompletionResponse{{Text: "done"}}}
+	agent := NewAgent("timed-agent", "Timed Agent", m)
+	agent.config.Timeout = time.Hour
+
+	ctx := tool.NewContext(context.Background())
+	if result := agent.Execute(ctx, tool.Arguments{"input": "hi"}); result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	if _, ok := ctx.Deadline(); ok || ctx.Err() != nil {
+		t.Errorf("expected the caller's context to be left without a deadline, got err %v", ctx.Err())
+	}
+}
+
+// TestExecute_CancelledContextSkipsModel verifies that an agent run on an
+// already cancelled context returns without calling the model.
+func TestExecute_CancelledContextSkipsModel(t *testing.T) {
+	m := &mockModel{responses: []model.CompletionResponse{{Text: "never"}}}
+	agent := NewAgent("cancelled-agent", "Cancelled Agent", m)
+
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := agent.Execute(tool.NewContext(parent), tool.Arguments{"input": "hi"})
+	if !result.Errored() || !errors.Is(result.GetError(), context.Canceled) {
+		t.Fatalf("expected context.Canceled error, got %v", result.GetError())
+	}
+	if m.calls != 0 {
+		t.Errorf("expected no model calls, got %d", m.calls)
+	}
+}
+
+// TestExecute_WithModelConfig verifies that the agent's model config is
+// sent with its requests, and that an invalid one fails before any call.
+func TestExecute_WithModelConfig(t *testing.T) {
+	m := &mockModel{responses: []model.CompletionResponse{{Text: "ok"}}}
+	config := model.ModelConfig{MaxTokens: 256, Stop: []string{"END"}, ToolChoice: model.ToolChoiceNone}
+	agent := NewAgent("configured-agent", "Configured Agent", m, WithModelConfig(config))
+
+	result := agent.Execute(nil, tool.Arguments{"input": "hi"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	sent := m.requests[0].Config
+	if sent.MaxTokens != 256 || len(sent.Stop) != 1 || sent.ToolChoice != model.ToolChoiceNone {
+		t.Errorf("expected the agent's model config to be sent, got %+v", sent)
+	}
+
+	m = &mockModel{responses: []model.CompletionResponse{{Text: "never"}}}
+	agent = NewAgent("misconfigured-agent", "Misconfigured Agent", m, WithModelConfig(model.ModelConfig{TopP: 2}))
+	result = agent.Execute(nil, tool.Arguments{"input": "hi"})
+	if !result.Errored() || !errors.Is(result.GetError(), model.ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", result.GetError())
+	}
+	if m.calls != 0 {
+		t.Errorf("expected no model calls, got %d", m.calls)
+	}
+}
+
+// TestExecute_ResponseFormatRepromptsOnMismatch verifies that an agent
+// with a ResponseFormat re-prompts a model that strays from it, and
+// returns the bare JSON.
+func TestExecute_ResponseFormatRepromptsOnMismatch(t *testing.T) {
+	type verdict struct {
+		OK bool `json:"ok"`
+	}
+	m := &mockModel{responses: []model.CompletionResponse{
+		{Text: "Looks good to me."},
+		{Text: "```json\n{\"ok\": true}\n```"},
+	}}
+	agent := NewAgent("format-agent", "Format Agent", m, WithModelConfig(model.ModelConfig{
+		ResponseFormat: model.ResponseFormatFor[verdict]("verdict"),
+	}))
+
+	result := agent.Execute(nil, tool.Arguments{"input": "check"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	if result.GetResult() != `{"ok": true}` {
+		t.Errorf("expected the bare JSON result, got %v", result.GetResult())
+	}
+	if m.calls != 2 {
+		t.Errorf("expected one re-prompt, got %d calls", m.calls)
+	}
+}
+
+// TestExecute_ReActModel verifies that an agent can call tools through a
+// model without native tool calling when it is wrapped with WithReAct.
+func TestExecute_ReActModel(t *testing.T) {
+	m := &mockModel{responses: []model.CompletionResponse{
+		{Text: "Thought: I should check.\nAction: lookup\nAction Input: {\"query\": \"answer\"}"},
+		{Text: "Thought: Found it.\nFinal Answer: 42"},
+	}}
+	lookup := tool.NewTool[string]("lookup", "Looks things up", []tool.Parameter{
+		tool.NewParameter[string]("query", "What to look up", true, "", func(v string) (string, error) { return v, nil }),
+	}, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "the answer is 42", nil
+	})
+	agent := NewAgent("react-agent", "ReAct Agent", model.WithReAct()(m), WithTool(lookup))
+
+	result := agent.Execute(nil, tool.Arguments{"input": "what is the answer?"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	if result.GetResult() != "42" {
+		t.Errorf("expected the final answer, got %v", result.GetResult())
+	}
+	last := m.requests[1].Messages
+	if observation := last[len(last)-1]; !strings.Contains(observation.Content, "Observation:") || !strings.Contains(observation.Content, "the answer is 42") {
+		t.Errorf("expected the tool result as an observation, got %+v", observation)
+	}
+}
+
+// TestExecute_RecordsUsage verifies that each model call's tokens and cost
+// are recorded on the agent's context, and roll up through sub-agents.
+func TestExecute_RecordsUsage(t *testing.T) {
+	costs := model.CostsPerToken{Input: 0.001, Output: 0.002}
+	subModel := &mockModel{
+		desc:      model.ModelDescription{Model: "sub-model", Costs: costs},
+		responses: []model.CompletionResponse{{Text: "found it", UsageStats: model.UsageStats{InputTokens: 10, OutputTokens: 5}}},
+	}
+	sub := NewAgent("researcher", "Researcher", subModel)
+
+	m := &mockModel{
+		desc: model.ModelDescription{Model: "main-model", Costs: costs},
+		responses: []model.CompletionResponse{
+			{
+				ToolCalls:  []model.ToolCall{{ID: "1", Name: "researcher", Arguments: tool.Arguments{"input": "look"}}},
+				UsageStats: model.UsageStats{InputTokens: 100, OutputTokens: 20},
+			},
+			{Text: "done", UsageStats: model.UsageStats{InputTokens: 150, OutputTokens: 10}},
+		},
+	}
+	agent := NewAgent("lead", "Lead", m, WithTool(sub))
+
+	ctx := tool.NewContext(context.Background())
+	result := agent.Execute(ctx, tool.Arguments{"input": "research"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+
+	own := ctx.Stats().Usage()["main-model"]
+	if own.Calls != 2 || own.InputTokens != 250 || own.OutputTokens != 30 {
+		t.Errorf("unexpected usage on the agent's context: %+v", own)
+	}
+
+	report := ctx.UsageReport()
+	if report.Total.Calls != 3 || report.Total.InputTokens != 260 {
+		t.Errorf("unexpected total %+v", report.Total)
+	}
+	if got := report.ByTool["researcher"]; got.Calls != 1 || got.OutputTokens != 5 {
+		t.Errorf("expected the sub-agent's usage by tool, got %+v", got)
+	}
+	if want := costs.Cost(260, 35); math.Abs(report.Total.Cost-want) > 1e-9 {
+		t.Errorf("expected cost %v, got %v", want, report.Total.Cost)
+	}
+}
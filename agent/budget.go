@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hlfshell/gotonomy/data/ledger"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// BudgetKey and ExecutionBudgetKey are the keys in the agent's scoped
+// ledger that its AgentConfig.Budget and ExecutionBudget are stored
+// under, where agents beneath it find them.
+const (
+	BudgetKey          = "budget"
+	ExecutionBudgetKey = "execution_budget"
+)
+
+// ErrBudgetExceeded is matched by every BudgetExceededError.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Budget limits the tokens and cost of model calls. Zero fields are
+// unlimited.
+type Budget struct {
+	MaxInputTokens  int64 `json:"max_input_tokens,omitempty"`
+	MaxOutputTokens int64 `json:"max_output_tokens,omitempty"`
+	// MaxTokens limits input and output tokens combined.
+	MaxTokens int64 `json:"max_tokens,omitempty"`
+	// MaxCost limits the cost, in the units of model.CostsPerToken.
+	MaxCost float64 `json:"max_cost,omitempty"`
+}
+
+// IsZero reports whether the budget sets no limits.
+func (b Budget) IsZero() bool {
+	return b == Budget{}
+}
+
+// Check returns a BudgetExceededError naming the first limit usage has
+// reached, or nil if there is room for another model call.
+func (b Budget) Check(usage tool.Usage) error {
+	if err := b.exceeded(usage); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (b Budget) exceeded(usage tool.Usage) *BudgetExceededError {
+	exceeded := func(limit string, used, max float64) *BudgetExceededError {
+		return &BudgetExceededError{Limit: limit, Used: used, Max: max, Usage: usage}
+	}
+	switch {
+	case b.MaxInputTokens > 0 && usage.InputTokens >= b.MaxInputTokens:
+		return exceeded("input tokens", float64(usage.InputTokens), float64(b.MaxInputTokens))
+	case b.MaxOutputTokens > 0 && usage.OutputTokens >= b.MaxOutputTokens:
+		return exceeded("output tokens", float64(usage.OutputTokens), float64(b.MaxOutputTokens))
+	case b.MaxTokens > 0 && usage.TotalTokens() >= b.MaxTokens:
+		return exceeded("tokens", float64(usage.TotalTokens()), float64(b.MaxTokens))
+	case b.MaxCost > 0 && usage.Cost >= b.MaxCost:
+		return exceeded("cost", usage.Cost, b.MaxCost)
+	}
+	return nil
+}
+
+// BudgetExceededError reports that a Budget's limit has been reached.
+type BudgetExceededError struct {
+	// Scope names whose budget it is: an agent, or the execution.
+	Scope string
+	// Limit names the limit reached: "input tokens", "output tokens",
+	// "tokens" or "cost".
+	Limit string
+	Used  float64
+	Max   float64
+	// Usage is everything counted against the budget.
+	Usage tool.Usage
+}
+
+func (e *BudgetExceededError) Error() string {
+	scope := ""
+	if e.Scope != "" {
+		scope = e.Scope + " "
+	}
+	return fmt.Sprintf("%s%s: used %g of %g %s", scope, ErrBudgetExceeded, e.Used, e.Max, e.Limit)
+}
+
+// Is matches ErrBudgetExceeded.
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// storeBudgets records the agent's budgets on its context so that agents
+// beneath it are held to them too.
+func (a *Agent) storeBudgets(ctx *tool.Context) error {
+	if !a.config.Budget.IsZero() {
+		if err := ctx.Data().SetData(BudgetKey, a.config.Budget); err != nil {
+			return fmt.Errorf("storing budget: %w", err)
+		}
+	}
+	if !a.config.ExecutionBudget.IsZero() {
+		if err := ctx.Data().SetData(ExecutionBudgetKey, a.config.ExecutionBudget); err != nil {
+			return fmt.Errorf("storing budget: %w", err)
+		}
+	}
+	return nil
+}
+
+// CheckBudgets returns a BudgetExceededError if the tool running on ctx,
+// or any agent above it, has reached its budget. Each agent's Budget is
+// checked against the usage of its own subtree, and each ExecutionBudget
+// against that of the whole execution. Agents check before each model
+// call; anything else calling a model beneath an agent, such as a
+// planner, should too.
+func CheckBudgets(ctx *tool.Context) error {
+	var root *tool.Context
+	var executionBudgets []Budget
+	for node := ctx; node != nil; node = node.Parent() {
+		root = node
+		if node.Data() == nil {
+			// A blank context, which holds no budgets
+			continue
+		}
+		if budget, err := ledger.GetDataScoped[Budget](node.Data(), BudgetKey); err == nil {
+			report := node.UsageReport()
+			if err := budget.exceeded(report.Total); err != nil {
+				err.Scope = fmt.Sprintf("agent %s", report.Tool)
+				return err
+			}
+		}
+		if budget, err := ledger.GetDataScoped[Budget](node.Data(), ExecutionBudgetKey); err == nil {
+			executionBudgets = append(executionBudgets, budget)
+		}
+	}
+	if len(executionBudgets) == 0 {
+		return nil
+	}
+
+	usage := root.UsageReport().Total
+	for _, budget := range executionBudgets {
+		if err := budget.exceeded(usage); err != nil {
+			err.Scope = "execution"
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func echoTool() tool.Tool {
+	return tool.NewTool[string]("echo", "Echoes its input", []tool.Parameter{
+		tool.NewParameter[string]("text", "Text to echo", true, "", func(v string) (string, error) { return v, nil }),
+	}, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return args["text"].(string), nil
+	})
+}
+
+func TestBudget_Check(t *testing.T) {
+	usage := tool.Usage{Calls: 2, InputTokens: 80, OutputTokens: 30, Cost: 0.5}
+	tests := []struct {
+		name   string
+		budget Budget
+		limit  string
+	}{
+		{"unlimited", Budget{}, ""},
+		{"within", Budget{MaxInputTokens: 100, MaxOutputTokens: 50, MaxTokens: 200, MaxCost: 1}, ""},
+		{"input tokens", Budget{MaxInputTokens: 80}, "input tokens"},
+		{"output tokens", Budget{MaxOutputTokens: 20}, "output tokens"},
+		{"tokens", Budget{MaxTokens: 100}, "tokens"},
+		{"cost", Budget{MaxCost: 0.25}, "cost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.budget.Check(usage)
+			if tt.limit == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var exceeded *BudgetExceededError
+			if !errors.As(err, &exceeded) || !errors.Is(err, ErrBudgetExceeded) {
+				t.Fatalf("expected a BudgetExceededError, got %v", err)
+			}
+			if exceeded.Limit != tt.limit {
+				t.Errorf("expected the %s limit, got %s", tt.limit, exceeded.Limit)
+			}
+		})
+	}
+}
+
+// TestExecute_BudgetStopsWithPartialResult verifies that an agent stops
+// before the model call that would exceed its budget, returning its last
+// answer with the budget error.
+func TestExecute_BudgetStopsWithPartialResult(t *testing.T) {
+	m := &mockModel{
+		desc: model.ModelDescription{Model: "m"},
+		responses: []model.CompletionResponse{{
+			Text:       "Let me check that.",
+			ToolCalls:  []model.ToolCall{{ID: "1", Name: "echo", Arguments: tool.Arguments{"text": "hi"}}},
+			UsageStats: model.UsageStats{InputTokens: 90, OutputTokens: 20},
+		}},
+	}
+	agent := NewAgent("frugal", "Frugal", m, WithTool(echoTool()), WithBudget(Budget{MaxTokens: 100}))
+
+	result := agent.Execute(nil, tool.Arguments{"input": "go"})
+	var exceeded *BudgetExceededError
+	if !errors.As(result.GetError(), &exceeded) {
+		t.Fatalf("expected a BudgetExceededError, got %v", result.GetError())
+	}
+	if exceeded.Scope != "agent frugal" || exceeded.Used != 110 {
+		t.Errorf("unexpected error %+v", exceeded)
+	}
+	if result.GetResult() != "Let me check that." {
+		t.Errorf("expected the last answer as the partial result, got %v", result.GetResult())
+	}
+	if m.calls != 1 {
+		t.Errorf("expected no model call past the budget, got %d calls", m.calls)
+	}
+}
+
+// TestExecute_BudgetInheritedByChildAgents verifies that an agent's
+// budget covers the agents it calls, which stop once it is spent.
+func TestExecute_BudgetInheritedByChildAgents(t *testing.T) {
+	subModel := &mockModel{
+		desc: model.ModelDescription{Model: "sub"},
+		responses: []model.CompletionResponse{{
+			Text:       "Working on it.",
+			ToolCalls:  []model.ToolCall{{ID: "1", Name: "echo", Arguments: tool.Arguments{"text": "hi"}}},
+			UsageStats: model.UsageStats{InputTokens: 40, OutputTokens: 20},
+		}},
+	}
+	sub := NewAgent("helper", "Helper", subModel, WithTool(echoTool()))
+
+	m := &mockModel{
+		desc: model.ModelDescription{Model: "lead"},
+		responses: []model.CompletionResponse{{
+			ToolCalls:  []model.ToolCall{{ID: "1", Name: "helper", Arguments: tool.Arguments{"input": "help"}}},
+			UsageStats: model.UsageStats{InputTokens: 10, OutputTokens: 5},
+		}},
+	}
+	agent := NewAgent("lead", "Lead", m, WithTool(sub), WithBudget(Budget{MaxTokens: 50}))
+
+	ctx := tool.NewContext(context.Background())
+	result := agent.Execute(ctx, tool.Arguments{"input": "go"})
+	if !errors.Is(result.GetError(), ErrBudgetExceeded) {
+		t.Fatalf("expected the budget to stop the agent, got %v", result.GetError())
+	}
+	if subModel.calls != 1 {
+		t.Errorf("expected the child agent to stop on its parent's budget, got %d calls", subModel.calls)
+	}
+	if m.calls != 1 {
+		t.Errorf("expected the agent to stop once its budget was spent, got %d calls", m.calls)
+	}
+	if got := ctx.UsageReport().Total.TotalTokens(); got != 75 {
+		t.Errorf("expected 75 tokens used, got %d", got)
+	}
+}
+
+// TestExecute_ExecutionBudget verifies that an execution budget counts
+// the usage of the whole execution, not just the agent's.
+func TestExecute_ExecutionBudget(t *testing.T) {
+	ctx := tool.NewContext(context.Background())
+	spender := tool.NewTool[string]("spender", "Spends", nil, func(c *tool.Context, args tool.Arguments) (string, error) {
+		c.Stats().RecordUsage("elsewhere", tool.Usage{Calls: 1, Cost: 2})
+		agent := NewAgent("inner", "Inner", &mockModel{responses: []model.CompletionResponse{{Text: "hi"}}},
+			WithExecutionBudget(Budget{MaxCost: 1}))
+		return "", agent.Execute(c, tool.Arguments{"input": "go"}).GetError()
+	})
+
+	result := spender.Execute(ctx, tool.Arguments{})
+	var exceeded *BudgetExceededError
+	if !errors.As(result.GetError(), &exceeded) || exceeded.Scope != "execution" {
+		t.Fatalf("expected the execution budget to be exceeded, got %v", result.GetError())
+	}
+}
@@ -7,23 +7,17 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/hlfshell/gogentic/agent"
+	"github.com/hlfshell/gotonomy/agent"
 )
 
 func main() {
 	var (
-		typeName    = flag.String("type", "", "Type name to generate tool wrapper for")
+		typeName    = flag.String("type", "", "Type name to generate tool wrapper for (defaults to every type annotated with "+agent.ToolDirective+")")
 		packageName = flag.String("package", "", "Package name (defaults to current package)")
-		outputFile  = flag.String("output", "", "Output file (defaults to <type>_tool_gen.go)")
+		outputFile  = flag.String("output", "", "Output file (defaults to <type>_tool_gen.go, or <file>_tool_gen.go without -type)")
 	)
 	flag.Parse()
 
-	if *typeName == "" {
-		fmt.Fprintln(os.Stderr, "Error: -type flag is required")
-		flag.Usage()
-		os.Exit(1)
-	}
-
 	// Get the file path from environment variable or current directory
 	goFile := os.Getenv("GOFILE")
 	if goFile == "" {
@@ -51,10 +45,10 @@ func main() {
 	outFile := *outputFile
 	if outFile == "" {
 		base := strings.TrimSuffix(filepath.Base(goFile), ".go")
-		outFile = fmt.Sprintf("%s_tool_gen.go", strings.ToLower(*typeName))
-		if base != "" {
-			outFile = filepath.Join(filepath.Dir(goFile), outFile)
+		if *typeName != "" {
+			base = strings.ToLower(*typeName)
 		}
+		outFile = filepath.Join(filepath.Dir(goFile), base+"_tool_gen.go")
 	}
 
 	// Write the generated code
@@ -65,4 +59,3 @@ func main() {
 
 	fmt.Printf("Generated tool wrapper: %s\n", outFile)
 }
-
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/hlfshell/gotonomy/model"
+)
+
+// TruncatedToolOutput replaces tool outputs dropped by
+// DropOldestToolOutputs.
+const TruncatedToolOutput = "[tool output truncated to fit the context window]"
+
+// TruncationStrategy shortens messages that do not fit in the model's
+// context window, returning messages that use at most limit tokens as
+// counted by tokenizer where it can. It must not modify messages in
+// place. The agent checks the result again and fails with
+// model.ErrContextTooLong if it still does not fit.
+type TruncationStrategy func(messages []model.Message, limit int, tokenizer model.Tokenizer) []model.Message
+
+// DropOldestToolOutputs returns a TruncationStrategy that replaces the
+// content of tool outputs, oldest first, with TruncatedToolOutput until
+// the messages fit. The messages themselves are kept, so every tool call
+// still has its reply.
+func DropOldestToolOutputs() TruncationStrategy {
+	return func(messages []model.Message, limit int, tokenizer model.Tokenizer) []model.Message {
+		messages = slices.Clone(messages)
+		for i, msg := range messages {
+			if model.CountMessageTokens(tokenizer, messages) <= limit {
+				break
+			}
+			if !isToolOutput(msg) || msg.Content == TruncatedToolOutput {
+				continue
+			}
+			messages[i] = model.Message{Role: msg.Role, Content: TruncatedToolOutput, ToolCallID: msg.ToolCallID}
+		}
+		return messages
+	}
+}
+
+// KeepSystemAndLastN returns a TruncationStrategy that keeps the leading
+// system messages (the agent's instructions), the first user message (the
+// task) and the last n turns, dropping everything in between. A turn is a
+// user message, or an assistant message along with the tool outputs that
+// answer it, so a turn with many tool calls still counts once.
+func KeepSystemAndLastN(n int) TruncationStrategy {
+	return func(messages []model.Message, limit int, tokenizer model.Tokenizer) []model.Message {
+		system := 0
+		for system < len(messages) && messages[system].Role == model.RoleSystem && !isToolOutput(messages[system]) {
+			system++
+		}
+		kept := slices.Clone(messages[:system])
+
+		rest := messages[system:]
+		if task := slices.IndexFunc(rest, func(msg model.Message) bool { return msg.Role == model.RoleUser }); task >= 0 {
+			kept = append(kept, rest[task])
+			rest = rest[task+1:]
+		}
+
+		// Tool outputs belong to the turn before them
+		var turns []int
+		for i, msg := range rest {
+			if !isToolOutput(msg) {
+				turns = append(turns, i)
+			}
+		}
+		if n <= 0 || len(turns) == 0 {
+			return kept
+		}
+		start := turns[max(len(turns)-n, 0)]
+		return append(kept, rest[start:]...)
+	}
+}
+
+// ChainTruncation returns a TruncationStrategy that applies strategies in
+// order, stopping as soon as the messages fit; e.g. dropping old tool
+// outputs first and only then whole turns.
+func ChainTruncation(strategies ...TruncationStrategy) TruncationStrategy {
+	return func(messages []model.Message, limit int, tokenizer model.Tokenizer) []model.Message {
+		for _, strategy := range strategies {
+			if model.CountMessageTokens(tokenizer, messages) <= limit {
+				break
+			}
+			messages = strategy(messages, limit, tokenizer)
+		}
+		return messages
+	}
+}
+
+// isToolOutput reports whether msg carries the output of a tool call.
+func isToolOutput(msg model.Message) bool {
+	return msg.Role == model.RoleTool || msg.ToolCallID != ""
+}
+
+// fitContext checks that request fits in the model's context window
+// before it is sent, applying the agent's TruncationStrategy if it does
+// not. It fails with model.ErrContextTooLong if the request still does
+// not fit.
+func (a *Agent) fitContext(request model.CompletionRequest) (model.CompletionRequest, error) {
+	desc := a.model.Description()
+	tokenizer := a.tokenizer
+	if tokenizer == nil {
+		tokenizer = model.DefaultTokenizer
+	}
+
+	err := model.CheckContext(tokenizer, desc, request)
+	if err == nil || a.truncate == nil || !errors.Is(err, model.ErrContextTooLong) {
+		return request, err
+	}
+
+	// Leave room for everything besides the messages: tool definitions
+	// and the reserved output
+	overhead := model.CountRequestTokens(tokenizer, model.CompletionRequest{Tools: request.Tools})
+	limit := desc.MaxContextTokens - overhead - request.Config.MaxTokens
+	request.Messages = a.truncate(request.Messages, limit, tokenizer)
+	return request, model.CheckContext(tokenizer, desc, request)
+}
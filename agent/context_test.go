@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func longConversation() []model.Message {
+	output := strings.Repeat("data ", 100)
+	return []model.Message{
+		{Role: model.RoleSystem, Content: "You are a researcher."},
+		{Role: model.RoleUser, Content: "Look it up."},
+		{Role: model.RoleAssistant, Content: ""},
+		{Role: model.RoleSystem, Content: output, ToolCallID: "call-1"},
+		{Role: model.RoleAssistant, Content: ""},
+		{Role: model.RoleSystem, Content: output, ToolCallID: "call-2"},
+		{Role: model.RoleUser, Content: "Summarize."},
+	}
+}
+
+func TestDropOldestToolOutputs(t *testing.T) {
+	messages := longConversation()
+	// Room for one tool output, but not both
+	limit := model.CountMessageTokens(model.DefaultTokenizer, messages) - 50
+
+	truncated := DropOldestToolOutputs()(messages, limit, model.DefaultTokenizer)
+	if len(truncated) != len(messages) {
+		t.Fatalf("expected every message to be kept, got %d", len(truncated))
+	}
+	if truncated[3].Content != TruncatedToolOutput || truncated[3].ToolCallID != "call-1" {
+		t.Errorf("expected the oldest output to be dropped, got %+v", truncated[3])
+	}
+	if truncated[5].Content == TruncatedToolOutput {
+		t.Errorf("expected the newest output to be kept")
+	}
+	if messages[3].Content == TruncatedToolOutput {
+		t.Errorf("expected the input to be left unmodified")
+	}
+}
+
+func TestKeepSystemAndLastN(t *testing.T) {
+	messages := longConversation()
+
+	kept := KeepSystemAndLastN(2)(messages, 0, model.DefaultTokenizer)
+	if len(kept) != 5 || kept[0].Content != "You are a researcher." || kept[1].Content != "Look it up." ||
+		kept[2].Role != model.RoleAssistant || kept[3].ToolCallID != "call-2" || kept[4].Content != "Summarize." {
+		t.Errorf("unexpected messages: %+v", kept)
+	}
+
+	// The task is kept even when it is not among the last turns
+	kept = KeepSystemAndLastN(1)(messages, 0, model.DefaultTokenizer)
+	if len(kept) != 3 || kept[1].Content != "Look it up." || kept[2].Content != "Summarize." {
+		t.Errorf("expected the system prompt, task and last turn, got %+v", kept)
+	}
+
+	if kept := KeepSystemAndLastN(5)(messages, 0, model.DefaultTokenizer); len(kept) != len(messages) {
+		t.Errorf("expected every message to be kept, got %+v", kept)
+	}
+}
+
+func TestKeepSystemAndLastN_CountsTurns(t *testing.T) {
+	messages := []model.Message{
+		{Role: model.RoleSystem, Content: "You are a researcher."},
+		{Role: model.RoleUser, Content: "Look it up."},
+		{Role: model.RoleAssistant, Content: "First look."},
+		{Role: model.RoleTool, Content: "old", ToolCallID: "call-1"},
+		{Role: model.RoleAssistant, Content: ""},
+		{Role: model.RoleTool, Content: "a", ToolCallID: "call-2"},
+		{Role: model.RoleTool, Content: "b", ToolCallID: "call-3"},
+		{Role: model.RoleTool, Content: "c", ToolCallID: "call-4"},
+	}
+
+	// A turn with many tool calls counts once, keeping every output
+	kept := KeepSystemAndLastN(1)(messages, 0, model.DefaultTokenizer)
+	if len(kept) != 6 || kept[2].Role != model.RoleAssistant || kept[2].Content != "" || kept[5].ToolCallID != "call-4" {
+		t.Errorf("expected the whole last turn, got %+v", kept)
+	}
+}
+
+func TestChainTruncation(t *testing.T) {
+	messages := longConversation()
+	strategy := ChainTruncation(DropOldestToolOutputs(), KeepSystemAndLastN(1))
+
+	// Dropping tool outputs is enough
+	limit := model.CountMessageTokens(model.DefaultTokenizer, messages) - 50
+	if got := strategy(messages, limit, model.DefaultTokenizer); len(got) != len(messages) {
+		t.Errorf("expected the second strategy to be skipped, got %+v", got)
+	}
+	// It is not; fall back to dropping turns
+	if got := strategy(messages, 20, model.DefaultTokenizer); len(got) != 3 {
+		t.Errorf("expected only the system prompt, task and last turn, got %+v", got)
+	}
+}
+
+func TestExecute_ContextWindow(t *testing.T) {
+	newModel := func() *mockModel {
+		return &mockModel{
+			desc: model.ModelDescription{Model: "small", Provider: "test", MaxContextTokens: 150},
+			responses: []model.CompletionResponse{
+				{ToolCalls: []model.ToolCall{{ID: "call-1", Name: "fetch"}}},
+				{Text: "done"},
+			},
+		}
+	}
+	fetch := tool.NewTool[string]("fetch", "Fetches a page", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return strings.Repeat("data ", 200), nil
+	})
+
+	// Without truncation the second request is rejected before it is sent
+	m := newModel()
+	result := NewAgent("reader", "Reads", m, WithTool(fetch)).Execute(nil, tool.Arguments{"input": "read it"})
+	if !result.Errored() || !errors.Is(result.GetError(), model.ErrContextTooLong) {
+		t.Fatalf("expected ErrContextTooLong, got %v", result.GetError())
+	}
+	if m.calls != 1 {
+		t.Errorf("expected the long request not to be sent, got %d calls", m.calls)
+	}
+
+	m = newModel()
+	result = NewAgent("reader", "Reads", m, WithTool(fetch), WithTruncation(DropOldestToolOutputs())).
+		Execute(nil, tool.Arguments{"input": "read it"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	sent := m.requests[1].Messages
+	if last := sent[len(sent)-1]; last.Content != TruncatedToolOutput {
+		t.Errorf("expected the tool output to be truncated, got %+v", last)
+	}
+}
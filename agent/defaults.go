@@ -31,12 +31,26 @@ func DefaultArgumentsToPrompt(args tool.Arguments) (map[string]string, error) {
 }
 
 // DefaultArgumentsToMessages builds a simple single-turn conversation:
-//   - If the session has prior steps, it replays the full conversation history.
+//   - If the session has prior steps, it replays the conversation history,
+//     with any steps folded into the session's Summary replaced by it.
 //   - For the first iteration, it converts args into a single user message whose
 //     content is the JSON-encoded "input" field from DefaultArgumentsToPrompt.
+//
+// Either way, the session's Prompt (see WithPrompt), if any, opens the
+// conversation as a system message, so a prompt rendered anew replaces
+// the one already sent.
 func DefaultArgumentsToMessages(args tool.Arguments, sess *Session) ([]model.Message, error) {
+	var prompt string
+	if sess != nil {
+		prompt = sess.Prompt()
+	}
+
 	if sess != nil && len(sess.Steps()) > 0 {
-		return sess.Conversation(), nil
+		msgs := sess.Summarized()
+		if prompt != "" && len(msgs) > 0 && msgs[0].Role == model.RoleSystem {
+			msgs[0] = model.Message{Role: model.RoleSystem, Content: prompt}
+		}
+		return msgs, nil
 	}
 
 	// No prior steps - start a new conversation from arguments.
@@ -49,12 +63,14 @@ func DefaultArgumentsToMessages(args tool.Arguments, sess *Session) ([]model.Mes
 		return nil, fmt.Errorf("default prompt missing input field")
 	}
 
-	return []model.Message{
-		{
-			Role:    model.RoleUser,
-			Content: input,
-		},
-	}, nil
+	var msgs []model.Message
+	if prompt != "" {
+		msgs = append(msgs, model.Message{Role: model.RoleSystem, Content: prompt})
+	}
+	return append(msgs, model.Message{
+		Role:    model.RoleUser,
+		Content: input,
+	}), nil
 }
 
 // DefaultResponseParser returns the raw text output unchanged.
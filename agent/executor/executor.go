@@ -67,6 +67,9 @@ type ExecutionReport struct {
 	Steps     []StepExecution `json:"steps"`
 	Replans   []plan.PlanDiff `json:"replans,omitempty"`
 	FinalPlan *plan.Plan      `json:"final_plan,omitempty"`
+	// Usage breaks down the model usage under the context the plan was
+	// executed in; it is nil when executed without one.
+	Usage *tool.UsageReport `json:"usage,omitempty"`
 }
 
 func (r *ExecutionReport) Duration() time.Duration {
@@ -125,6 +128,9 @@ func (e *Executor) Execute(ctx *tool.Context, p *plan.Plan, objective string) (*
 	report.FinalPlan = p
 	report.PlanID = p.ID
 	report.EndedAt = time.Now()
+	if ctx != nil {
+		report.Usage = ctx.UsageReport()
+	}
 	if err != nil {
 		return report, err
 	}
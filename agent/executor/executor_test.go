@@ -7,6 +7,7 @@ import (
 	"github.com/hlfshell/gotonomy/agent/judging"
 	"github.com/hlfshell/gotonomy/agent/planning"
 	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/model/modeltest"
 	"github.com/hlfshell/gotonomy/plan"
 	"github.com/hlfshell/gotonomy/tool"
 )
@@ -146,17 +147,9 @@ func TestExecutor_Replan(t *testing.T) {
 	)
 
 	// Planner that always returns a plan with expectation "ok".
-	call := 0
-	pm := &plannerMockModel{
-		complete: func(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error) {
-			_ = ctx
-			_ = req
-			call++
-			return model.CompletionResponse{
-				Text: `{"steps":[{"id":"s1","name":"S1","instruction":"do s1","expectation":"ok","dependencies":[]}]}`,
-			}, nil
-		},
-	}
+	pm := modeltest.NewScripted().When(modeltest.Any(), model.CompletionResponse{
+		Text: `{"steps":[{"id":"s1","name":"S1","instruction":"do s1","expectation":"ok","dependencies":[]}]}`,
+	})
 	planner, err := planning.NewPlannerAgent("planner", "planner", "planner", planning.Config{Model: pm, Temperature: 0.0})
 	if err != nil {
 		t.Fatalf("failed to create planner: %v", err)
@@ -179,7 +172,7 @@ func TestExecutor_Replan(t *testing.T) {
 	if len(report.Replans) != 1 {
 		t.Fatalf("expected 1 replan, got %d", len(report.Replans))
 	}
-	if call == 0 {
+	if pm.Calls() == 0 {
 		t.Fatalf("expected planner to be called")
 	}
 	if report.Steps[len(report.Steps)-1].FinalVerdict != judging.VerdictPass {
@@ -187,22 +180,57 @@ func TestExecutor_Replan(t *testing.T) {
 	}
 }
 
-type plannerMockModel struct {
-	complete func(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error)
-}
+func TestExecutor_ReportsUsage(t *testing.T) {
+	p := plan.NewPlan("p1")
+	s1 := plan.NewStep("s1", "S1", "do s1", "ok", nil, nil)
+	s2 := plan.NewStep("s2", "S2", "do s2", "ok", []*plan.Step{&s1}, nil)
+	p.AddStep(s1)
+	p.AddStep(s2)
+
+	runner := tool.NewTool[string](
+		"runner",
+		"fake runner",
+		executorRunnerParams(),
+		func(ctx *tool.Context, args tool.Arguments) (string, error) {
+			ctx.Stats().RecordUsage("worker-model", tool.Usage{Calls: 1, InputTokens: 10, OutputTokens: 2, Cost: 0.25})
+			return "ok", nil
+		},
+	)
+	judge := tool.NewTool[judging.JudgeResult](
+		"judge",
+		"fake judge",
+		executorJudgeParams(),
+		func(ctx *tool.Context, args tool.Arguments) (judging.JudgeResult, error) {
+			ctx.Stats().RecordUsage("judge-model", tool.Usage{Calls: 1, InputTokens: 5, OutputTokens: 1, Cost: 0.5})
+			return judging.JudgeResult{Verdict: judging.VerdictPass, Justification: "ok"}, nil
+		},
+	)
 
-func (m *plannerMockModel) Description() model.ModelDescription {
-	return model.ModelDescription{
-		Model:            "mock",
-		Provider:         "mock",
-		MaxContextTokens: 8192,
-		Description:      "mock",
-		CanUseTools:      false,
+	exec := &Executor{
+		Config:     ExecutorConfig{MaxAttemptsPerStep: 1},
+		StepRunner: runner,
+		Judge:      judge,
 	}
-}
 
-func (m *plannerMockModel) Complete(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error) {
-	return m.complete(ctx, req)
+	_, ctx := tool.NewExecution(tool.NewTool[string]("session", "", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	}), tool.Arguments{})
+	report, err := exec.Execute(ctx, p, "objective")
+	if err != nil {
+		t.Fatalf("expected success, got err: %v", err)
+	}
+	if report.Usage == nil {
+		t.Fatal("expected a usage report")
+	}
+	if got := report.Usage.ByTool["runner"]; got.Calls != 2 || got.InputTokens != 20 {
+		t.Errorf("unexpected runner usage %+v", got)
+	}
+	if got := report.Usage.ByModel["judge-model"]; got.Calls != 2 || got.Cost != 1 {
+		t.Errorf("unexpected judge usage %+v", got)
+	}
+	if report.Usage.Total.Cost != 1.5 {
+		t.Errorf("expected total cost 1.5, got %v", report.Usage.Total.Cost)
+	}
 }
 
 func executorRunnerParams() []tool.Parameter {
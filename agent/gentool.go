@@ -0,0 +1,486 @@
+package agent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/hlfshell/gotonomy/utils/semver"
+)
+
+const (
+	// ToolDirective annotates a struct type for GenerateToolWrapper, with
+	// optional space separated settings:
+	//
+	//	// GetWeather fetches the current weather for a city.
+	//	//
+	//	//gotonomy:tool name=get_weather id=hlfshell/get_weather method=Run version=1.2.0
+	//	type GetWeather struct {
+	//		// City is the city to get the weather for.
+	//		City string `json:"city" minLength:"2"`
+	//		Unit string `json:"unit,omitempty" enum:"celsius,fahrenheit" default:"celsius"`
+	//	}
+	//
+	//	func (g GetWeather) Run(ctx *tool.Context) (Forecast, error)
+	//
+	// name defaults to the type's name in snake_case, id to the name, and
+	// method to Run. version is reported when none was set at build time.
+	ToolDirective = "//gotonomy:tool"
+
+	toolPackage   = "github.com/hlfshell/gotonomy/tool"
+	semverPackage = "github.com/hlfshell/gotonomy/utils/semver"
+)
+
+// ErrGenerateTool is returned when GenerateToolWrapper cannot generate a
+// tool for a type.
+var ErrGenerateTool = errors.New("cannot generate tool")
+
+// GenerateToolWrapper generates the source of a tool.Tool implementation,
+// in package pkg, for the struct type typeName declared in goFile; or,
+// if typeName is empty, for every struct type there annotated with
+// ToolDirective. It is the engine of the gentool command, run by
+// go:generate.
+//
+// The tool's description is the type's doc comment, and each exported
+// field is a parameter derived from its tags by tool.FieldParameter, as
+// tool.NewToolFromFunc derives them, and described by its doc comment if
+// it has no description tag. The tool validates the arguments it is
+// called with against its parameters, sets the fields from them, and
+// returns what the type's method returns. The method may take a
+// *tool.Context and must return a result and an error.
+func GenerateToolWrapper(typeName, pkg, goFile string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, goFile, nil, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", goFile, err)
+	}
+
+	g := &toolGenerator{
+		fset:    fset,
+		file:    file,
+		imports: fileImports(file),
+		used:    map[string]string{"tool": toolPackage, "semver": semverPackage},
+	}
+	var wrappers []toolWrapper
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.TypeSpec)
+			doc := spec.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			directive, annotated := toolDirective(doc)
+			if typeName != "" && spec.Name.Name != typeName || typeName == "" && !annotated {
+				continue
+			}
+			wrapper, err := g.wrapper(spec, doc, directive)
+			if err != nil {
+				return "", fmt.Errorf("%w %s: %v", ErrGenerateTool, spec.Name.Name, err)
+			}
+			wrappers = append(wrappers, wrapper)
+		}
+	}
+	if len(wrappers) == 0 {
+		if typeName != "" {
+			return "", fmt.Errorf("%w %s: no such type in %s", ErrGenerateTool, typeName, goFile)
+		}
+		return "", fmt.Errorf("%w: no types annotated with %s in %s", ErrGenerateTool, ToolDirective, goFile)
+	}
+
+	// Standard library imports come first, as goimports groups them
+	var std, imports []string
+	for name, importPath := range g.used {
+		spec := strconv.Quote(importPath)
+		if path.Base(importPath) != name {
+			spec = name + " " + spec
+		}
+		if first, _, _ := strings.Cut(importPath, "/"); strings.Contains(first, ".") {
+			imports = append(imports, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	slices.Sort(std)
+	slices.Sort(imports)
+	if len(std) > 0 {
+		imports = append(append(std, ""), imports...)
+	}
+
+	var buf bytes.Buffer
+	err = toolTemplate.Execute(&buf, map[string]any{
+		"File":     filepath.Base(goFile),
+		"Package":  pkg,
+		"Imports":  imports,
+		"Wrappers": wrappers,
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrGenerateTool, err)
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("%w: formatting generated code: %v", ErrGenerateTool, err)
+	}
+	return string(code), nil
+}
+
+// toolWrapper is what the template needs to generate a tool for a type.
+type toolWrapper struct {
+	Type        string
+	Wrapper     string
+	Name        string
+	ID          string
+	Description string
+	Method      string
+	// Context is whether the method takes a *tool.Context
+	Context bool
+	// Version is the version reported when none was set at build time
+	Version    semver.SemVer
+	Parameters []toolParameter
+}
+
+type toolParameter struct {
+	Field string
+	// Description is the field's doc comment, if it has no description tag
+	Description string
+}
+
+type toolGenerator struct {
+	fset *token.FileSet
+	file *ast.File
+	// imports maps the names of the file's imports to their paths, and
+	// used those the generated code needs
+	imports map[string]string
+	used    map[string]string
+}
+
+func (g *toolGenerator) wrapper(spec *ast.TypeSpec, doc *ast.CommentGroup, directive map[string]string) (toolWrapper, error) {
+	structType, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return toolWrapper{}, errors.New("not a struct type")
+	}
+	if spec.TypeParams != nil {
+		return toolWrapper{}, errors.New("generic types are not supported")
+	}
+
+	w := toolWrapper{
+		Type:        spec.Name.Name,
+		Wrapper:     spec.Name.Name + "Tool",
+		Name:        directive["name"],
+		ID:          directive["id"],
+		Description: strings.TrimSpace(doc.Text()),
+		Method:      directive["method"],
+	}
+	if w.Name == "" {
+		w.Name = snakeCase(w.Type)
+	}
+	if w.ID == "" {
+		w.ID = w.Name
+	}
+	if w.Method == "" {
+		w.Method = "Run"
+	}
+	if version, ok := directive["version"]; ok {
+		v, err := semver.NewSemVer(version)
+		if err != nil {
+			return toolWrapper{}, fmt.Errorf("version: %w", err)
+		}
+		w.Version = v
+	}
+	for key := range directive {
+		if !slices.Contains([]string{"name", "id", "method", "version"}, key) {
+			return toolWrapper{}, fmt.Errorf("unknown %s setting %q", ToolDirective, key)
+		}
+	}
+
+	var err error
+	if w.Context, err = g.method(w.Type, w.Method); err != nil {
+		return toolWrapper{}, err
+	}
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 {
+			return toolWrapper{}, errors.New("embedded fields are not supported")
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			param, ok, err := g.parameter(name.Name, field)
+			if err != nil {
+				return toolWrapper{}, fmt.Errorf("field %s: %w", name.Name, err)
+			}
+			if ok {
+				w.Parameters = append(w.Parameters, param)
+			}
+		}
+	}
+	if len(w.Parameters) > 0 {
+		g.used["reflect"] = "reflect"
+	}
+	return w, nil
+}
+
+// method checks that the type has the named method with a signature the
+// tool can call, reporting whether it takes a *tool.Context.
+func (g *toolGenerator) method(typeName, name string) (bool, error) {
+	for _, decl := range g.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Name.Name != name {
+			continue
+		}
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if ident, ok := recv.(*ast.Ident); !ok || ident.Name != typeName {
+			continue
+		}
+
+		params := fieldTypes(fn.Type.Params)
+		results := fieldTypes(fn.Type.Results)
+		signature := fmt.Errorf("method %s must be func(*tool.Context) (T, error) or func() (T, error)", name)
+		if len(results) != 2 || g.expr(results[1]) != "error" || len(params) > 1 {
+			return false, signature
+		}
+		if len(params) == 0 {
+			return false, nil
+		}
+		star, ok := params[0].(*ast.StarExpr)
+		if !ok {
+			return false, signature
+		}
+		sel, ok := star.X.(*ast.SelectorExpr)
+		if !ok || sel.Sel.Name != "Context" {
+			return false, signature
+		}
+		if pkg, ok := sel.X.(*ast.Ident); !ok || g.imports[pkg.Name] != toolPackage {
+			return false, signature
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("no method %s found in the file", name)
+}
+
+// parameter returns the tool parameter for a struct field, or false if
+// encoding/json skips it. Its tags are read when the tool is made, by
+// tool.FieldParameter, so they mean the same as they do to
+// tool.NewToolFromFunc; only the doc comment, which it cannot see, is
+// taken here, to describe a field without a description tag.
+func (g *toolGenerator) parameter(fieldName string, field *ast.Field) (toolParameter, bool, error) {
+	var tag reflect.StructTag
+	if field.Tag != nil {
+		unquoted, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			return toolParameter{}, false, fmt.Errorf("tag: %w", err)
+		}
+		tag = reflect.StructTag(unquoted)
+	}
+	if tag.Get("json") == "-" {
+		return toolParameter{}, false, nil
+	}
+
+	p := toolParameter{Field: fieldName}
+	if _, ok := tag.Lookup("description"); !ok {
+		p.Description = strings.TrimSpace(field.Doc.Text())
+		if p.Description == "" {
+			p.Description = strings.TrimSpace(field.Comment.Text())
+		}
+	}
+	return p, true, nil
+}
+
+// expr prints a type expression, noting the imports it uses.
+func (g *toolGenerator) expr(expr ast.Expr) string {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok {
+				if importPath, ok := g.imports[pkg.Name]; ok {
+					g.used[pkg.Name] = importPath
+				}
+			}
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	printer.Fprint(&buf, g.fset, expr)
+	return buf.String()
+}
+
+// fileImports maps the names a file's imports are referred to by to
+// their paths.
+func fileImports(file *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, spec := range file.Imports {
+		importPath, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(importPath)
+		// Major version suffixes are not part of the package name
+		if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+			name = path.Base(path.Dir(importPath))
+		}
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = importPath
+	}
+	return imports
+}
+
+// toolDirective returns the settings of a doc comment's ToolDirective,
+// and whether it has one.
+func toolDirective(doc *ast.CommentGroup) (map[string]string, bool) {
+	if doc == nil {
+		return nil, false
+	}
+	for _, comment := range doc.List {
+		rest, ok := strings.CutPrefix(comment.Text, ToolDirective)
+		if !ok || rest != "" && !unicode.IsSpace(rune(rest[0])) {
+			continue
+		}
+		settings := map[string]string{}
+		for _, setting := range strings.Fields(rest) {
+			key, value, _ := strings.Cut(setting, "=")
+			settings[key] = value
+		}
+		return settings, true
+	}
+	return nil, false
+}
+
+func fieldTypes(fields *ast.FieldList) []ast.Expr {
+	if fields == nil {
+		return nil
+	}
+	var types []ast.Expr
+	for _, field := range fields.List {
+		for range max(len(field.Names), 1) {
+			types = append(types, field.Type)
+		}
+	}
+	return types
+}
+
+// snakeCase converts a Go identifier such as HTTPGetWeather to
+// http_get_weather.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || unicode.IsUpper(previous) && nextLower {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+var toolTemplate = template.Must(template.New("tool").Parse(`// Code generated by gentool from {{.File}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Imports}}
+{{if .}}	{{.}}{{end}}
+{{- end}}
+)
+{{range .Wrappers}}
+// {{.Wrapper}} is the tool.Tool for {{.Type}}, calling its {{.Method}}
+// method with the arguments it is given.
+type {{.Wrapper}} struct {
+	parameters []tool.Parameter
+}
+
+// New{{.Wrapper}} returns the tool.Tool for {{.Type}}.
+func New{{.Wrapper}}() *{{.Wrapper}} {
+	return &{{.Wrapper}}{
+		parameters: []tool.Parameter{
+		{{- $type := .Type}}
+		{{- range .Parameters}}
+			tool.FieldParameter(reflect.TypeFor[{{$type}}](), {{printf "%q" .Field}}{{if .Description}}, tool.WithDescription({{printf "%q" .Description}}){{end}}),
+		{{- end}}
+		},
+	}
+}
+
+func (t *{{.Wrapper}}) ID() string {
+	return {{printf "%q" .ID}}
+}
+
+// Version returns the version set at build time; see
+// semver.GetBuildVersion.
+func (t *{{.Wrapper}}) Version() semver.SemVer {
+	version, err := semver.GetBuildVersion()
+	if err != nil {
+		return semver.SemVer{Major: {{.Version.Major}}, Minor: {{.Version.Minor}}, Patch: {{.Version.Patch}}, Hash: {{printf "%q" .Version.Hash}}}
+	}
+	return version
+}
+
+func (t *{{.Wrapper}}) Name() string {
+	return {{printf "%q" .Name}}
+}
+
+func (t *{{.Wrapper}}) Description() string {
+	return {{printf "%q" .Description}}
+}
+
+func (t *{{.Wrapper}}) Parameters() []tool.Parameter {
+	return append([]tool.Parameter(nil), t.parameters...)
+}
+
+// Execute validates args against the tool's parameters and calls
+// {{.Type}}.{{.Method}} with them.
+func (t *{{.Wrapper}}) Execute(ctx *tool.Context, args tool.Arguments) tool.ResultInterface {
+	ctx = tool.PrepareContext(ctx, t, args)
+	ctx.Stats().MarkStarted()
+	defer ctx.Stats().MarkFinished()
+
+	result := t.execute(ctx, args)
+	ctx.SetOutput(result)
+	return result
+}
+
+func (t *{{.Wrapper}}) execute(ctx *tool.Context, args tool.Arguments) tool.ResultInterface {
+	if err := ctx.Err(); err != nil {
+		return tool.NewError(err)
+	}
+	{{if .Parameters}}validated{{else}}_{{end}}, err := tool.ValidateArguments(args, t.parameters)
+	if err != nil {
+		return tool.NewError(err)
+	}
+
+{{- if .Parameters}}
+	value, err := tool.Coerce(validated, reflect.TypeFor[{{.Type}}]())
+	if err != nil {
+		return tool.NewError(err)
+	}
+	input := value.({{.Type}})
+{{- else}}
+	var input {{.Type}}
+{{- end}}
+	output, err := input.{{.Method}}({{if .Context}}ctx{{end}})
+	if err != nil {
+		return tool.NewError(err)
+	}
+	return tool.NewOK(output)
+}
+{{end}}`))
@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+const gentoolSource = `package weather
+
+import (
+	"time"
+
+	gtool "github.com/hlfshell/gotonomy/tool"
+)
+
+type Unit string
+
+// GetWeather fetches the current weather for a city.
+//
+//gotonomy:tool id=hlfshell/get_weather version=1.2.0
+type GetWeather struct {
+	// City is the city to get the weather for.
+	City  string    ` + "`json:\"city\" minLength:\"2\"`" + `
+	Unit  Unit      ` + "`json:\"unit,omitempty\" enum:\"celsius,fahrenheit\"`" + `
+	Days  int       ` + "`json:\"days,omitempty\" minimum:\"1\" maximum:\"14\" default:\"3\"`" + `
+	Since time.Time ` + "`json:\"since\" description:\"Earliest time\" required:\"false\"`" + `
+	Debug bool      ` + "`json:\"-\"`" + `
+	cache map[string]string
+}
+
+func (g *GetWeather) Run(ctx *gtool.Context) (string, error) {
+	return g.City, nil
+}
+
+// HTTPPing checks a host is up.
+type HTTPPing struct {
+	Host string
+}
+
+func (HTTPPing) Run() (bool, error) { return true, nil }
+
+type NoMethod struct{}
+`
+
+func writeGentoolSource(t *testing.T, source string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "weather.go")
+	if err := os.WriteFile(file, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestGenerateToolWrapper(t *testing.T) {
+	file := writeGentoolSource(t, gentoolSource)
+
+	code, err := GenerateToolWrapper("", "weather", file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "gen.go", code, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, code)
+	}
+
+	for _, expected := range []string{
+		"// Code generated by gentool from weather.go; DO NOT EDIT.",
+		"package weather",
+		"import (\n\t\"reflect\"\n\n\t\"github.com/hlfshell/gotonomy/tool\"\n\t\"github.com/hlfshell/gotonomy/utils/semver\"\n)",
+		`tool.FieldParameter(reflect.TypeFor[GetWeather](), "City", tool.WithDescription("City is the city to get the weather for.")),`,
+		`tool.FieldParameter(reflect.TypeFor[GetWeather](), "Unit"),`,
+		`tool.FieldParameter(reflect.TypeFor[GetWeather](), "Days"),`,
+		`tool.FieldParameter(reflect.TypeFor[GetWeather](), "Since"),`,
+		`return "hlfshell/get_weather"`,
+		`return "get_weather"`,
+		`return "GetWeather fetches the current weather for a city."`,
+		"return semver.SemVer{Major: 1, Minor: 2, Patch: 0, Hash: \"\"}",
+		"validated, err := tool.ValidateArguments(args, t.parameters)",
+		"value, err := tool.Coerce(validated, reflect.TypeFor[GetWeather]())",
+		"input := value.(GetWeather)",
+		"output, err := input.Run(ctx)",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("expected %q in the generated code:\n%s", expected, code)
+		}
+	}
+	for _, unexpected := range []string{"Debug", "cache", "HTTPPing"} {
+		if strings.Contains(code, unexpected) {
+			t.Errorf("did not expect %q in the generated code", unexpected)
+		}
+	}
+}
+
+type gentoolUnit string
+
+// gentoolForecast has the optional, constrained fields of a type a
+// generated tool wraps.
+type gentoolForecast struct {
+	City  string      `json:"city"`
+	Unit  gentoolUnit `json:"unit,omitempty" enum:"celsius,fahrenheit" default:"celsius"`
+	Scale gentoolUnit `json:"scale,omitempty" enum:"linear,log"`
+	Days  int         `json:"days,omitempty" minimum:"1"`
+	Query string      `json:"query,omitempty" minLength:"3" pattern:"^\\w"`
+}
+
+func TestGenerateToolWrapper_OptionalConstrainedFields(t *testing.T) {
+	// The parameters as the generated constructor makes them
+	var parameters []tool.Parameter
+	for _, field := range []string{"City", "Unit", "Scale", "Days", "Query"} {
+		parameters = append(parameters, tool.FieldParameter(reflect.TypeFor[gentoolForecast](), field))
+	}
+
+	validated, err := tool.ValidateArguments(tool.Arguments{"city": "Boston"}, parameters)
+	if err != nil {
+		t.Fatalf("omitted optional fields should not be checked: %v", err)
+	}
+	if want := (tool.Arguments{"city": "Boston", "unit": gentoolUnit("celsius")}); !reflect.DeepEqual(validated, want) {
+		t.Errorf("validated = %v, want %v", validated, want)
+	}
+
+	_, err = tool.ValidateArguments(tool.Arguments{"city": "Boston", "scale": "cubic", "days": 0.0}, parameters)
+	if err == nil || !strings.Contains(err.Error(), "cubic is not one of") || !strings.Contains(err.Error(), "less than the minimum") {
+		t.Errorf("expected the given fields to be checked, got %v", err)
+	}
+}
+
+func TestGenerateToolWrapper_ByType(t *testing.T) {
+	file := writeGentoolSource(t, gentoolSource)
+
+	code, err := GenerateToolWrapper("HTTPPing", "weather", file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"type HTTPPingTool struct",
+		`return "http_ping"`,
+		`tool.FieldParameter(reflect.TypeFor[HTTPPing](), "Host"),`,
+		"output, err := input.Run()",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("expected %q in the generated code:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "GetWeather") {
+		t.Errorf("expected only HTTPPing and its imports:\n%s", code)
+	}
+}
+
+func TestGenerateToolWrapper_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		typeName string
+		source   string
+		err      string
+	}{
+		{"missing type", "Missing", gentoolSource, "no such type"},
+		{"missing method", "NoMethod", gentoolSource, "no method Run"},
+		{"not a struct", "Unit", gentoolSource, "not a struct type"},
+		{"nothing annotated", "", "package weather\n\ntype A struct{}\n", "no types annotated"},
+		{"bad signature", "", "package weather\n\n//gotonomy:tool\ntype A struct{}\n\nfunc (A) Run(s string) error { return nil }\n", "method Run must be"},
+		{"unknown setting", "", "package weather\n\n//gotonomy:tool nmae=a\ntype A struct{}\n\nfunc (A) Run() (int, error) { return 0, nil }\n", `unknown //gotonomy:tool setting "nmae"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GenerateToolWrapper(tt.typeName, "weather", writeGentoolSource(t, tt.source))
+			if !errors.Is(err, ErrGenerateTool) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected ErrGenerateTool with %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, expected := range map[string]string{
+		"GetWeather":  "get_weather",
+		"HTTPPing":    "http_ping",
+		"Search2Web":  "search2_web",
+		"lowercase":   "lowercase",
+		"ParseJSONID": "parse_jsonid",
+	} {
+		if got := snakeCase(name); got != expected {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, expected)
+		}
+	}
+}
@@ -49,6 +49,7 @@ func (r JudgeResult) Validate() error {
 //
 // The judge does NOT use tools. It outputs strict JSON only:
 // {"verdict":"pass|fail|replan","justification":"...","suggested_fix":"...optional..."}
+// requested as a JudgeResult schema, natively where the model supports it.
 func NewJudgeAgent(m model.Model) *agent.Agent {
 	parser := func(output string) (any, error) {
 		var res JudgeResult
@@ -79,6 +80,9 @@ func NewJudgeAgent(m model.Model) *agent.Agent {
 		agent.WithParser(parser),
 		agent.WithExtractor(extractor),
 		agent.WithMaxIterations(3),
+		agent.WithModelConfig(model.ModelConfig{
+			ResponseFormat: model.ResponseFormatFor[JudgeResult]("judge_result"),
+		}),
 	)
 }
 
@@ -3,38 +3,12 @@ package judging
 import (
 	"testing"
 
-	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/model/modeltest"
 	"github.com/hlfshell/gotonomy/tool"
 )
 
-type mockModel struct {
-	complete func(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error)
-}
-
-func (m *mockModel) Description() model.ModelDescription {
-	return model.ModelDescription{
-		Model:            "mock",
-		Provider:         "mock",
-		MaxContextTokens: 8192,
-		Description:      "mock model",
-		CanUseTools:      false,
-	}
-}
-
-func (m *mockModel) Complete(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error) {
-	return m.complete(ctx, req)
-}
-
 func TestJudgeAgent_Pass(t *testing.T) {
-	m := &mockModel{
-		complete: func(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error) {
-			_ = ctx
-			_ = req
-			return model.CompletionResponse{
-				Text: `{"verdict":"pass","justification":"Matches expectation."}`,
-			}, nil
-		},
-	}
+	m := modeltest.NewScriptedText(`{"verdict":"pass","justification":"Matches expectation."}`)
 
 	judge := NewJudgeAgent(m)
 	res := judge.Execute(nil, tool.Arguments{
@@ -62,18 +36,10 @@ func TestJudgeAgent_Pass(t *testing.T) {
 }
 
 func TestJudgeAgent_RetryOnInvalidJSON(t *testing.T) {
-	call := 0
-	m := &mockModel{
-		complete: func(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error) {
-			_ = ctx
-			_ = req
-			call++
-			if call == 1 {
-				return model.CompletionResponse{Text: "not json"}, nil
-			}
-			return model.CompletionResponse{Text: `{"verdict":"fail","justification":"Does not match expectation.","suggested_fix":"Include X."}`}, nil
-		},
-	}
+	m := modeltest.NewScriptedText(
+		"not json",
+		`{"verdict":"fail","justification":"Does not match expectation.","suggested_fix":"Include X."}`,
+	)
 
 	judge := NewJudgeAgent(m)
 	res := judge.Execute(nil, tool.Arguments{
@@ -87,8 +53,8 @@ func TestJudgeAgent_RetryOnInvalidJSON(t *testing.T) {
 	if res.Errored() {
 		t.Fatalf("expected ok, got error: %v", res.GetError())
 	}
-	if call < 2 {
-		t.Fatalf("expected retry, model calls=%d", call)
+	if m.Calls() < 2 {
+		t.Fatalf("expected retry, model calls=%d", m.Calls())
 	}
 
 	jr, ok := res.GetResult().(JudgeResult)
@@ -1,18 +1,51 @@
 package agent
 
-import "github.com/hlfshell/gotonomy/tool"
+import (
+	"fmt"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/prompt"
+	"github.com/hlfshell/gotonomy/tool"
+)
 
 // AgentOption is a functional option for configuring an Agent.
 type AgentOption func(*Agent)
 
-// WithPrompt is currently a no-op placeholder for future prompt templating.
-// It exists to avoid breaking API, but does not alter behavior yet.
-// Future versions will wire this into a prompt template system.
-func WithPrompt(prompt string) AgentOption {
+// WithPrompt gives the agent a system prompt, a text/template rendered
+// with PromptData: the agent's Args, tools and session. The default
+// PrepareInput opens the conversation with it. It is rendered once, or
+// before every iteration with RenderEveryIteration. Arguments the
+// template refers to must be among the agent's parameters; a prompt that
+// fails to parse or refers to others fails the agent's Execute with
+// ErrInvalidPrompt.
+func WithPrompt(text string, options ...PromptOption) AgentOption {
+	return func(a *Agent) {
+		tmpl, err := prompt.NewTemplateCache().AddTemplate(a.name+" prompt", text)
+		if err != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidPrompt, err)
+		}
+		a.prompt = newSystemPrompt(tmpl, err, options)
+	}
+}
+
+// WithPromptTemplate is WithPrompt with a template that has already been
+// parsed, e.g. one loaded with prompt.LoadTemplate.
+func WithPromptTemplate(tmpl *prompt.Template, options ...PromptOption) AgentOption {
 	return func(a *Agent) {
-		// intentionally no-op
-		_ = prompt
+		var err error
+		if tmpl == nil || tmpl.ParsedTemplate == nil {
+			err = fmt.Errorf("%w: no template", ErrInvalidPrompt)
+		}
+		a.prompt = newSystemPrompt(tmpl, err, options)
+	}
+}
+
+func newSystemPrompt(tmpl *prompt.Template, err error, options []PromptOption) *systemPrompt {
+	p := &systemPrompt{template: tmpl, err: err}
+	for _, option := range options {
+		option(p)
 	}
+	return p
 }
 
 // WithArgumentsToMessages sets a custom arguments-to-messages function for the agent.
@@ -50,6 +83,46 @@ func WithExtractor(extractor ExtractResult) AgentOption {
 	}
 }
 
+// WithStreamHandler streams the agent's model calls, passing each event
+// to handler as it arrives. Models that do not implement
+// model.StreamingModel are still supported; their full response is
+// delivered as a single text event.
+func WithStreamHandler(handler StreamHandler) AgentOption {
+	return func(a *Agent) {
+		a.streamHandler = handler
+	}
+}
+
+// WithTokenizer sets the Tokenizer used to check that each request fits
+// the model's context window. By default model.DefaultTokenizer is used.
+func WithTokenizer(tokenizer model.Tokenizer) AgentOption {
+	return func(a *Agent) {
+		a.tokenizer = tokenizer
+	}
+}
+
+// WithTruncation sets how the agent shortens its conversation when a
+// request would not fit the model's context window; e.g.
+// DropOldestToolOutputs or KeepSystemAndLastN. Without one, such a
+// request fails with model.ErrContextTooLong before it is sent.
+func WithTruncation(strategy TruncationStrategy) AgentOption {
+	return func(a *Agent) {
+		a.truncate = strategy
+	}
+}
+
+// WithSummarization makes the agent fold the older steps of a long
+// session into a rolling summary, written by config.Model, so that the
+// model is sent the task, the summary and only the most recent steps.
+// Summaries are stored in the context's scoped ledger under SummaryKey.
+// This applies to the default PrepareInput; custom ones can use
+// Session.Summarized to the same effect.
+func WithSummarization(config SummarizationConfig) AgentOption {
+	return func(a *Agent) {
+		a.summarization = &config
+	}
+}
+
 // WithParameters sets the parameters for the agent.
 func WithParameters(parameters []tool.Parameter) AgentOption {
 	return func(a *Agent) {
@@ -88,6 +161,33 @@ func WithMaxIterations(n int) AgentOption {
 	}
 }
 
+// WithBudget limits the model usage of the agent, including that of the
+// agents and tools it calls, which are held to it as well. Once a limit
+// is reached the agent stops before its next model call, returning its
+// last answer with a BudgetExceededError.
+func WithBudget(budget Budget) AgentOption {
+	return func(a *Agent) {
+		a.config.Budget = budget
+	}
+}
+
+// WithExecutionBudget limits the model usage of the whole execution the
+// agent runs in, as WithBudget does for the agent alone.
+func WithExecutionBudget(budget Budget) AgentOption {
+	return func(a *Agent) {
+		a.config.ExecutionBudget = budget
+	}
+}
+
+// WithModelConfig sets the model configuration (max tokens, sampling,
+// tool choice, response format and so on) sent with every model request
+// the agent makes. It is validated when the agent executes.
+func WithModelConfig(config model.ModelConfig) AgentOption {
+	return func(a *Agent) {
+		a.config.ModelConfig = config
+	}
+}
+
 // WithID sets a custom globally unique identifier for the agent.
 // If not provided, a default ID will be generated from the name.
 // The ID should be globally unique (e.g., "hlfshell/my_agent").
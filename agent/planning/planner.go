@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/hlfshell/gotonomy/agent"
 	"github.com/hlfshell/gotonomy/assets"
 	"github.com/hlfshell/gotonomy/model"
 	"github.com/hlfshell/gotonomy/plan"
@@ -167,15 +168,23 @@ func (a *PlannerAgent) Plan(ctx *tool.Context, input PlannerInput) (*PlannerResu
 	request := model.CompletionRequest{
 		Messages: messages,
 		Config: model.ModelConfig{
-			Temperature: a.temperature,
+			Temperature:    a.temperature,
+			ResponseFormat: model.ResponseFormatFor[planResponse]("plan"),
 		},
 	}
 
-	// Call the model
-	response, err := a.model.Complete(ctx, request)
+	// Hold the planner to the budgets of the agents it plans for
+	if err := agent.CheckBudgets(ctx); err != nil {
+		return nil, err
+	}
+
+	// Call the model, holding it to the plan schema
+	m := model.WithStructuredOutput(model.DefaultStructuredOutputRetries)(a.model)
+	response, err := m.Complete(ctx, request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get completion from model: %w", err)
 	}
+	model.RecordUsage(ctx, a.model, response)
 
 	// Parse the response into a plan
 	generatedPlan, err := a.parsePlanFromResponse(response.Text)
@@ -1,8 +1,11 @@
 package planning
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/hlfshell/gotonomy/agent"
 	"github.com/hlfshell/gotonomy/model"
 	"github.com/hlfshell/gotonomy/plan"
 	"github.com/hlfshell/gotonomy/prompt"
@@ -636,3 +639,58 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+// TestPlannerAgent_Plan_RecordsUsage verifies that the planner's model
+// calls count toward the usage of the context it plans on.
+func TestPlannerAgent_Plan_RecordsUsage(t *testing.T) {
+	mockModel := &MockModel{
+		Response: `{"steps": [{"id": "step1", "name": "Research", "instruction": "Research the topic", "expectation": "A report", "dependencies": []}]}`,
+	}
+	planner, err := NewPlannerAgent("test-planner", "Test Planner", "A test planner", Config{Model: mockModel})
+	if err != nil {
+		t.Fatalf("Failed to create planner agent: %v", err)
+	}
+
+	ctx := tool.NewContext(context.Background())
+	if _, err := planner.Plan(ctx, PlannerInput{Objective: "Write a report"}); err != nil {
+		t.Fatalf("Failed to create plan: %v", err)
+	}
+
+	total := ctx.UsageReport().Total
+	if total.Calls != 1 || total.InputTokens != 100 || total.OutputTokens != 200 {
+		t.Errorf("Expected the planner's usage in the report, got %+v", total)
+	}
+}
+
+// TestPlannerAgent_Plan_BudgetExceeded verifies that the planner does not
+// call its model once the budget of the agent it plans for is spent.
+func TestPlannerAgent_Plan_BudgetExceeded(t *testing.T) {
+	called := false
+	mockModel := &MockModel{
+		CompleteFunc: func(ctx *tool.Context, req model.CompletionRequest) (model.CompletionResponse, error) {
+			called = true
+			return model.CompletionResponse{}, nil
+		},
+	}
+	planner, err := NewPlannerAgent("test-planner", "Test Planner", "A test planner", Config{Model: mockModel})
+	if err != nil {
+		t.Fatalf("Failed to create planner agent: %v", err)
+	}
+
+	spender := tool.NewTool[string]("spender", "Spends its budget, then plans", nil, func(c *tool.Context, args tool.Arguments) (string, error) {
+		if err := c.Data().SetData(agent.BudgetKey, agent.Budget{MaxTokens: 100}); err != nil {
+			return "", err
+		}
+		c.Stats().RecordUsage("earlier", tool.Usage{Calls: 1, InputTokens: 80, OutputTokens: 40})
+		_, err := planner.Plan(c, PlannerInput{Objective: "Write a report"})
+		return "", err
+	})
+
+	result := spender.Execute(tool.NewContext(context.Background()), tool.Arguments{})
+	if !errors.Is(result.GetError(), agent.ErrBudgetExceeded) {
+		t.Fatalf("Expected a budget exceeded error, got %v", result.GetError())
+	}
+	if called {
+		t.Error("Expected no model call past the budget")
+	}
+}
@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"text/template/parse"
+
+	"github.com/hlfshell/gotonomy/prompt"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// ErrInvalidPrompt is returned when an agent's prompt template fails to
+// parse, refers to arguments the agent does not take, or fails to render.
+var ErrInvalidPrompt = errors.New("invalid prompt")
+
+// PromptData is what an agent's prompt template is rendered with.
+type PromptData struct {
+	// Name and Description describe the agent.
+	Name        string
+	Description string
+	// Args are the arguments the agent was called with; templates refer
+	// to them by name, e.g. {{.Args.topic}}.
+	Args tool.Arguments
+	// Tools are the tools the agent may call, sorted by name.
+	Tools []PromptTool
+	// Iteration is the agent's current iteration, from 1.
+	Iteration int
+	// Summary is the session's rolling summary, if one has been made.
+	Summary string
+}
+
+// PromptTool describes a tool to a prompt template.
+type PromptTool struct {
+	Name        string
+	Description string
+}
+
+// systemPrompt is an agent's prompt template and how it is rendered.
+type systemPrompt struct {
+	template       *prompt.Template
+	err            error
+	everyIteration bool
+}
+
+// PromptOption configures how an agent renders its prompt.
+type PromptOption func(*systemPrompt)
+
+// RenderEveryIteration renders the prompt anew before each iteration
+// rather than once, so that it can follow the session as it progresses;
+// e.g. by its Iteration or Summary.
+func RenderEveryIteration() PromptOption {
+	return func(p *systemPrompt) {
+		p.everyIteration = true
+	}
+}
+
+// validate checks that the template only refers to arguments among
+// parameters.
+func (p *systemPrompt) validate(parameters []tool.Parameter) error {
+	if p.err != nil {
+		return p.err
+	}
+	declared := make(map[string]bool, len(parameters))
+	for _, param := range parameters {
+		declared[param.Name()] = true
+	}
+	for _, name := range promptArguments(p.template) {
+		if !declared[name] {
+			return fmt.Errorf("%w: %s refers to argument %q, which is not a parameter of the agent", ErrInvalidPrompt, p.template.Name, name)
+		}
+	}
+	return nil
+}
+
+// promptArguments returns the names of the arguments tmpl refers to, as
+// .Args.name or $.Args.name.
+func promptArguments(tmpl *prompt.Template) []string {
+	var names []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "Args" {
+				names = append(names, n.Ident[1])
+			}
+		case *parse.VariableNode:
+			if len(n.Ident) > 2 && n.Ident[0] == "$" && n.Ident[1] == "Args" {
+				names = append(names, n.Ident[2])
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	if tmpl != nil && tmpl.ParsedTemplate != nil {
+		for _, t := range tmpl.ParsedTemplate.Templates() {
+			if t.Tree != nil {
+				walk(t.Tree.Root)
+			}
+		}
+	}
+	return names
+}
+
+// renderPrompt renders the agent's prompt into the session, the first
+// time round and then, if so configured, on every iteration.
+func (a *Agent) renderPrompt(args tool.Arguments, session *Session) error {
+	if a.prompt == nil || (session.Prompt() != "" && !a.prompt.everyIteration) {
+		return nil
+	}
+
+	data := PromptData{
+		Name:        a.name,
+		Description: a.description,
+		Args:        args,
+		Iteration:   len(session.Steps()) + 1,
+	}
+	for _, t := range a.toolsSlice() {
+		data.Tools = append(data.Tools, PromptTool{Name: t.Name(), Description: t.Description()})
+	}
+	if summary := session.Summary(); summary != nil {
+		data.Summary = summary.Text
+	}
+
+	text, err := a.prompt.template.Render(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPrompt, err)
+	}
+	session.SetPrompt(text)
+	return nil
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/prompt"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func TestWithPrompt_RendersSystemPrompt(t *testing.T) {
+	m := &mockModel{responses: []model.CompletionResponse{
+		{ToolCalls: []model.ToolCall{{ID: "1", Name: "echo", Arguments: tool.Arguments{"text": "hi"}}}},
+		{Text: "done"},
+	}}
+	agent := NewAgent("greeter", "Greets people", m,
+		WithTool(echoTool()),
+		WithPrompt(`You are {{.Name}}: {{.Description}}. Help with {{.Args.input}} using{{range .Tools}} {{.Name}}{{end}}. Iteration {{.Iteration}}.`),
+	)
+
+	result := agent.Execute(nil, tool.Arguments{"input": "greetings"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+
+	expected := "You are greeter: Greets people. Help with greetings using echo. Iteration 1."
+	for i, request := range m.requests {
+		if first := request.Messages[0]; first.Role != model.RoleSystem || first.Content != expected {
+			t.Errorf("request %d: expected the rendered prompt first, got %+v", i, first)
+		}
+		if request.Messages[1].Role != model.RoleUser {
+			t.Errorf("request %d: expected the input after the prompt, got %+v", i, request.Messages[1])
+		}
+	}
+}
+
+func TestWithPrompt_RenderEveryIteration(t *testing.T) {
+	m := &mockModel{responses: []model.CompletionResponse{
+		{ToolCalls: []model.ToolCall{{ID: "1", Name: "echo", Arguments: tool.Arguments{"text": "hi"}}}},
+		{Text: "done"},
+	}}
+	agent := NewAgent("counter", "Counts", m,
+		WithTool(echoTool()),
+		WithPrompt("Iteration {{.Iteration}}", RenderEveryIteration()),
+	)
+
+	result := agent.Execute(nil, tool.Arguments{"input": "count"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	second := m.requests[1].Messages
+	if second[0].Content != "Iteration 2" {
+		t.Errorf("expected the prompt rendered anew, got %+v", second[0])
+	}
+	for _, msg := range second[1:] {
+		if strings.HasPrefix(msg.Content, "Iteration") {
+			t.Errorf("expected the earlier prompt to be replaced, got %+v", second)
+		}
+	}
+}
+
+func TestWithPrompt_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		option AgentOption
+	}{
+		{"undeclared argument", WithPrompt("Write about {{.Args.topic}}")},
+		{"undeclared argument in a block", WithPrompt("{{if .Args.input}}{{range .Tools}}{{$.Args.style}}{{end}}{{end}}")},
+		{"parse error", WithPrompt("{{.Args.input")},
+		{"no template", WithPromptTemplate(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &mockModel{responses: []model.CompletionResponse{{Text: "done"}}}
+			agent := NewAgent("writer", "Writes", m, tt.option)
+			result := agent.Execute(nil, tool.Arguments{"input": "go"})
+			if !errors.Is(result.GetError(), ErrInvalidPrompt) {
+				t.Fatalf("expected ErrInvalidPrompt, got %v", result.GetError())
+			}
+			if m.calls != 0 {
+				t.Errorf("expected no model calls, got %d", m.calls)
+			}
+		})
+	}
+}
+
+func TestWithPromptTemplate_DeclaredParameters(t *testing.T) {
+	tmpl, err := prompt.NewTemplateCache().AddTemplate("writer", "Write a {{.Args.style}} piece about {{.Args.topic}}.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockModel{responses: []model.CompletionResponse{{Text: "done"}}}
+	agent := NewAgent("writer", "Writes", m,
+		WithParameters([]tool.Parameter{
+			tool.NewParameter[string]("topic", "What to write about", true, "", nil),
+			tool.NewParameter[string]("style", "How to write", false, "plain", nil),
+		}),
+		WithPromptTemplate(tmpl),
+	)
+
+	result := agent.Execute(nil, tool.Arguments{"topic": "gophers", "style": "short"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	if got := m.requests[0].Messages[0].Content; got != "Write a short piece about gophers." {
+		t.Errorf("unexpected prompt %q", got)
+	}
+}
@@ -114,6 +114,8 @@ type Response struct {
 	Output    model.Message    `json:"output"`
 	ToolCalls []model.ToolCall `json:"tool_calls"`
 	Error     string           `json:"error,omitempty"`
+	// Model is the model that produced the response, if reported.
+	Model string `json:"model,omitempty"`
 }
 
 type StepStats struct {
@@ -126,8 +128,11 @@ func (ss *StepStats) Duration() time.Duration {
 }
 
 type Session struct {
-	ledger *ledger.ScopedLedger
-	steps  []*Step
+	ledger  *ledger.ScopedLedger
+	steps   []*Step
+	summary *Summary
+	// prompt is the agent's rendered system prompt, if it has one
+	prompt string
 }
 
 func (s *Session) Iterations() int {
@@ -148,23 +153,31 @@ func (s *Session) Duration() time.Duration {
 
 func (s *Session) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&struct {
-		Steps    []*Step `json:"steps"`
-		Finished bool    `json:"finished"`
-		Duration string  `json:"duration"`
+		Steps    []*Step  `json:"steps"`
+		Summary  *Summary `json:"summary,omitempty"`
+		Prompt   string   `json:"prompt,omitempty"`
+		Finished bool     `json:"finished"`
+		Duration string   `json:"duration"`
 	}{
 		Steps:    s.steps,
+		Summary:  s.summary,
+		Prompt:   s.prompt,
 		Finished: s.Finished(),
 		Duration: s.Duration().String(),
 	})
 }
 func (s *Session) UnmarshalJSON(data []byte) error {
 	var aux struct {
-		Steps []*Step `json:"steps"`
+		Steps   []*Step  `json:"steps"`
+		Summary *Summary `json:"summary"`
+		Prompt  string   `json:"prompt"`
 	}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
 	s.steps = aux.Steps
+	s.summary = aux.Summary
+	s.prompt = aux.Prompt
 	return nil
 }
 
@@ -209,10 +222,14 @@ func NewSession(sessionLedger ...*ledger.ScopedLedger) *Session {
 		// Sort the step indexes
 		sort.Ints(stepIdxs)
 
-		return &Session{
+		session := &Session{
 			ledger: sl,
 			steps:  steps,
 		}
+		if summary, err := ledger.GetDataScoped[Summary](sl, SummaryKey); err == nil {
+			session.summary = &summary
+		}
+		return session
 	}
 
 	// Create a new empty session
@@ -276,19 +293,43 @@ func (s *Session) AppendUserMessage(content string) {
 }
 
 // Conversation flattens the session into a sequence of model messages in the
-// order they were sent/received. For steps with tool calls, the order is:
-// 1. Original input messages (user/system messages)
-// 2. Assistant message with tool_calls (if one was returned)
-// 3. Tool result messages
+// order they were sent/received:
+//  1. The first step's input messages (the task)
+//  2. For each step, the assistant message it returned (if any)
+//  3. For each step, the tool results and feedback appended after it
+//
+// Later steps' inputs are not repeated: PrepareInput builds them by
+// replaying this same history, so including them would duplicate it.
 func (s *Session) Conversation() []model.Message {
-	var msgs []model.Message
-	for _, step := range s.steps {
-		// Original input messages
-		msgs = append(msgs, step.input...)
-
-		// Assistant output (may include tool_calls at provider level)
-		if step.response.Output.Role != "" {
-			msgs = append(msgs, step.response.Output)
+	return s.conversationFrom(0)
+}
+
+// Summarized returns the conversation as it is sent to the model once
+// older steps have been folded into a Summary: the task, the summary as a
+// system message, then the steps the summary does not cover. Without a
+// summary it is the full Conversation.
+func (s *Session) Summarized() []model.Message {
+	if s.summary == nil {
+		return s.Conversation()
+	}
+	return s.conversationFrom(s.summary.Steps)
+}
+
+// conversationFrom returns the task followed by the summary (if from is
+// past the first step) and the messages of steps[from:].
+func (s *Session) conversationFrom(from int) []model.Message {
+	if len(s.steps) == 0 {
+		return nil
+	}
+	msgs := append([]model.Message{}, s.steps[0].input...)
+	if from > 0 && s.summary != nil {
+		msgs = append(msgs, s.summary.Message())
+	}
+	for _, step := range s.steps[min(from, len(s.steps)):] {
+		// Assistant output, with the tool calls its tool outputs answer
+		if output := step.response.Output; output.Role != "" {
+			output.ToolCalls = step.response.ToolCalls
+			msgs = append(msgs, output)
 		}
 
 		// Tool outputs + extractor feedback appended after the response
@@ -305,3 +346,27 @@ func (s *Session) Finished() bool {
 	// Finished if no tool calls in the last step
 	return len(lastStep.GetResponse().ToolCalls) == 0
 }
+
+// Summary returns the session's rolling summary of its older steps, or
+// nil if none has been made.
+func (s *Session) Summary() *Summary {
+	return s.summary
+}
+
+// SetSummary replaces the session's rolling summary. Summarized will
+// return it in place of the steps it covers.
+func (s *Session) SetSummary(summary Summary) {
+	s.summary = &summary
+}
+
+// Prompt returns the agent's rendered system prompt, or "" if it has
+// none.
+func (s *Session) Prompt() string {
+	return s.prompt
+}
+
+// SetPrompt sets the system prompt DefaultArgumentsToMessages opens the
+// conversation with; set again, it replaces the one already sent.
+func (s *Session) SetPrompt(prompt string) {
+	s.prompt = prompt
+}
@@ -119,6 +119,49 @@ func TestSessionJSONRoundTrip(t *testing.T) {
 	}
 }
 
+func TestSessionJSONRoundTrip_MultimodalParts(t *testing.T) {
+	sess := NewSession()
+	image := []byte{0x89, 'P', 'N', 'G'}
+	step := NewStep([]model.Message{
+		{
+			Role: model.RoleUser,
+			Parts: []model.ContentPart{
+				model.TextPart("describe this screenshot"),
+				model.ImagePart(image, "image/png"),
+			},
+		},
+	})
+	step.SetResponse(Response{
+		Output: model.Message{Role: model.RoleAssistant, Content: "a login form"},
+	})
+	sess.AddStep(step)
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatalf("failed to marshal session: %v", err)
+	}
+
+	var decoded Session
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal session: %v", err)
+	}
+
+	conv := decoded.Conversation()
+	if len(conv) != 2 {
+		t.Fatalf("expected 2 messages after round-trip, got %d", len(conv))
+	}
+	parts := conv[0].Parts
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 parts after round-trip, got %d", len(parts))
+	}
+	if parts[0].Type != model.PartText || parts[0].Text != "describe this screenshot" {
+		t.Errorf("unexpected text part: %#v", parts[0])
+	}
+	if parts[1].Type != model.PartImage || parts[1].MIMEType != "image/png" || string(parts[1].Data) != string(image) {
+		t.Errorf("unexpected image part: %#v", parts[1])
+	}
+}
+
 func TestSessionConversation_OrderWithAppended(t *testing.T) {
 	sess := NewSession()
 	step := NewStep([]model.Message{
@@ -155,4 +198,59 @@ func TestSessionConversation_OrderWithAppended(t *testing.T) {
 	}
 }
 
+func TestSessionConversation_DoesNotRepeatReplayedInput(t *testing.T) {
+	sess := NewSession()
+	step1 := NewStep([]model.Message{{Role: model.RoleUser, Content: "u"}})
+	step1.SetResponse(Response{Output: model.Message{Role: model.RoleAssistant, Content: "a1"}})
+	sess.AddStep(step1)
+	sess.AppendSystemMessage("tool-output")
+
+	// The second step's input replays the first step's conversation
+	step2 := NewStep(sess.Conversation())
+	step2.SetResponse(Response{Output: model.Message{Role: model.RoleAssistant, Content: "a2"}})
+	sess.AddStep(step2)
 
+	conv := sess.Conversation()
+	if len(conv) != 4 || conv[3].Content != "a2" {
+		t.Fatalf("expected u, a1, tool-output, a2, got %#v", conv)
+	}
+}
+
+func TestSessionConversation_PairsToolCallsWithResults(t *testing.T) {
+	sess := NewSession()
+	step := NewStep([]model.Message{{Role: model.RoleUser, Content: "u"}})
+	step.SetResponse(ResponseFromModel(model.CompletionResponse{
+		Text: "checking",
+		ToolCalls: []model.ToolCall{
+			{ID: "1", Name: "tool1"},
+			{ID: "2", Name: "tool2"},
+		},
+	}))
+	sess.AddStep(step)
+	sess.AppendToolMessage(model.Message{Role: model.RoleTool, Content: "r1", ToolCallID: "1"})
+	sess.AppendToolMessage(model.Message{Role: model.RoleTool, Content: "r2", ToolCallID: "2"})
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded Session
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	for _, conv := range [][]model.Message{sess.Conversation(), decoded.Conversation()} {
+		if len(conv) != 4 {
+			t.Fatalf("expected 4 messages in conversation, got %#v", conv)
+		}
+		assistant := conv[1]
+		if assistant.Role != model.RoleAssistant || len(assistant.ToolCalls) != 2 || assistant.ToolCalls[1].ID != "2" {
+			t.Fatalf("expected the assistant message to carry its tool calls, got %#v", assistant)
+		}
+		for i, id := range []string{"1", "2"} {
+			if result := conv[2+i]; result.Role != model.RoleTool || result.ToolCallID != id {
+				t.Errorf("expected the result of call %s, got %#v", id, result)
+			}
+		}
+	}
+}
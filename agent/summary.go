@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// SummaryKey is the key in the agent's scoped ledger that a session's
+// rolling Summary is stored under. Every summary made is kept in the
+// key's history, so the compression can be audited and replayed.
+const SummaryKey = "summary"
+
+// StatSummaries counts the summaries an agent makes.
+const StatSummaries = "summaries"
+
+// DefaultSummaryKeepSteps is how many recent steps are kept verbatim when
+// SummarizationConfig.KeepSteps is 0.
+const DefaultSummaryKeepSteps = 2
+
+// DefaultSummaryPrompt instructs the summarizing model when
+// SummarizationConfig.Prompt is blank.
+const DefaultSummaryPrompt = `You maintain the working memory of an AI agent partway through a task.
+Summarize the conversation below, including any earlier summary, so the agent can continue without it.
+Keep every fact, result, decision and open question the agent will need, including tool results it relied on; drop pleasantries and repetition.
+Respond with only the summary.`
+
+// Summary is a rolling summary of a Session's older steps.
+type Summary struct {
+	// Text is the summary itself.
+	Text string `json:"text"`
+	// Steps is how many of the session's leading steps the summary
+	// covers; it replaces steps [0, Steps) when the conversation is
+	// replayed to the model.
+	Steps int `json:"steps"`
+	// Model is the model that wrote the summary, if reported.
+	Model string `json:"model,omitempty"`
+	// Usage is what writing the summary cost.
+	Usage     model.UsageStats `json:"usage"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// Message returns the summary as the system message it is replayed as.
+func (s Summary) Message() model.Message {
+	return model.Message{
+		Role:    model.RoleSystem,
+		Content: fmt.Sprintf("Summary of the conversation so far:\n%s", s.Text),
+	}
+}
+
+// SummarizationConfig configures how an agent folds older steps of a long
+// session into a rolling summary.
+type SummarizationConfig struct {
+	// Model writes the summaries. It may be a cheaper model than the
+	// agent's own; if nil, the agent's model is used.
+	Model model.Model
+	// TriggerTokens is the size the conversation may grow to before it
+	// is summarized. If 0, three quarters of the agent model's
+	// MaxContextTokens is used; if that is unknown too, the session is
+	// never summarized.
+	TriggerTokens int
+	// KeepSteps is how many recent steps are always kept verbatim. If 0,
+	// DefaultSummaryKeepSteps is used.
+	KeepSteps int
+	// Prompt instructs the summarizing model. If blank,
+	// DefaultSummaryPrompt is used.
+	Prompt string
+}
+
+// summarize folds the session's older steps into its Summary once the
+// conversation outgrows the configured trigger, storing the new summary
+// in the context's scoped ledger. The task and the most recent steps are
+// always kept as they are.
+func (a *Agent) summarize(ctx *tool.Context, session *Session) error {
+	config := a.summarization
+	if config == nil {
+		return nil
+	}
+
+	trigger := config.TriggerTokens
+	if trigger <= 0 {
+		trigger = a.model.Description().MaxContextTokens * 3 / 4
+	}
+	keep := config.KeepSteps
+	if keep <= 0 {
+		keep = DefaultSummaryKeepSteps
+	}
+	from := 0
+	if previous := session.Summary(); previous != nil {
+		from = previous.Steps
+	}
+	until := len(session.Steps()) - keep
+	if trigger <= 0 || until <= from {
+		return nil
+	}
+	tokenizer := a.tokenizer
+	if tokenizer == nil {
+		tokenizer = model.DefaultTokenizer
+	}
+	if model.CountMessageTokens(tokenizer, session.Summarized()) <= trigger {
+		return nil
+	}
+
+	summarizer := config.Model
+	if summarizer == nil {
+		summarizer = a.model
+	}
+	prompt := config.Prompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+	resp, err := summarizer.Complete(ctx, model.CompletionRequest{
+		Messages: []model.Message{
+			{Role: model.RoleSystem, Content: prompt},
+			{Role: model.RoleUser, Content: transcript(session, from, until)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("summarizing conversation: %w", err)
+	}
+	model.RecordUsage(ctx, summarizer, resp)
+
+	summary := Summary{
+		Text:      strings.TrimSpace(resp.Text),
+		Steps:     until,
+		Model:     resp.Model,
+		Usage:     resp.UsageStats,
+		CreatedAt: time.Now(),
+	}
+	session.SetSummary(summary)
+	ctx.Stats().Incr(StatSummaries)
+	if err := ctx.Data().SetData(SummaryKey, summary); err != nil {
+		return fmt.Errorf("storing summary: %w", err)
+	}
+	return nil
+}
+
+// transcript renders the session's previous summary and steps
+// [from, until) as plain text for the summarizing model.
+func transcript(session *Session, from, until int) string {
+	var sb strings.Builder
+	if previous := session.Summary(); previous != nil {
+		fmt.Fprintf(&sb, "Earlier summary:\n%s\n\n", previous.Text)
+	}
+	steps := session.Steps()
+	if from == 0 {
+		for _, msg := range steps[0].GetInput() {
+			fmt.Fprintf(&sb, "%s: %s\n", msg.Role, msg.Text())
+		}
+	}
+	for _, step := range steps[from:until] {
+		response := step.GetResponse()
+		if text := response.Output.Text(); text != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", model.RoleAssistant, text)
+		}
+		names := make(map[string]string, len(response.ToolCalls))
+		for _, call := range response.ToolCalls {
+			names[call.ID] = call.Name
+			args, _ := json.Marshal(call.Arguments)
+			fmt.Fprintf(&sb, "%s called %s with %s\n", model.RoleAssistant, call.Name, args)
+		}
+		for _, msg := range step.GetAppended() {
+			if name, ok := names[msg.ToolCallID]; ok && msg.Role == model.RoleTool {
+				fmt.Fprintf(&sb, "%s returned: %s\n", name, msg.Text())
+				continue
+			}
+			fmt.Fprintf(&sb, "%s: %s\n", msg.Role, msg.Text())
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/data/ledger"
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func TestSessionSummarized(t *testing.T) {
+	sess := NewSession()
+	for _, text := range []string{"a1", "a2", "a3"} {
+		step := NewStep([]model.Message{{Role: model.RoleUser, Content: "task"}})
+		step.SetResponse(Response{Output: model.Message{Role: model.RoleAssistant, Content: text}})
+		sess.AddStep(step)
+		sess.AppendSystemMessage("result of " + text)
+	}
+	if conv := sess.Summarized(); len(conv) != 7 {
+		t.Fatalf("expected the full conversation without a summary, got %d messages", len(conv))
+	}
+
+	sess.SetSummary(Summary{Text: "a1 and a2 happened", Steps: 2})
+	conv := sess.Summarized()
+	if len(conv) != 4 {
+		t.Fatalf("expected task, summary and the last step, got %+v", conv)
+	}
+	if conv[0].Content != "task" || !strings.Contains(conv[1].Content, "a1 and a2 happened") || conv[2].Content != "a3" {
+		t.Errorf("unexpected conversation: %+v", conv)
+	}
+	if len(sess.Conversation()) != 7 {
+		t.Errorf("expected Conversation to keep the full history")
+	}
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded Session
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if decoded.Summary() == nil || decoded.Summary().Steps != 2 {
+		t.Errorf("expected the summary to round trip, got %+v", decoded.Summary())
+	}
+}
+
+func TestExecute_Summarization(t *testing.T) {
+	m := &mockModel{responses: []model.CompletionResponse{
+		{ToolCalls: []model.ToolCall{{ID: "1", Name: "fetch"}}},
+		{ToolCalls: []model.ToolCall{{ID: "2", Name: "fetch"}}},
+		{ToolCalls: []model.ToolCall{{ID: "3", Name: "fetch"}}},
+		{ToolCalls: []model.ToolCall{{ID: "4", Name: "fetch"}}},
+		{Text: "done"},
+	}}
+	summarizer := &mockModel{responses: []model.CompletionResponse{{Text: "Fetched several pages.", Model: "cheap"}}}
+	fetch := tool.NewTool[string]("fetch", "Fetches a page", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return strings.Repeat("data ", 50), nil
+	})
+	agent := NewAgent("reader", "Reads", m,
+		WithTool(fetch),
+		WithSummarization(SummarizationConfig{Model: summarizer, TriggerTokens: 150, KeepSteps: 1}),
+	)
+
+	ctx := tool.NewContext(context.Background())
+	result := agent.Execute(ctx, tool.Arguments{"input": "read everything"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	if summarizer.calls == 0 {
+		t.Fatal("expected the conversation to be summarized")
+	}
+	if prompt := summarizer.requests[0].Messages[1].Content; !strings.Contains(prompt, "read everything") || !strings.Contains(prompt, "called fetch") {
+		t.Errorf("expected the task and tool calls in the transcript, got %q", prompt)
+	}
+
+	// The last request carries the task, the summary and only recent steps
+	last := m.requests[len(m.requests)-1].Messages
+	if !strings.Contains(last[0].Content, "read everything") || !strings.Contains(last[1].Content, "Fetched several pages.") {
+		t.Errorf("expected the task and summary first, got %+v", last[:2])
+	}
+	if len(last) > 5 {
+		t.Errorf("expected older steps to be left out, got %d messages", len(last))
+	}
+
+	summaries, err := ledger.GetDataHistoryScoped[Summary](ctx.Data(), SummaryKey)
+	if err != nil {
+		t.Fatalf("reading summaries: %v", err)
+	}
+	if len(summaries) != summarizer.calls || summaries[len(summaries)-1].Model != "cheap" {
+		t.Errorf("expected every summary in the ledger, got %+v", summaries)
+	}
+	if count := ctx.Stats().GetCount(StatSummaries); count == nil || int(*count) != summarizer.calls {
+		t.Errorf("expected summaries to be counted, got %v", count)
+	}
+}
@@ -104,20 +104,15 @@ func processToolResult(
 	return content
 }
 
-// appendToolMessagesToSession adds all tool results as messages to
-// the session.
+// appendToolMessagesToSession adds all tool results as tool messages to
+// the session, each answering its call by ID.
 func appendToolMessagesToSession(sess *Session, results []toolResult) {
 	for _, result := range results {
-		content := fmt.Sprintf("Tool %s returned: %s", result.call.Name, result.content)
-		if result.call.ID != "" {
-			content = fmt.Sprintf("ToolCall %s (%s) returned: %s", result.call.ID, result.call.Name, result.content)
-		}
-		systemMsg := model.Message{
-			Role:       model.RoleSystem,
-			Content:    content,
+		sess.AppendToolMessage(model.Message{
+			Role:       model.RoleTool,
+			Content:    result.content,
 			ToolCallID: result.call.ID,
-		}
-		sess.AppendToolMessage(systemMsg)
+		})
 	}
 }
 
@@ -214,6 +209,12 @@ func (a *Agent) handleToolCalls(
 
 	wg.Wait()
 
+	// A cancelled or timed out run stops here rather than feeding
+	// partial tool results back to the model.
+	if err := parentCtx.Err(); err != nil {
+		return fmt.Errorf("tool calls interrupted: %w", err)
+	}
+
 	// If StopOnFirstToolError and we encountered an error, return it
 	if a.config.ToolErrorHandling == StopOnFirstToolError && firstError != nil {
 		return firstError
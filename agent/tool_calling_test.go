@@ -437,12 +437,11 @@ func TestAppendToolMessagesToSession(t *testing.T) {
 	// Check tool messages
 	for i, result := range results {
 		toolMsg := messages[i]
-		if toolMsg.Role != model.RoleSystem {
-			t.Errorf("Message %d: expected RoleSystem, got %v", i, toolMsg.Role)
+		if toolMsg.Role != model.RoleTool {
+			t.Errorf("Message %d: expected RoleTool, got %v", i, toolMsg.Role)
 		}
-		expected := fmt.Sprintf("ToolCall %s (%s) returned: %s", result.call.ID, result.call.Name, result.content)
-		if toolMsg.Content != expected {
-			t.Errorf("Message %d: expected content %q, got %q", i, expected, toolMsg.Content)
+		if toolMsg.Content != result.content {
+			t.Errorf("Message %d: expected content %q, got %q", i, result.content, toolMsg.Content)
 		}
 		if toolMsg.ToolCallID != result.call.ID {
 			t.Errorf("Message %d: expected ToolCallID %q, got %q", i, result.call.ID, toolMsg.ToolCallID)
@@ -530,7 +529,7 @@ func TestHandleToolCalls_SingleTool_Success(t *testing.T) {
 	// Find tool message
 	found := false
 	for _, msg := range messages {
-		if msg.Role == model.RoleSystem && msg.ToolCallID != "" && contains(msg.Content, "tool1 result") {
+		if msg.Role == model.RoleTool && msg.ToolCallID != "" && contains(msg.Content, "tool1 result") {
 			found = true
 			break
 		}
@@ -712,7 +711,7 @@ func TestHandleToolCalls_OrderPreservation(t *testing.T) {
 	// Find tool messages and verify order
 	toolMessages := make([]string, 0)
 	for _, msg := range messages {
-		if msg.Role == model.RoleSystem {
+		if msg.Role == model.RoleTool {
 			toolMessages = append(toolMessages, msg.Content)
 		}
 	}
@@ -857,7 +856,7 @@ func TestHandleToolCalls_FunctionOnError(t *testing.T) {
 
 	found := false
 	for _, msg := range messages {
-		if msg.Role == model.RoleSystem && contains(msg.Content, "recovered from error") {
+		if msg.Role == model.RoleTool && contains(msg.Content, "recovered from error") {
 			found = true
 			break
 		}
@@ -908,7 +907,7 @@ func TestHandleToolCalls_FunctionOnError_HandlerReturnsError(t *testing.T) {
 
 	found := false
 	for _, msg := range messages {
-		if msg.Role == model.RoleSystem && contains(msg.Content, "handler error message") {
+		if msg.Role == model.RoleTool && contains(msg.Content, "handler error message") {
 			found = true
 			break
 		}
@@ -972,7 +971,7 @@ func TestHandleToolCalls_ConcurrentErrorHandling(t *testing.T) {
 
 	toolMessages := make([]string, 0)
 	for _, msg := range messages {
-		if msg.Role == model.RoleSystem {
+		if msg.Role == model.RoleTool {
 			toolMessages = append(toolMessages, msg.Content)
 		}
 	}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// TypedAgent is an Agent whose final answer is parsed into a T. The
+// model is asked for T's JSON schema as its ResponseFormat, and answers
+// that fail to parse, or whose T fails its Validate method if it has
+// one, are sent back to the model with the error to correct.
+//
+// The embedded Agent is still a tool.Tool, returning the T as its
+// result, so it can be handed to other agents and tools as any other.
+type TypedAgent[T any] struct {
+	*Agent
+}
+
+// NewTypedAgent creates a TypedAgent as NewAgent does. Unless the options
+// set one, the agent's ResponseFormat is derived from T with
+// model.ResponseFormatFor. A parser or extractor set by the options
+// takes the place of the typed parsing, and must produce a T.
+func NewTypedAgent[T any](
+	name, description string,
+	m model.Model,
+	opts ...AgentOption,
+) *TypedAgent[T] {
+	typed := func(a *Agent) {
+		if a.config.ModelConfig.ResponseFormat == nil {
+			a.config.ModelConfig.ResponseFormat = model.ResponseFormatFor[T](name)
+		}
+		if a.extractResult == nil {
+			a.parseResponse = ParseInto[T]
+			a.extractResult = typedExtractor(model.DefaultStructuredOutputRetries)
+		}
+	}
+	return &TypedAgent[T]{Agent: NewAgent(name, description, m, append(opts, typed)...)}
+}
+
+// Execute runs the agent and returns its answer as a T.
+func (t *TypedAgent[T]) Execute(ctx *tool.Context, args tool.Arguments) tool.Result[T] {
+	res := t.Agent.Execute(ctx, args)
+	if res.Errored() {
+		return tool.Result[T]{Error: res.GetError()}
+	}
+	value, ok := res.GetResult().(T)
+	if !ok {
+		var zero T
+		return tool.Result[T]{Error: fmt.Errorf("agent %s: %w: result is %T, not %T", t.name, model.ErrInvalidResponse, res.GetResult(), zero)}
+	}
+	return tool.Result[T]{Result: value}
+}
+
+// ParseInto is a ResponseParser that unmarshals the JSON in output, as
+// found by model.ExtractJSON, into a T, then checks it with its Validate
+// method if it has one.
+func ParseInto[T any](output string) (any, error) {
+	var value T
+	if err := json.Unmarshal([]byte(model.ExtractJSON(output)), &value); err != nil {
+		return nil, err
+	}
+	var validated any = value
+	if _, ok := validated.(interface{ Validate() error }); !ok {
+		validated = &value
+	}
+	if v, ok := validated.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// typedExtractor parses the final answer with the agent's ResponseParser
+// like ExtractorFromParser, but on failure shows the model the error and
+// asks again, up to maxRetries times, before failing with
+// model.ErrInvalidResponse.
+func typedExtractor(maxRetries int) ExtractResult {
+	return func(a *Agent, ctx *tool.Context, session *Session) ExtractDecision {
+		last := session.LastStep()
+		if last == nil || len(last.GetResponse().ToolCalls) > 0 {
+			return ExtractDecision{}
+		}
+
+		parsed, err := a.parseResponse(last.GetResponse().Output.Content)
+		if err == nil {
+			return ExtractDecision{Done: true, Result: parsed}
+		}
+
+		// Every earlier answer without tool calls was rejected too
+		rejected := 0
+		for _, step := range session.Steps() {
+			if len(step.GetResponse().ToolCalls) == 0 {
+				rejected++
+			}
+		}
+		if rejected > maxRetries {
+			return ExtractDecision{Err: fmt.Errorf("agent %s: %w: %v", a.name, model.ErrInvalidResponse, err)}
+		}
+		return ExtractDecision{
+			Warnings: []string{fmt.Sprintf("failed to parse response: %v", err)},
+			Feedback: []model.Message{{
+				Role:    model.RoleUser,
+				Content: fmt.Sprintf("Your previous response was invalid: %v\n\nRespond again with only the corrected JSON, with no markdown or other text.", err),
+			}},
+		}
+	}
+}
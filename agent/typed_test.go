@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+type forecast struct {
+	City        string  `json:"city"`
+	Temperature float64 `json:"temperature"`
+}
+
+func (f forecast) Validate() error {
+	if f.City == "" {
+		return fmt.Errorf("city is required")
+	}
+	return nil
+}
+
+func TestTypedAgent_ParsesResult(t *testing.T) {
+	m := &mockModel{
+		desc:      model.ModelDescription{Model: "test-model", StructuredOutput: true},
+		responses: []model.CompletionResponse{{Text: "```json\n{\"city\":\"Boston\",\"temperature\":12.5}\n```"}},
+	}
+	agent := NewTypedAgent[forecast]("forecaster", "Forecasts weather", m)
+
+	result := agent.Execute(nil, tool.Arguments{"input": "Boston"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	if result.Result != (forecast{City: "Boston", Temperature: 12.5}) {
+		t.Errorf("unexpected result %+v", result.Result)
+	}
+
+	format := m.requests[0].Config.ResponseFormat
+	if format == nil || format.Type != model.ResponseFormatJSONSchema || format.Name != "forecaster" {
+		t.Fatalf("expected a json_schema format derived from the type, got %+v", format)
+	}
+	if _, ok := format.Schema["properties"].(map[string]any)["city"]; !ok {
+		t.Errorf("expected the schema to describe forecast, got %v", format.Schema)
+	}
+}
+
+func TestTypedAgent_RetriesWithFeedback(t *testing.T) {
+	m := &mockModel{
+		desc: model.ModelDescription{Model: "test-model", StructuredOutput: true},
+		responses: []model.CompletionResponse{
+			{Text: `{"city":"","temperature":3}`},
+			{Text: `{"city":"Oslo","temperature":3}`},
+		},
+	}
+	agent := NewTypedAgent[forecast]("forecaster", "Forecasts weather", m)
+
+	result := agent.Execute(nil, tool.Arguments{"input": "Oslo"})
+	if result.Errored() {
+		t.Fatalf("unexpected error: %v", result.GetError())
+	}
+	if result.Result.City != "Oslo" {
+		t.Errorf("unexpected result %+v", result.Result)
+	}
+
+	retry := m.requests[1].Messages
+	feedback := retry[len(retry)-1]
+	if feedback.Role != model.RoleUser || !strings.Contains(feedback.Content, "city is required") {
+		t.Errorf("expected the validation error fed back, got %+v", feedback)
+	}
+}
+
+func TestTypedAgent_GivesUp(t *testing.T) {
+	m := &mockModel{
+		desc:      model.ModelDescription{Model: "test-model", StructuredOutput: true},
+		responses: []model.CompletionResponse{{Text: `{"city":"","temperature":3}`}},
+	}
+	agent := NewTypedAgent[forecast]("forecaster", "Forecasts weather", m)
+
+	result := agent.Execute(nil, tool.Arguments{"input": "Nowhere"})
+	if !errors.Is(result.GetError(), model.ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse, got %v", result.GetError())
+	}
+	if m.calls != model.DefaultStructuredOutputRetries+1 {
+		t.Errorf("expected %d calls, got %d", model.DefaultStructuredOutputRetries+1, m.calls)
+	}
+}
+
+func TestTypedAgent_AsTool(t *testing.T) {
+	m := &mockModel{
+		desc:      model.ModelDescription{Model: "test-model", StructuredOutput: true},
+		responses: []model.CompletionResponse{{Text: `{"city":"Lima","temperature":20}`}},
+	}
+	var weather tool.Tool = NewTypedAgent[forecast]("forecaster", "Forecasts weather", m).Agent
+
+	result := weather.Execute(nil, tool.Arguments{"input": "Lima"})
+	if got, ok := result.GetResult().(forecast); !ok || got.City != "Lima" {
+		t.Errorf("expected a forecast result, got %#v", result.GetResult())
+	}
+}
+
+func TestParseInto(t *testing.T) {
+	if _, err := ParseInto[forecast](`{"city":1}`); err == nil {
+		t.Error("expected a type mismatch to fail")
+	}
+	got, err := ParseInto[[]int]("Here you go: [1, 2, 3]")
+	if err != nil || len(got.([]int)) != 3 {
+		t.Errorf("expected [1 2 3], got %v (%v)", got, err)
+	}
+}
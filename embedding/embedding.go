@@ -80,7 +80,8 @@ type EmbeddingModel interface {
 	// GetInfo returns information about the embedding model.
 	GetInfo() ModelInfo
 
-	// Embed generates embeddings for the given request.
+	// Embed generates embeddings for the given request. ctx may be a
+	// *tool.Context so the call is cancelled along with its execution.
 	Embed(ctx context.Context, request EmbeddingRequest) (EmbeddingResponse, error)
 
 	// SupportsContentType checks if the model supports a specific content type.
@@ -5,3 +5,5 @@ go 1.24.2
 require github.com/google/uuid v1.6.0
 
 require github.com/hlfshell/structured-parse/go v1.0.3
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -0,0 +1,312 @@
+package model
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+const (
+	// StatModelCacheHits counts requests answered from the cache.
+	StatModelCacheHits = "model_cache_hits"
+	// StatModelCacheMisses counts cacheable requests sent to the model.
+	StatModelCacheMisses = "model_cache_misses"
+)
+
+// CacheMode controls how WithCache treats a single request.
+type CacheMode string
+
+const (
+	// CacheDefault answers the request from the cache if possible and
+	// caches the response otherwise.
+	CacheDefault CacheMode = ""
+	// CacheBypass sends the request to the model without reading or
+	// writing the cache.
+	CacheBypass CacheMode = "bypass"
+	// CacheRefresh invalidates any cached response: the request is sent
+	// to the model and its response replaces the cached one.
+	CacheRefresh CacheMode = "refresh"
+)
+
+// Cache stores completion responses by request key, as computed by
+// RequestKey. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the response cached under key, if any.
+	Get(key string) (CompletionResponse, bool, error)
+	// Set caches resp under key.
+	Set(key string, resp CompletionResponse) error
+	// Delete removes any response cached under key.
+	Delete(key string) error
+}
+
+// RequestKey returns the cache key for request sent to the model desc
+// describes: a hash of the model's identity, the messages, the tools'
+// IDs, versions and parameter schemas, and the ModelConfig.
+func RequestKey(desc ModelDescription, request CompletionRequest) (string, error) {
+	type toolKey struct {
+		ID          string         `json:"id"`
+		Version     string         `json:"version"`
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	}
+	tools := make([]toolKey, 0, len(request.Tools))
+	for _, t := range request.Tools {
+		tools = append(tools, toolKey{
+			ID:          t.ID(),
+			Version:     t.Version().String(),
+			Name:        t.Name(),
+			Description: t.Description(),
+			Parameters:  tool.ParametersToJSONSchema(t.Parameters()),
+		})
+	}
+
+	data, err := json.Marshal(struct {
+		Provider string      `json:"provider"`
+		Model    string      `json:"model"`
+		Messages []Message   `json:"messages"`
+		Tools    []toolKey   `json:"tools"`
+		Config   ModelConfig `json:"config"`
+	}{desc.Provider, desc.Model, request.Messages, tools, request.Config})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// WithCache returns middleware that answers repeated requests from cache
+// rather than the model. Requests are keyed with RequestKey, and each
+// request's Cache mode can bypass the cache or refresh its entry. Hits
+// and misses are counted in the context's Stats; a hit used no tokens, so
+// its response reports no usage. Failed requests are not cached, and a
+// cache that fails to read or write is reported as an error.
+func WithCache(cache Cache) Middleware {
+	return func(next Model) Model {
+		// lookup returns the request's key and any cached response; an
+		// empty key means the request is not to be cached.
+		lookup := func(ctx *tool.Context, request CompletionRequest) (string, *CompletionResponse, error) {
+			if request.Cache == CacheBypass {
+				return "", nil, nil
+			}
+			key, err := RequestKey(next.Description(), request)
+			if err != nil {
+				return "", nil, err
+			}
+			if request.Cache != CacheRefresh {
+				resp, ok, err := cache.Get(key)
+				if err != nil {
+					return "", nil, fmt.Errorf("failed to read cache: %w", err)
+				}
+				if ok {
+					if ctx != nil {
+						ctx.Stats().Incr(StatModelCacheHits)
+					}
+					resp.UsageStats = UsageStats{}
+					return key, &resp, nil
+				}
+			}
+			if ctx != nil {
+				ctx.Stats().Incr(StatModelCacheMisses)
+			}
+			return key, nil, nil
+		}
+
+		store := func(key string, resp CompletionResponse) error {
+			if key == "" {
+				return nil
+			}
+			if err := cache.Set(key, resp); err != nil {
+				return fmt.Errorf("failed to write cache: %w", err)
+			}
+			return nil
+		}
+
+		return &wrappedModel{
+			next: next,
+			complete: func(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+				key, cached, err := lookup(ctx, request)
+				if err != nil {
+					return CompletionResponse{}, err
+				}
+				if cached != nil {
+					return *cached, nil
+				}
+				resp, err := next.Complete(ctx, request)
+				if err != nil {
+					return CompletionResponse{}, err
+				}
+				return resp, store(key, resp)
+			},
+			stream: func(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error) {
+				key, cached, err := lookup(ctx, request)
+				if err != nil {
+					return nil, err
+				}
+				if cached != nil {
+					return responseEvents(*cached), nil
+				}
+				events, err := Stream(ctx, next, request)
+				if err != nil {
+					return nil, err
+				}
+
+				// Pass the events on as they arrive, caching the response
+				// once it is complete
+				out := make(chan StreamEvent)
+				go func() {
+					defer close(out)
+					for event := range events {
+						if event.Type == StreamEventDone && event.Response != nil {
+							if err := store(key, *event.Response); err != nil {
+								event = StreamEvent{Type: StreamEventError, Err: err}
+							}
+						}
+						out <- event
+					}
+				}()
+				return out, nil
+			},
+		}
+	}
+}
+
+// LRUCache is an in-memory Cache holding up to a fixed number of
+// responses, evicting the least recently used first.
+type LRUCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	mu      sync.Mutex
+}
+
+type lruEntry struct {
+	key  string
+	resp CompletionResponse
+}
+
+// NewLRUCache returns an LRUCache holding up to size responses.
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    max(size, 1),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *LRUCache) Get(key string) (CompletionResponse, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return CompletionResponse{}, false, nil
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*lruEntry).resp, true, nil
+}
+
+func (c *LRUCache) Set(key string, resp CompletionResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruEntry).resp = resp
+		c.order.MoveToFront(element)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, resp: resp})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+func (c *LRUCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+// Len returns the number of cached responses.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// DiskCache is a Cache storing each response as a JSON file in a
+// directory, so that it persists between runs; e.g. to replay a test
+// suite's model calls.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache in dir, creating it if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *DiskCache) Get(key string) (CompletionResponse, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return CompletionResponse{}, false, nil
+	}
+	if err != nil {
+		return CompletionResponse{}, false, err
+	}
+	var resp CompletionResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return CompletionResponse{}, false, fmt.Errorf("corrupt cache entry %s: %w", key, err)
+	}
+	return resp, true, nil
+}
+
+func (c *DiskCache) Set(key string, resp CompletionResponse) error {
+	data, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+func (c *DiskCache) Delete(key string) error {
+	err := os.Remove(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
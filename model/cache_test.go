@@ -0,0 +1,158 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func TestRequestKey(t *testing.T) {
+	desc := ModelDescription{Model: "m", Provider: "test"}
+	echo := tool.NewTool[string]("echo", "echoes", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+	base := userRequest("hi")
+	base.Tools = []tool.Tool{echo}
+
+	key := func(desc ModelDescription, request CompletionRequest) string {
+		k, err := RequestKey(desc, request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return k
+	}
+	baseKey := key(desc, base)
+
+	bypass := base
+	bypass.Cache = CacheBypass
+	if key(desc, bypass) != baseKey {
+		t.Errorf("expected the cache mode not to affect the key")
+	}
+
+	configured := base
+	configured.Config.Temperature = 0.5
+	otherTool := base
+	otherTool.Tools = []tool.Tool{tool.NewTool[string]("echo", "echoes loudly", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})}
+	for name, k := range map[string]string{
+		"message": key(desc, userRequest("hello")),
+		"config":  key(desc, configured),
+		"tools":   key(desc, otherTool),
+		"model":   key(ModelDescription{Model: "other", Provider: "test"}, base),
+	} {
+		if k == baseKey {
+			t.Errorf("expected a different %s to change the key", name)
+		}
+	}
+}
+
+func TestWithCache(t *testing.T) {
+	inner := &scriptedModel{
+		desc: ModelDescription{Model: "m", Provider: "test"},
+		responses: []CompletionResponse{
+			{Text: "first", UsageStats: UsageStats{InputTokens: 5, OutputTokens: 1}},
+			{Text: "second"},
+			{Text: "third"},
+		},
+	}
+	m := WithCache(NewLRUCache(10))(inner)
+	ctx := tool.NewContext(context.Background())
+
+	resp, err := m.Complete(ctx, userRequest("hi"))
+	if err != nil || resp.Text != "first" {
+		t.Fatalf("unexpected response: %+v, %v", resp, err)
+	}
+	resp, err = m.Complete(ctx, userRequest("hi"))
+	if err != nil || resp.Text != "first" || resp.UsageStats.InputTokens != 0 {
+		t.Fatalf("expected the cached response without usage, got %+v, %v", resp, err)
+	}
+	if len(inner.requests) != 1 {
+		t.Errorf("expected one model call, got %d", len(inner.requests))
+	}
+	if hits := ctx.Stats().GetCount(StatModelCacheHits); hits == nil || *hits != 1 {
+		t.Errorf("expected one cache hit, got %v", hits)
+	}
+
+	bypass := userRequest("hi")
+	bypass.Cache = CacheBypass
+	if resp, _ := m.Complete(ctx, bypass); resp.Text != "second" {
+		t.Errorf("expected the bypass to reach the model, got %q", resp.Text)
+	}
+	refresh := userRequest("hi")
+	refresh.Cache = CacheRefresh
+	if resp, _ := m.Complete(ctx, refresh); resp.Text != "third" {
+		t.Errorf("expected the refresh to reach the model, got %q", resp.Text)
+	}
+	if resp, _ := m.Complete(ctx, userRequest("hi")); resp.Text != "third" {
+		t.Errorf("expected the refreshed response to be cached, got %q", resp.Text)
+	}
+}
+
+func TestWithCache_Stream(t *testing.T) {
+	inner := &scriptedModel{responses: []CompletionResponse{{Text: "streamed"}}}
+	cache := NewLRUCache(10)
+	m := WithCache(cache)(inner)
+
+	for i := 0; i < 2; i++ {
+		events, err := Stream(nil, m, userRequest("hi"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := CollectStream(events, nil)
+		if err != nil || resp.Text != "streamed" {
+			t.Fatalf("unexpected response: %+v, %v", resp, err)
+		}
+	}
+	if len(inner.requests) != 1 || cache.Len() != 1 {
+		t.Errorf("expected the streamed response to be cached, got %d calls", len(inner.requests))
+	}
+}
+
+func TestLRUCache_Evicts(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", CompletionResponse{Text: "a"})
+	cache.Set("b", CompletionResponse{Text: "b"})
+	cache.Get("a")
+	cache.Set("c", CompletionResponse{Text: "c"})
+
+	if _, ok, _ := cache.Get("b"); ok {
+		t.Errorf("expected the least recently used entry to be evicted")
+	}
+	if _, ok, _ := cache.Get("a"); !ok {
+		t.Errorf("expected the recently used entry to be kept")
+	}
+	cache.Delete("a")
+	if _, ok, _ := cache.Get("a"); ok || cache.Len() != 1 {
+		t.Errorf("expected the entry to be deleted")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp := CompletionResponse{Text: "saved", ToolCalls: []ToolCall{{ID: "1", Name: "echo", Arguments: tool.Arguments{"x": "y"}}}}
+	if err := cache.Set("key", resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A new cache on the same directory sees the entry
+	reopened, _ := NewDiskCache(dir)
+	got, ok, err := reopened.Get("key")
+	if err != nil || !ok || got.Text != "saved" || got.ToolCalls[0].Arguments["x"] != "y" {
+		t.Fatalf("unexpected entry: %+v, %v, %v", got, ok, err)
+	}
+	if err := reopened.Delete("key"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, _ := cache.Get("key"); ok {
+		t.Errorf("expected the entry to be deleted")
+	}
+	if err := cache.Delete("missing"); err != nil {
+		t.Errorf("expected deleting a missing entry to succeed, got %v", err)
+	}
+}
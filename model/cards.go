@@ -0,0 +1,294 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CardPathsEnv names the environment variable listing extra directories
+// (separated like PATH) to load model cards from.
+const CardPathsEnv = "GOTONOMY_MODEL_CARDS"
+
+// CardRegistry holds model cards, the ModelDescriptions for known models,
+// keyed by provider and model ID. Cards loaded later override earlier
+// ones field by field, so a user file only needs to list what it changes;
+// for example, updated costs for a model that ships with the library.
+type CardRegistry struct {
+	cards map[cardKey]ModelDescription
+	mu    sync.RWMutex
+}
+
+type cardKey struct {
+	provider string
+	model    string
+}
+
+// NewCardRegistry returns an empty CardRegistry.
+func NewCardRegistry() *CardRegistry {
+	return &CardRegistry{cards: make(map[cardKey]ModelDescription)}
+}
+
+// Add validates and adds complete cards, replacing any existing card for
+// the same provider and model.
+func (r *CardRegistry) Add(cards ...ModelDescription) error {
+	for _, card := range cards {
+		if err := card.Validate(); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, card := range cards {
+		r.cards[cardKey{card.Provider, card.Model}] = card
+	}
+	return nil
+}
+
+// Get returns the card for the given provider and model.
+func (r *CardRegistry) Get(provider, model string) (ModelDescription, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	card, ok := r.cards[cardKey{provider, model}]
+	return card, ok
+}
+
+// List returns every card for the given provider, sorted by model.
+func (r *CardRegistry) List(provider string) []ModelDescription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var cards []ModelDescription
+	for key, card := range r.cards {
+		if key.provider == provider {
+			cards = append(cards, card)
+		}
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Model < cards[j].Model })
+	return cards
+}
+
+// Load parses the cards in data and merges them into the registry. Files
+// named *.json hold a single card or an array of cards; anything else is
+// read as YAML with one card per document. Each card must name its
+// provider and model; a card for a model the registry does not know yet
+// must also be complete enough to pass ModelDescription.Validate.
+func (r *CardRegistry) Load(name string, data []byte) error {
+	cards, err := parseCards(name, data)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidModelDescription, name, err)
+	}
+	return r.merge(name, cards, true)
+}
+
+// LoadFile loads the cards in the file at path.
+func (r *CardRegistry) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return r.Load(path, data)
+}
+
+// LoadDir loads every .yaml, .yml and .json file under dir, in lexical
+// order. A missing dir is not an error.
+func (r *CardRegistry) LoadDir(dir string) error {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return r.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS loads every .yaml, .yml and .json file under dir in fsys, in
+// lexical order; useful with embed.FS.
+func (r *CardRegistry) LoadFS(fsys fs.FS, dir string) error {
+	return fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !isCardFile(name) {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		return r.Load(name, data)
+	})
+}
+
+// merge applies cards over the registry's existing ones. With override
+// unset, existing cards win instead.
+func (r *CardRegistry) merge(name string, cards []cardFile, override bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	merged := make(map[cardKey]ModelDescription, len(cards))
+	for i, card := range cards {
+		if card.Provider == "" || card.Model == "" {
+			return fmt.Errorf("%w: %s: card %d needs a provider and model", ErrInvalidModelDescription, name, i)
+		}
+		key := cardKey{card.Provider, card.Model}
+		existing, ok := merged[key]
+		if !ok {
+			existing = r.cards[key]
+		}
+		var desc ModelDescription
+		if override {
+			desc = card.apply(existing)
+		} else {
+			desc = existing
+			if desc.Model == "" {
+				desc = card.apply(ModelDescription{})
+			}
+		}
+		if err := desc.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		merged[key] = desc
+	}
+	for key, desc := range merged {
+		r.cards[key] = desc
+	}
+	return nil
+}
+
+// cardFile is a card as written in a file. Optional fields are pointers
+// so that a card can override only the fields it sets.
+type cardFile struct {
+	Model            string         `json:"model" yaml:"model"`
+	Provider         string         `json:"provider" yaml:"provider"`
+	MaxContextTokens int            `json:"max_context_tokens" yaml:"max_context_tokens"`
+	Description      string         `json:"description" yaml:"description"`
+	Costs            *CostsPerToken `json:"costs" yaml:"costs"`
+	CanUseTools      *bool          `json:"can_use_tools" yaml:"can_use_tools"`
+	AcceptsFileTypes []string       `json:"accepts_file_types" yaml:"accepts_file_types"`
+	StructuredOutput *bool          `json:"structured_output" yaml:"structured_output"`
+}
+
+// apply returns desc with the fields set in the card overridden.
+func (c cardFile) apply(desc ModelDescription) ModelDescription {
+	desc.Model = c.Model
+	desc.Provider = c.Provider
+	if c.MaxContextTokens != 0 {
+		desc.MaxContextTokens = c.MaxContextTokens
+	}
+	if c.Description != "" {
+		desc.Description = c.Description
+	}
+	if c.Costs != nil {
+		desc.Costs = *c.Costs
+	}
+	if c.CanUseTools != nil {
+		desc.CanUseTools = *c.CanUseTools
+	}
+	if c.AcceptsFileTypes != nil {
+		desc.AcceptsFileTypes = c.AcceptsFileTypes
+	}
+	if c.StructuredOutput != nil {
+		desc.StructuredOutput = *c.StructuredOutput
+	}
+	return desc
+}
+
+// parseCards decodes the cards in data, choosing the format by name.
+func parseCards(name string, data []byte) ([]cardFile, error) {
+	if strings.EqualFold(path.Ext(name), ".json") {
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 && data[0] == '[' {
+			var cards []cardFile
+			err := json.Unmarshal(data, &cards)
+			return cards, err
+		}
+		var card cardFile
+		if err := json.Unmarshal(data, &card); err != nil {
+			return nil, err
+		}
+		return []cardFile{card}, nil
+	}
+
+	var cards []cardFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var card cardFile
+		err := decoder.Decode(&card)
+		if errors.Is(err, io.EOF) {
+			return cards, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if card.Model == "" && card.Provider == "" {
+			// Empty document, e.g. a trailing "---"
+			continue
+		}
+		cards = append(cards, card)
+	}
+}
+
+// isCardFile reports whether name has a model card file extension.
+func isCardFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+var (
+	defaultCards     = NewCardRegistry()
+	defaultCardsErr  error
+	defaultCardsOnce sync.Once
+)
+
+// RegisterDefaultCards adds cards that ship with a provider, in any format
+// Load accepts. Providers call it from init with their embedded
+// models.yaml; cards loaded from the default paths always take precedence
+// over these.
+func RegisterDefaultCards(name string, data []byte) error {
+	cards, err := parseCards(name, data)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidModelDescription, name, err)
+	}
+	return defaultCards.merge(name, cards, false)
+}
+
+// DefaultCardPaths returns the directories LoadDefaultPaths reads user
+// cards from, lowest precedence first: the gotonomy/models directory in
+// the user's config directory, then each directory listed in the
+// GOTONOMY_MODEL_CARDS environment variable.
+func DefaultCardPaths() []string {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "gotonomy", "models"))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv(CardPathsEnv)) {
+		if dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+	return paths
+}
+
+// LoadDefaultPaths returns the shared registry of default cards: those
+// registered by providers, overridden by any found in DefaultCardPaths.
+// The paths are read once, on the first call; later calls return the same
+// registry and error.
+func LoadDefaultPaths() (*CardRegistry, error) {
+	defaultCardsOnce.Do(func() {
+		for _, dir := range DefaultCardPaths() {
+			if err := defaultCards.LoadDir(dir); err != nil {
+				defaultCardsErr = fmt.Errorf("failed to load model cards from %s: %w", dir, err)
+				return
+			}
+		}
+	})
+	return defaultCards, defaultCardsErr
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCardRegistry_Load(t *testing.T) {
+	registry := NewCardRegistry()
+	err := registry.Load("models.yaml", []byte(`
+model: big
+provider: acme
+max_context_tokens: 100000
+description: "A big model"
+costs:
+  input: 0.000002
+  output: 0.000008
+  reasoning: 0.0
+can_use_tools: true
+accepts_file_types: [image/png]
+tags: [vision]
+---
+model: small
+provider: acme
+max_context_tokens: 8000
+---
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	big, ok := registry.Get("acme", "big")
+	if !ok || big.MaxContextTokens != 100000 || !big.CanUseTools || big.Costs.Output != 0.000008 || !big.Accepts("image/png") {
+		t.Fatalf("unexpected card: %+v", big)
+	}
+	if cards := registry.List("acme"); len(cards) != 2 || cards[0].Model != "big" || cards[1].Model != "small" {
+		t.Fatalf("unexpected cards: %+v", cards)
+	}
+	if _, ok := registry.Get("other", "big"); ok {
+		t.Errorf("expected cards to be keyed by provider")
+	}
+
+	// A later card overrides only the fields it sets
+	err = registry.Load("override.json", []byte(`[
+		{"model": "big", "provider": "acme", "costs": {"input": 0.000001, "output": 0.000004}, "can_use_tools": false}
+	]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	big, _ = registry.Get("acme", "big")
+	if big.Costs.Input != 0.000001 || big.CanUseTools || big.MaxContextTokens != 100000 || big.Description != "A big model" {
+		t.Errorf("unexpected merged card: %+v", big)
+	}
+}
+
+func TestCardRegistry_LoadErrors(t *testing.T) {
+	registry := NewCardRegistry()
+
+	tests := map[string]string{
+		"incomplete.yaml": "model: new\nprovider: acme\n",
+		"anonymous.json":  `{"max_context_tokens": 1000}`,
+		"malformed.yaml":  "model: [",
+	}
+	for name, data := range tests {
+		if err := registry.Load(name, []byte(data)); !errors.Is(err, ErrInvalidModelDescription) {
+			t.Errorf("%s: expected ErrInvalidModelDescription, got %v", name, err)
+		}
+	}
+	if cards := registry.List("acme"); len(cards) != 0 {
+		t.Errorf("expected failed loads to add nothing, got %+v", cards)
+	}
+}
+
+func TestCardRegistry_LoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/models.yaml":  {Data: []byte("model: m\nprovider: acme\nmax_context_tokens: 1000\n")},
+		"b/models.json":  {Data: []byte(`{"model": "m", "provider": "acme", "max_context_tokens": 2000}`)},
+		"b/README.md":    {Data: []byte("not a card")},
+		"c/ignored.toml": {Data: []byte("model = 'x'")},
+	}
+	registry := NewCardRegistry()
+	if err := registry.LoadFS(fsys, "."); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if card, _ := registry.Get("acme", "m"); card.MaxContextTokens != 2000 {
+		t.Errorf("expected files to load in lexical order, got %+v", card)
+	}
+
+	if err := registry.LoadDir(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("expected a missing dir to be skipped, got %v", err)
+	}
+}
+
+func TestCardRegistry_DefaultsYieldToExisting(t *testing.T) {
+	registry := NewCardRegistry()
+	if err := registry.Add(ModelDescription{Model: "m", Provider: "acme", MaxContextTokens: 5000}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cards, err := parseCards("defaults.yaml", []byte("model: m\nprovider: acme\nmax_context_tokens: 1000\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.merge("defaults.yaml", cards, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if card, _ := registry.Get("acme", "m"); card.MaxContextTokens != 5000 {
+		t.Errorf("expected the existing card to win, got %+v", card)
+	}
+}
+
+func TestCardRegistry_ProviderCardsParse(t *testing.T) {
+	files, err := filepath.Glob("../provider/*/models.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no provider model cards found: %v", err)
+	}
+	registry := NewCardRegistry()
+	for _, file := range files {
+		if err := registry.LoadFile(file); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+	if _, ok := registry.Get("openai", "gpt-4o"); !ok {
+		t.Errorf("expected the openai cards to include gpt-4o")
+	}
+}
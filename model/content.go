@@ -0,0 +1,111 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// PartType identifies the kind of content held by a ContentPart.
+type PartType string
+
+const (
+	PartText  PartType = "text"
+	PartImage PartType = "image"
+	PartFile  PartType = "file"
+)
+
+// ContentPart is a single typed piece of a multimodal message. Non-text
+// parts are identified by their MIME type and carry their content either
+// inline (Data), by URL, or - for files - by a provider-side file ID.
+type ContentPart struct {
+	Type PartType `json:"type"`
+	// Text is the content of a text part.
+	Text string `json:"text,omitempty"`
+	// MIMEType is required for image and file parts, and is checked
+	// against ModelDescription.AcceptsFileTypes.
+	MIMEType string `json:"mime_type,omitempty"`
+	// Data is the raw inline content; it is base64 encoded in JSON.
+	Data []byte `json:"data,omitempty"`
+	// URL points to remotely hosted content.
+	URL string `json:"url,omitempty"`
+	// FileID references a file previously uploaded to the provider.
+	FileID string `json:"file_id,omitempty"`
+	// Filename is an optional human readable name for file parts.
+	Filename string `json:"filename,omitempty"`
+}
+
+// TextPart creates a text content part.
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: PartText, Text: text}
+}
+
+// ImagePart creates an image content part from inline bytes.
+func ImagePart(data []byte, mimeType string) ContentPart {
+	return ContentPart{Type: PartImage, Data: data, MIMEType: mimeType}
+}
+
+// ImageURLPart creates an image content part referencing a URL.
+func ImageURLPart(url, mimeType string) ContentPart {
+	return ContentPart{Type: PartImage, URL: url, MIMEType: mimeType}
+}
+
+// FilePart creates a file content part from inline bytes.
+func FilePart(data []byte, mimeType, filename string) ContentPart {
+	return ContentPart{Type: PartFile, Data: data, MIMEType: mimeType, Filename: filename}
+}
+
+// FileRefPart creates a file content part referencing a file already
+// uploaded to the provider.
+func FileRefPart(fileID, mimeType string) ContentPart {
+	return ContentPart{Type: PartFile, FileID: fileID, MIMEType: mimeType}
+}
+
+// DataURL returns the part's inline data encoded as a data URL
+// (data:<mime>;base64,<data>), as expected by several provider APIs.
+func (p ContentPart) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", p.MIMEType, base64.StdEncoding.EncodeToString(p.Data))
+}
+
+// Validate ensures the part is internally consistent for its type.
+func (p ContentPart) Validate() error {
+	switch p.Type {
+	case PartText:
+		if p.Text == "" {
+			return fmt.Errorf("%w: text part requires text", ErrInvalidMessage)
+		}
+	case PartImage:
+		if p.MIMEType == "" {
+			return fmt.Errorf("%w: image part requires a MIME type", ErrInvalidMessage)
+		}
+		if (len(p.Data) == 0) == (p.URL == "") {
+			return fmt.Errorf("%w: image part requires exactly one of data or URL", ErrInvalidMessage)
+		}
+	case PartFile:
+		if p.MIMEType == "" {
+			return fmt.Errorf("%w: file part requires a MIME type", ErrInvalidMessage)
+		}
+		sources := 0
+		for _, set := range []bool{len(p.Data) > 0, p.URL != "", p.FileID != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("%w: file part requires exactly one of data, URL or file ID", ErrInvalidMessage)
+		}
+	default:
+		return fmt.Errorf("%w: invalid content part type %q", ErrInvalidMessage, p.Type)
+	}
+	return nil
+}
+
+// Accepts returns true if the model accepts the given MIME type as input.
+func (m ModelDescription) Accepts(mimeType string) bool {
+	for _, accepted := range m.AcceptsFileTypes {
+		if strings.EqualFold(accepted, mimeType) {
+			return true
+		}
+	}
+	return false
+}
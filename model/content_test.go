@@ -0,0 +1,87 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContentPart_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		part    ContentPart
+		wantErr bool
+	}{
+		{name: "text", part: TextPart("hi")},
+		{name: "empty text", part: ContentPart{Type: PartText}, wantErr: true},
+		{name: "inline image", part: ImagePart([]byte{1, 2}, "image/png")},
+		{name: "image url", part: ImageURLPart("https://example.com/a.png", "image/png")},
+		{name: "image without mime", part: ContentPart{Type: PartImage, URL: "https://example.com/a.png"}, wantErr: true},
+		{name: "image with data and url", part: ContentPart{Type: PartImage, Data: []byte{1}, URL: "https://x", MIMEType: "image/png"}, wantErr: true},
+		{name: "image without source", part: ContentPart{Type: PartImage, MIMEType: "image/png"}, wantErr: true},
+		{name: "inline file", part: FilePart([]byte("%PDF"), "application/pdf", "doc.pdf")},
+		{name: "file reference", part: FileRefPart("file-123", "application/pdf")},
+		{name: "file with two sources", part: ContentPart{Type: PartFile, FileID: "f", URL: "https://x", MIMEType: "application/pdf"}, wantErr: true},
+		{name: "unknown type", part: ContentPart{Type: "audio"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.part.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidMessage) {
+				t.Errorf("expected ErrInvalidMessage, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMessage_Text(t *testing.T) {
+	plain := Message{Role: RoleUser, Content: "plain"}
+	if plain.Text() != "plain" {
+		t.Errorf("expected %q, got %q", "plain", plain.Text())
+	}
+
+	multi := Message{Role: RoleUser, Parts: []ContentPart{
+		TextPart("look at "),
+		ImagePart([]byte{1}, "image/png"),
+		TextPart("this"),
+	}}
+	if multi.Text() != "look at this" {
+		t.Errorf("expected %q, got %q", "look at this", multi.Text())
+	}
+}
+
+func TestCompletionRequest_ValidateFor(t *testing.T) {
+	desc := ModelDescription{
+		Model:            "vision",
+		Provider:         "test",
+		MaxContextTokens: 1024,
+		AcceptsFileTypes: []string{"image/png", "image/jpeg"},
+	}
+
+	req := CompletionRequest{Messages: []Message{{
+		Role:  RoleUser,
+		Parts: []ContentPart{TextPart("what is this?"), ImagePart([]byte{1}, "image/png")},
+	}}}
+	if err := req.ValidateFor(desc); err != nil {
+		t.Fatalf("expected accepted image to validate, got %v", err)
+	}
+
+	req.Messages[0].Parts = append(req.Messages[0].Parts, FilePart([]byte("%PDF"), "application/pdf", "doc.pdf"))
+	err := req.ValidateFor(desc)
+	if !errors.Is(err, ErrUnsupportedContent) {
+		t.Fatalf("expected ErrUnsupportedContent, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "application/pdf") {
+		t.Errorf("expected error to name the rejected MIME type, got %v", err)
+	}
+
+	textOnly := ModelDescription{Model: "text", Provider: "test", MaxContextTokens: 1024}
+	req.Messages[0].Parts = []ContentPart{TextPart("only text")}
+	if err := req.ValidateFor(textOnly); err != nil {
+		t.Fatalf("expected text parts to be accepted by text-only models, got %v", err)
+	}
+}
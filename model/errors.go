@@ -1,7 +1,15 @@
 // Package model provides interfaces and types for interacting with language models.
 package model
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 // Common model-related errors.
 var (
@@ -28,6 +36,106 @@ var (
 
 	// ErrInvalidToolCall is returned when a tool call is invalid.
 	ErrInvalidToolCall = errors.New("invalid tool call")
+
+	// ErrUnsupportedContent is returned when a message contains content
+	// (e.g. an image MIME type) the model does not accept.
+	ErrUnsupportedContent = errors.New("unsupported content")
+
+	// ErrInvalidResponse is returned when a response does not match the
+	// requested ResponseFormat.
+	ErrInvalidResponse = errors.New("invalid model response")
 )
 
+// ProviderError describes a failed provider API call in a provider-agnostic
+// way, so that middleware such as WithRetry can decide how to react.
+type ProviderError struct {
+	// Provider is the name of the provider that returned the error.
+	Provider string
+	// StatusCode is the HTTP status code of the failed call, if any.
+	StatusCode int
+	// RetryAfter is how long the provider asked us to wait before
+	// retrying, or 0 if it did not say.
+	RetryAfter time.Duration
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: status %d: %v", e.Provider, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports a 429 as ErrRateLimitExceeded.
+func (e *ProviderError) Is(target error) bool {
+	return target == ErrRateLimitExceeded && e.StatusCode == http.StatusTooManyRequests
+}
+
+// Retryable returns true for transient failures: timeouts, conflicts,
+// rate limiting and server side errors.
+func (e *ProviderError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode == http.StatusConflict,
+		e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode >= 500:
+		return true
+	case e.StatusCode == 0:
+		// No response at all; retry transport failures
+		return IsRetryable(e.Err)
+	}
+	return false
+}
+
+// IsRetryable classifies err as transient (worth retrying) or permanent.
+// Cancellation and deadlines are never retried; rate limiting, retryable
+// ProviderErrors and network timeouts are.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Retryable()
+	}
+	if errors.Is(err, ErrRateLimitExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RetryAfter returns the wait requested by the provider for err, or 0.
+func RetryAfter(err error) time.Duration {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.RetryAfter
+	}
+	return 0
+}
 
+// ParseRetryAfter parses a Retry-After header value, given either in
+// seconds or as an HTTP date. It returns 0 if the value is missing or
+// malformed.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// Middleware wraps a Model to add behaviour around its calls, such as
+// retries or rate limiting.
+type Middleware func(next Model) Model
+
+// Chain wraps m with the given middleware. The first middleware is the
+// outermost, so Chain(m, WithRetry(r), WithRateLimit(l)) retries calls
+// that are each individually rate limited.
+func Chain(m Model, middleware ...Middleware) Model {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		m = middleware[i](m)
+	}
+	return m
+}
+
+// Unwrapper is implemented by models that wrap another model.
+type Unwrapper interface {
+	Unwrap() Model
+}
+
+// completeFunc and streamFunc match Model.Complete and
+// StreamingModel.CompleteStream respectively.
+type completeFunc func(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error)
+type streamFunc func(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error)
+
+// wrappedModel is the Model returned by middleware. It always implements
+// StreamingModel; when the wrapped model cannot stream, Stream falls back
+// to its blocking Complete.
+type wrappedModel struct {
+	next     Model
+	complete completeFunc
+	stream   streamFunc
+}
+
+// Ensure wrappedModel supports streaming.
+var _ StreamingModel = (*wrappedModel)(nil)
+
+func (w *wrappedModel) Description() ModelDescription {
+	return w.next.Description()
+}
+
+func (w *wrappedModel) Complete(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+	return w.complete(ctx, request)
+}
+
+func (w *wrappedModel) CompleteStream(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error) {
+	return w.stream(ctx, request)
+}
+
+// Unwrap returns the wrapped model.
+func (w *wrappedModel) Unwrap() Model {
+	return w.next
+}
@@ -0,0 +1,254 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// flakyModel fails with the queued errors before succeeding.
+type flakyModel struct {
+	blockingModel
+	failures []error
+	calls    int
+}
+
+func (m *flakyModel) Complete(ctx *tool.Context, req CompletionRequest) (CompletionResponse, error) {
+	m.calls++
+	if len(m.failures) > 0 {
+		err := m.failures[0]
+		m.failures = m.failures[1:]
+		return CompletionResponse{}, err
+	}
+	return m.resp, nil
+}
+
+func fastRetry(maxRetries int) RetryConfig {
+	return RetryConfig{MaxRetries: maxRetries, InitialBackoff: time.Millisecond, Multiplier: 2}
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next Model) Model {
+			return &wrappedModel{
+				next: next,
+				complete: func(ctx *tool.Context, req CompletionRequest) (CompletionResponse, error) {
+					order = append(order, name)
+					return next.Complete(ctx, req)
+				},
+			}
+		}
+	}
+
+	m := Chain(&blockingModel{}, tag("outer"), tag("inner"))
+	if _, err := m.Complete(nil, CompletionRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Fatalf("expected [outer inner], got %v", order)
+	}
+	if m.Description().Model != "blocking" {
+		t.Errorf("expected description of the wrapped model, got %+v", m.Description())
+	}
+	if _, ok := m.(Unwrapper); !ok {
+		t.Errorf("expected middleware models to be unwrappable")
+	}
+}
+
+func TestWithRetry_RetriesTransientErrors(t *testing.T) {
+	inner := &flakyModel{
+		blockingModel: blockingModel{resp: CompletionResponse{Text: "ok"}},
+		failures: []error{
+			&ProviderError{Provider: "test", StatusCode: http.StatusServiceUnavailable, Err: errors.New("down")},
+			&ProviderError{Provider: "test", StatusCode: http.StatusTooManyRequests, Err: errors.New("slow down")},
+		},
+	}
+	m := WithRetry(fastRetry(3))(inner)
+
+	ctx := tool.NewContext(context.Background())
+	resp, err := m.Complete(ctx, CompletionRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Text != "ok" || inner.calls != 3 {
+		t.Fatalf("expected success on third call, got %q after %d calls", resp.Text, inner.calls)
+	}
+	if retries := ctx.Stats().GetCount(StatModelRetries); retries == nil || *retries != 2 {
+		t.Errorf("expected 2 retries recorded, got %v", retries)
+	}
+}
+
+func TestWithRetry_StopsOnPermanentErrors(t *testing.T) {
+	inner := &flakyModel{failures: []error{
+		&ProviderError{Provider: "test", StatusCode: http.StatusBadRequest, Err: errors.New("bad")},
+	}}
+	m := WithRetry(fastRetry(3))(inner)
+
+	if _, err := m.Complete(nil, CompletionRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected a single attempt, got %d", inner.calls)
+	}
+}
+
+func TestWithRetry_GivesUpAfterMaxRetries(t *testing.T) {
+	transient := &ProviderError{Provider: "test", StatusCode: http.StatusBadGateway, Err: errors.New("bad gateway")}
+	inner := &flakyModel{failures: []error{transient, transient, transient, transient}}
+	m := WithRetry(fastRetry(2))(inner)
+
+	if _, err := m.Complete(nil, CompletionRequest{}); !errors.Is(err, transient) {
+		t.Fatalf("expected last error to be returned, got %v", err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", inner.calls)
+	}
+}
+
+func TestWithRetry_HonorsRetryAfter(t *testing.T) {
+	inner := &flakyModel{failures: []error{
+		&ProviderError{Provider: "test", StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Millisecond, Err: errors.New("slow down")},
+	}}
+	config := fastRetry(1)
+	config.InitialBackoff = time.Hour
+	m := WithRetry(config)(inner)
+
+	start := time.Now()
+	if _, err := m.Complete(nil, CompletionRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected to wait for Retry-After, waited %s", elapsed)
+	}
+}
+
+func TestWithRetry_StopsWhenContextDone(t *testing.T) {
+	inner := &flakyModel{failures: []error{ErrRateLimitExceeded}}
+	config := fastRetry(1)
+	config.InitialBackoff = time.Hour
+	m := WithRetry(config)(inner)
+
+	parent, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := m.Complete(tool.NewContext(parent), CompletionRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWithRetry_RetriesStreamsFailingUpFront(t *testing.T) {
+	final := CompletionResponse{Text: "ok"}
+	attempts := 0
+	inner := &wrappedModel{
+		next: &blockingModel{},
+		stream: func(ctx *tool.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+			attempts++
+			ch := make(chan StreamEvent, 2)
+			if attempts == 1 {
+				ch <- StreamEvent{Type: StreamEventError, Err: ErrRateLimitExceeded}
+			} else {
+				ch <- StreamEvent{Type: StreamEventText, Text: "ok"}
+				ch <- StreamEvent{Type: StreamEventDone, Response: &final}
+			}
+			close(ch)
+			return ch, nil
+		},
+	}
+	m := WithRetry(fastRetry(2))(inner)
+
+	events, err := Stream(nil, m, CompletionRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var text string
+	resp, err := CollectStream(events, func(e StreamEvent) { text += e.Text })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 2 || text != "ok" || resp.Text != "ok" {
+		t.Fatalf("expected retried stream, got %d attempts, text %q", attempts, text)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	now := time.Now()
+	l := &rateLimiter{config: RateLimitConfig{RequestsPerMinute: 2, TokensPerMinute: 100}}
+	l.entries = []*rateEntry{
+		{at: now.Add(-70 * time.Second), tokens: 1000}, // outside the window
+		{at: now.Add(-50 * time.Second), tokens: 40},
+		{at: now.Add(-20 * time.Second), tokens: 40},
+	}
+
+	// Two calls within the window hit the request limit; the oldest
+	// expires in 10s.
+	if wait := l.wait(now, 1); wait != 10*time.Second {
+		t.Errorf("expected 10s wait for request limit, got %s", wait)
+	}
+	if len(l.entries) != 2 {
+		t.Errorf("expected expired entries to be pruned, got %d", len(l.entries))
+	}
+
+	// Token limit: 80 used, so 70 more needs both entries to expire.
+	l.config.RequestsPerMinute = 0
+	if wait := l.wait(now, 70); wait != 40*time.Second {
+		t.Errorf("expected 40s wait for token limit, got %s", wait)
+	}
+	if wait := l.wait(now, 20); wait != 0 {
+		t.Errorf("expected no wait within the token limit, got %s", wait)
+	}
+}
+
+func TestWithRateLimit_DelaysUntilContextDone(t *testing.T) {
+	inner := &blockingModel{resp: CompletionResponse{Text: "ok", UsageStats: UsageStats{InputTokens: 5}}}
+	m := WithRateLimit(RateLimitConfig{RequestsPerMinute: 1})(inner)
+
+	if _, err := m.Complete(nil, CompletionRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parent, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ctx := tool.NewContext(parent)
+	if _, err := m.Complete(ctx, CompletionRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the second call to wait for the window, got %v", err)
+	}
+	if limited := ctx.Stats().GetCount(StatModelRateLimited); limited == nil || *limited != 1 {
+		t.Errorf("expected rate limited call to be recorded, got %v", limited)
+	}
+}
+
+func TestProviderError_Classification(t *testing.T) {
+	rateLimited := &ProviderError{Provider: "test", StatusCode: http.StatusTooManyRequests, Err: errors.New("x")}
+	if !errors.Is(rateLimited, ErrRateLimitExceeded) {
+		t.Error("expected 429 to match ErrRateLimitExceeded")
+	}
+	if !IsRetryable(rateLimited) {
+		t.Error("expected 429 to be retryable")
+	}
+	if IsRetryable(&ProviderError{Provider: "test", StatusCode: http.StatusUnauthorized, Err: errors.New("x")}) {
+		t.Error("expected 401 to be permanent")
+	}
+	if IsRetryable(context.Canceled) {
+		t.Error("expected cancellation to be permanent")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := ParseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("expected 3s, got %s", got)
+	}
+	if got := ParseRetryAfter(""); got != 0 {
+		t.Errorf("expected 0 for empty header, got %s", got)
+	}
+	if got := ParseRetryAfter("soon"); got != 0 {
+		t.Errorf("expected 0 for malformed header, got %s", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := ParseRetryAfter(date); got < 58*time.Minute || got > time.Hour {
+		t.Errorf("expected about an hour for HTTP date, got %s", got)
+	}
+}
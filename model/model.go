@@ -3,6 +3,8 @@ package model
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/hlfshell/gotonomy/tool"
 )
@@ -26,8 +28,8 @@ type ModelDescription struct {
 	Description      string        `json:"description" yaml:"description"`
 	Costs            CostsPerToken `json:"costs" yaml:"costs"`
 	// CanUseTools indicates whether the model can use tools/functions
-	// If not, you need to use a ReAct wrapper (ToolExtractor- todo) or equivalent to add
-	// it to the model.
+	// If not, you need to wrap the model with WithReAct or equivalent to
+	// add it to the model.
 	CanUseTools bool `json:"can_use_tools" yaml:"can_use_tools"`
 	// AcceptsFileTypes indicates what, if any, file / media types can
 	// be accepted by the model. Common MIME types include:
@@ -37,6 +39,10 @@ type ModelDescription struct {
 	//   - "image/webp" for WebP images
 	// If empty, the model only accepts text input.
 	AcceptsFileTypes []string `json:"accepts_file_types" yaml:"accepts_file_types"`
+	// StructuredOutput indicates whether the provider can constrain the
+	// model's output to a JSON schema natively (see ResponseFormat); if
+	// not, WithStructuredOutput falls back to prompting and checking.
+	StructuredOutput bool `json:"structured_output" yaml:"structured_output"`
 }
 
 // Validate validates the model description.
@@ -68,11 +74,32 @@ func (c CostsPerToken) Cost(input, output int) float64 {
 type Message struct {
 	Role    Role   `json:"role"`
 	Content string `json:"content"`
+	// Parts holds multimodal content (text, images, files). When set,
+	// providers send the parts in order instead of Content.
+	Parts []ContentPart `json:"parts,omitempty"`
+	// For assistant messages, the tools the model called; each call's
+	// result follows in a tool role message carrying its ID.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 	// For tool role messages, the ID of the tool call this
 	// is a response to; blank otherwise
 	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
+// Text returns the textual content of the message; for multimodal
+// messages this is the concatenation of its text parts.
+func (m Message) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var sb strings.Builder
+	for _, part := range m.Parts {
+		if part.Type == PartText {
+			sb.WriteString(part.Text)
+		}
+	}
+	return sb.String()
+}
+
 // Validate validates the message by ensuring that the role
 // matches one we'd expect.
 // TODO - Do we need this?
@@ -86,14 +113,43 @@ func (m Message) Validate() error {
 	default:
 		return fmt.Errorf("%w: invalid role %q", ErrInvalidMessage, m.Role)
 	}
+	if len(m.ToolCalls) > 0 && m.Role != RoleAssistant {
+		return fmt.Errorf("%w: only assistant messages may carry tool calls", ErrInvalidMessage)
+	}
+	for i, part := range m.Parts {
+		if err := part.Validate(); err != nil {
+			return fmt.Errorf("part %d: %w", i, err)
+		}
+	}
 	return nil
 }
 
 // ModelConfig contains configuration for model completion requests.
+// Zero values leave the provider's defaults in place.
 type ModelConfig struct {
 	// Temperature controls randomness in the response (0.0 to 1.0).
 	// Higher values make output more random, lower values more deterministic.
 	Temperature float32 `json:"temperature"`
+	// MaxTokens caps the number of tokens generated in the response.
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// TopP restricts sampling to the most likely tokens whose probabilities
+	// add up to TopP (0.0 to 1.0).
+	TopP float32 `json:"top_p,omitempty"`
+	// Stop lists sequences that end generation when produced.
+	Stop []string `json:"stop,omitempty"`
+	// Seed requests deterministic sampling where the provider supports it.
+	Seed *int64 `json:"seed,omitempty"`
+	// PresencePenalty and FrequencyPenalty (-2.0 to 2.0) discourage
+	// repeating tokens that have appeared at all, or often, respectively.
+	PresencePenalty  float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float32 `json:"frequency_penalty,omitempty"`
+	// ToolChoice controls whether and which tools the model calls.
+	ToolChoice ToolChoice `json:"tool_choice,omitempty"`
+	// ParallelToolCalls allows or forbids several tool calls in one
+	// response; nil leaves it to the provider.
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+	// ResponseFormat constrains the shape of the response text.
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
 }
 
 // Validate validates the model configuration.
@@ -101,6 +157,90 @@ func (c ModelConfig) Validate() error {
 	if c.Temperature < 0.0 || c.Temperature > 1.0 {
 		return fmt.Errorf("%w: temperature must be between 0.0 and 1.0, got %f", ErrInvalidConfig, c.Temperature)
 	}
+	if c.MaxTokens < 0 {
+		return fmt.Errorf("%w: max tokens must not be negative, got %d", ErrInvalidConfig, c.MaxTokens)
+	}
+	if c.TopP < 0.0 || c.TopP > 1.0 {
+		return fmt.Errorf("%w: top_p must be between 0.0 and 1.0, got %f", ErrInvalidConfig, c.TopP)
+	}
+	for _, stop := range c.Stop {
+		if stop == "" {
+			return fmt.Errorf("%w: stop sequences must not be empty", ErrInvalidConfig)
+		}
+	}
+	if c.PresencePenalty < -2.0 || c.PresencePenalty > 2.0 {
+		return fmt.Errorf("%w: presence penalty must be between -2.0 and 2.0, got %f", ErrInvalidConfig, c.PresencePenalty)
+	}
+	if c.FrequencyPenalty < -2.0 || c.FrequencyPenalty > 2.0 {
+		return fmt.Errorf("%w: frequency penalty must be between -2.0 and 2.0, got %f", ErrInvalidConfig, c.FrequencyPenalty)
+	}
+	if c.ResponseFormat != nil {
+		if err := c.ResponseFormat.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToolChoice controls tool use for a request: one of the ToolChoice
+// constants, or the name of a tool the model must call. Empty leaves the
+// choice to the provider, which is usually ToolChoiceAuto.
+type ToolChoice string
+
+const (
+	// ToolChoiceAuto lets the model decide whether to call tools.
+	ToolChoiceAuto ToolChoice = "auto"
+	// ToolChoiceNone forbids tool calls.
+	ToolChoiceNone ToolChoice = "none"
+	// ToolChoiceRequired makes the model call at least one tool.
+	ToolChoiceRequired ToolChoice = "required"
+)
+
+// Tool returns the name of the specific tool the model must call, or ""
+// if the choice is one of the ToolChoice constants or unset.
+func (c ToolChoice) Tool() string {
+	switch c {
+	case "", ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		return ""
+	}
+	return string(c)
+}
+
+// ResponseFormatType is the kind of response a ResponseFormat asks for.
+type ResponseFormatType string
+
+const (
+	// ResponseFormatText is unconstrained text, the default.
+	ResponseFormatText ResponseFormatType = "text"
+	// ResponseFormatJSON is any valid JSON object.
+	ResponseFormatJSON ResponseFormatType = "json_object"
+	// ResponseFormatJSONSchema is JSON conforming to Schema.
+	ResponseFormatJSONSchema ResponseFormatType = "json_schema"
+)
+
+// ResponseFormat constrains the response text to JSON, optionally
+// matching a JSON schema.
+type ResponseFormat struct {
+	Type ResponseFormatType `json:"type"`
+	// Name identifies the schema to the provider; ResponseFormatJSONSchema only.
+	Name string `json:"name,omitempty"`
+	// Schema is the JSON schema of the response; ResponseFormatJSONSchema only.
+	Schema map[string]any `json:"schema,omitempty"`
+	// Strict asks the provider to enforce Schema exactly where supported.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// Validate validates the response format.
+func (f ResponseFormat) Validate() error {
+	switch f.Type {
+	case ResponseFormatText, ResponseFormatJSON:
+	case ResponseFormatJSONSchema:
+		if f.Name == "" || len(f.Schema) == 0 {
+			return fmt.Errorf("%w: json_schema response format requires a name and schema", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown response format type %q", ErrInvalidConfig, f.Type)
+	}
 	return nil
 }
 
@@ -109,6 +249,9 @@ type CompletionRequest struct {
 	Messages []Message   `json:"messages"`
 	Tools    []tool.Tool `json:"tools"`
 	Config   ModelConfig `json:"config"`
+	// Cache controls how WithCache treats this request; it is not part
+	// of the request's cache key.
+	Cache CacheMode `json:"cache,omitempty"`
 }
 
 // Validate validates the completion request.
@@ -124,6 +267,34 @@ func (r CompletionRequest) Validate() error {
 	if err := r.Config.Validate(); err != nil {
 		return fmt.Errorf("config: %w", err)
 	}
+	if r.Config.ToolChoice == ToolChoiceRequired && len(r.Tools) == 0 {
+		return fmt.Errorf("%w: tool choice %q requires tools", ErrInvalidRequest, r.Config.ToolChoice)
+	}
+	if name := r.Config.ToolChoice.Tool(); name != "" {
+		if !slices.ContainsFunc(r.Tools, func(t tool.Tool) bool { return t.Name() == name }) {
+			return fmt.Errorf("%w: tool choice %q is not among the request's tools", ErrInvalidRequest, name)
+		}
+	}
+	return nil
+}
+
+// ValidateFor validates the request and additionally ensures every
+// non-text content part has a MIME type the given model accepts, as
+// advertised by its AcceptsFileTypes.
+func (r CompletionRequest) ValidateFor(desc ModelDescription) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	for i, msg := range r.Messages {
+		for j, part := range msg.Parts {
+			if part.Type == PartText {
+				continue
+			}
+			if !desc.Accepts(part.MIMEType) {
+				return fmt.Errorf("message %d part %d: %w: %s does not accept %q", i, j, ErrUnsupportedContent, desc.Model, part.MIMEType)
+			}
+		}
+	}
 	return nil
 }
 
@@ -140,6 +311,9 @@ type CompletionResponse struct {
 	Text       string     `json:"text"`
 	ToolCalls  []ToolCall `json:"tool_calls"`
 	UsageStats UsageStats `json:"usage_stats"`
+	// Model is the name of the model that produced the response, which
+	// may differ from the one called when routing between models.
+	Model string `json:"model,omitempty"`
 }
 
 // UsageStats contains token usage statistics for a model request.
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func TestModelConfig_Validate(t *testing.T) {
+	seed := int64(7)
+	valid := ModelConfig{
+		Temperature:      0.5,
+		MaxTokens:        100,
+		TopP:             0.9,
+		Stop:             []string{"\n\n"},
+		Seed:             &seed,
+		PresencePenalty:  -1,
+		FrequencyPenalty: 1.5,
+		ToolChoice:       ToolChoiceAuto,
+		ResponseFormat: &ResponseFormat{
+			Type:   ResponseFormatJSONSchema,
+			Name:   "answer",
+			Schema: map[string]any{"type": "object"},
+		},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := map[string]ModelConfig{
+		"temperature":       {Temperature: 1.5},
+		"max tokens":        {MaxTokens: -1},
+		"top p":             {TopP: 1.1},
+		"empty stop":        {Stop: []string{""}},
+		"presence penalty":  {PresencePenalty: 2.5},
+		"frequency penalty": {FrequencyPenalty: -3},
+		"format type":       {ResponseFormat: &ResponseFormat{Type: "xml"}},
+		"schema missing":    {ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONSchema, Name: "answer"}},
+	}
+	for name, config := range invalid {
+		if err := config.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+}
+
+func TestCompletionRequest_ValidateToolChoice(t *testing.T) {
+	lookup := tool.NewTool[string]("lookup", "Looks things up", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+
+	tests := []struct {
+		name   string
+		tools  []tool.Tool
+		choice ToolChoice
+		valid  bool
+	}{
+		{"auto without tools", nil, ToolChoiceAuto, true},
+		{"required without tools", nil, ToolChoiceRequired, false},
+		{"required with tools", []tool.Tool{lookup}, ToolChoiceRequired, true},
+		{"named tool", []tool.Tool{lookup}, "lookup", true},
+		{"unknown tool", []tool.Tool{lookup}, "search", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CompletionRequest{
+				Messages: messages,
+				Tools:    tt.tools,
+				Config:   ModelConfig{ToolChoice: tt.choice},
+			}.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("expected ErrInvalidRequest, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package modeltest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// RecordEnv names the environment variable that, when set, makes Open
+// record cassettes anew from the live model even if they already exist.
+const RecordEnv = "GOTONOMY_RECORD"
+
+// Cassette is a recording of a model's responses to a series of
+// requests, saved as JSON.
+type Cassette struct {
+	// Model describes the recorded model; a Replayer reports it too.
+	Model        model.ModelDescription `json:"model"`
+	Interactions []Interaction          `json:"interactions"`
+}
+
+// Interaction is one recorded request and the model's reply to it.
+type Interaction struct {
+	// Key identifies the request, as computed by model.RequestKey.
+	Key      string                    `json:"key"`
+	Request  RecordedRequest           `json:"request"`
+	Response *model.CompletionResponse `json:"response,omitempty"`
+	// Error is the error the model returned, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// RecordedRequest is the readable part of a recorded request, kept so
+// that cassettes can be reviewed; only the Key is used for matching.
+type RecordedRequest struct {
+	Messages []model.Message   `json:"messages"`
+	Tools    []string          `json:"tools,omitempty"`
+	Config   model.ModelConfig `json:"config"`
+}
+
+// LoadCassette reads the cassette at path.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to path, creating its directory if needed.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Recorder wraps a model, recording every request sent through it and
+// the model's reply into a Cassette.
+type Recorder struct {
+	next     model.Model
+	cassette Cassette
+	mu       sync.Mutex
+}
+
+var _ model.Model = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder wrapping next.
+func NewRecorder(next model.Model) *Recorder {
+	return &Recorder{
+		next:     next,
+		cassette: Cassette{Model: next.Description()},
+	}
+}
+
+func (r *Recorder) Description() model.ModelDescription {
+	return r.next.Description()
+}
+
+func (r *Recorder) Complete(ctx *tool.Context, request model.CompletionRequest) (model.CompletionResponse, error) {
+	key, err := model.RequestKey(r.cassette.Model, request)
+	if err != nil {
+		return model.CompletionResponse{}, err
+	}
+	resp, err := r.next.Complete(ctx, request)
+
+	interaction := Interaction{Key: key, Request: recordRequest(request)}
+	if err != nil {
+		interaction.Error = err.Error()
+	} else {
+		interaction.Response = &resp
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+	return resp, err
+}
+
+// Cassette returns a copy of what has been recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cassette := r.cassette
+	cassette.Interactions = append([]Interaction(nil), r.cassette.Interactions...)
+	return &cassette
+}
+
+// Save writes what has been recorded so far to path.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().Save(path)
+}
+
+func recordRequest(request model.CompletionRequest) RecordedRequest {
+	recorded := RecordedRequest{Messages: request.Messages, Config: request.Config}
+	for _, t := range request.Tools {
+		recorded.Tools = append(recorded.Tools, t.Name())
+	}
+	return recorded
+}
+
+// Replayer is a model.Model answering requests from a Cassette, offline.
+// Requests are matched to interactions by key; when a request was
+// recorded more than once, its replies are replayed in order and the last
+// one repeats. A request the cassette does not hold fails with
+// ErrUnexpectedRequest.
+type Replayer struct {
+	cassette     *Cassette
+	interactions map[string][]Interaction
+	replayed     map[string]int
+	mu           sync.Mutex
+}
+
+var _ model.Model = (*Replayer)(nil)
+
+// NewReplayer returns a Replayer for cassette.
+func NewReplayer(cassette *Cassette) *Replayer {
+	r := &Replayer{
+		cassette:     cassette,
+		interactions: make(map[string][]Interaction),
+		replayed:     make(map[string]int),
+	}
+	for _, interaction := range cassette.Interactions {
+		r.interactions[interaction.Key] = append(r.interactions[interaction.Key], interaction)
+	}
+	return r
+}
+
+func (r *Replayer) Description() model.ModelDescription {
+	return r.cassette.Model
+}
+
+func (r *Replayer) Complete(ctx *tool.Context, request model.CompletionRequest) (model.CompletionResponse, error) {
+	key, err := model.RequestKey(r.cassette.Model, request)
+	if err != nil {
+		return model.CompletionResponse{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorded := r.interactions[key]
+	if len(recorded) == 0 {
+		return model.CompletionResponse{}, fmt.Errorf("%w: the cassette has no recording of this request (%s); record it again with %s set", ErrUnexpectedRequest, describeRequest(request), RecordEnv)
+	}
+	interaction := recorded[min(r.replayed[key], len(recorded)-1)]
+	r.replayed[key]++
+
+	if interaction.Error != "" {
+		return model.CompletionResponse{}, errors.New(interaction.Error)
+	}
+	return *interaction.Response, nil
+}
+
+// Open returns a model for a test backed by the cassette at path. If the
+// cassette exists it is replayed, offline. Otherwise, or if RecordEnv is
+// set, the model returned by live is recorded instead and the cassette is
+// saved when the test ends; if live is nil or returns nil (e.g. for want
+// of an API key), the test is skipped.
+func Open(t testing.TB, path string, live func() model.Model) model.Model {
+	t.Helper()
+
+	_, err := os.Stat(path)
+	if os.Getenv(RecordEnv) == "" && !errors.Is(err, fs.ErrNotExist) {
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			t.Fatalf("modeltest: %v", err)
+		}
+		return NewReplayer(cassette)
+	}
+
+	var m model.Model
+	if live != nil {
+		m = live()
+	}
+	if m == nil {
+		t.Skipf("modeltest: no cassette at %s and no live model to record one", path)
+	}
+	recorder := NewRecorder(m)
+	t.Cleanup(func() {
+		if err := recorder.Save(path); err != nil {
+			t.Errorf("modeltest: saving cassette: %v", err)
+		}
+	})
+	return recorder
+}
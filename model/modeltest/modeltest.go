@@ -0,0 +1,227 @@
+// Package modeltest provides model.Model implementations for tests: a
+// Scripted model answering from a queue of responses or matcher rules,
+// and a Recorder and Replayer that capture a real model's responses in a
+// cassette file and play them back offline.
+package modeltest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// ErrUnexpectedRequest is returned when a Scripted or Replayer model has
+// no response for a request.
+var ErrUnexpectedRequest = errors.New("unexpected model request")
+
+// DefaultDescription describes Scripted models unless overridden.
+var DefaultDescription = model.ModelDescription{
+	Model:            "scripted",
+	Provider:         "modeltest",
+	MaxContextTokens: 128000,
+	Description:      "Scripted model for tests",
+	CanUseTools:      true,
+}
+
+// Matcher selects the requests a Scripted rule applies to.
+type Matcher func(request model.CompletionRequest) bool
+
+// Any matches every request.
+func Any() Matcher {
+	return func(request model.CompletionRequest) bool { return true }
+}
+
+// LastMessageContains matches requests whose last message contains text.
+func LastMessageContains(text string) Matcher {
+	return func(request model.CompletionRequest) bool {
+		if len(request.Messages) == 0 {
+			return false
+		}
+		return strings.Contains(request.Messages[len(request.Messages)-1].Text(), text)
+	}
+}
+
+// AnyMessageContains matches requests with a message containing text.
+func AnyMessageContains(text string) Matcher {
+	return func(request model.CompletionRequest) bool {
+		for _, msg := range request.Messages {
+			if strings.Contains(msg.Text(), text) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasTool matches requests offering the named tool.
+func HasTool(name string) Matcher {
+	return func(request model.CompletionRequest) bool {
+		for _, t := range request.Tools {
+			if t.Name() == name {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// reply is a scripted response or error.
+type reply struct {
+	resp model.CompletionResponse
+	err  error
+}
+
+type rule struct {
+	match Matcher
+	reply reply
+}
+
+// Scripted is a model.Model answering requests from a script. Each
+// request is answered by the first rule matching it, if any, and
+// otherwise by the next reply in the queue. A request with neither fails
+// with ErrUnexpectedRequest. Every request is recorded. It is safe for
+// concurrent use.
+type Scripted struct {
+	desc     model.ModelDescription
+	queue    []reply
+	rules    []rule
+	requests []model.CompletionRequest
+	mu       sync.Mutex
+}
+
+var _ model.Model = (*Scripted)(nil)
+
+// NewScripted returns a Scripted model that answers with responses in
+// order.
+func NewScripted(responses ...model.CompletionResponse) *Scripted {
+	s := &Scripted{desc: DefaultDescription}
+	return s.Respond(responses...)
+}
+
+// NewScriptedText returns a Scripted model that answers with each text
+// in order.
+func NewScriptedText(texts ...string) *Scripted {
+	s := &Scripted{desc: DefaultDescription}
+	for _, text := range texts {
+		s.Respond(model.CompletionResponse{Text: text})
+	}
+	return s
+}
+
+// WithDescription sets the description the model reports.
+func (s *Scripted) WithDescription(desc model.ModelDescription) *Scripted {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.desc = desc
+	return s
+}
+
+// Respond queues responses.
+func (s *Scripted) Respond(responses ...model.CompletionResponse) *Scripted {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, resp := range responses {
+		s.queue = append(s.queue, reply{resp: resp})
+	}
+	return s
+}
+
+// RespondText queues a response with the given text.
+func (s *Scripted) RespondText(text string) *Scripted {
+	return s.Respond(model.CompletionResponse{Text: text})
+}
+
+// CallTool queues a response calling the named tool with args.
+func (s *Scripted) CallTool(name string, args tool.Arguments) *Scripted {
+	return s.Respond(model.CompletionResponse{
+		ToolCalls: []model.ToolCall{{Name: name, Arguments: args}},
+	})
+}
+
+// Fail queues an error.
+func (s *Scripted) Fail(err error) *Scripted {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, reply{err: err})
+	return s
+}
+
+// When adds a rule answering every request matching match with resp,
+// ahead of the queue. Rules are tried in the order they were added.
+func (s *Scripted) When(match Matcher, resp model.CompletionResponse) *Scripted {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{match: match, reply: reply{resp: resp}})
+	return s
+}
+
+// WhenFail adds a rule failing every request matching match with err.
+func (s *Scripted) WhenFail(match Matcher, err error) *Scripted {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{match: match, reply: reply{err: err}})
+	return s
+}
+
+func (s *Scripted) Description() model.ModelDescription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.desc
+}
+
+func (s *Scripted) Complete(ctx *tool.Context, request model.CompletionRequest) (model.CompletionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+
+	for _, r := range s.rules {
+		if r.match(request) {
+			return r.reply.resp, r.reply.err
+		}
+	}
+	if len(s.queue) == 0 {
+		return model.CompletionResponse{}, fmt.Errorf("%w: request %d (%s) has no scripted response", ErrUnexpectedRequest, len(s.requests), describeRequest(request))
+	}
+	next := s.queue[0]
+	s.queue = s.queue[1:]
+	return next.resp, next.err
+}
+
+// Requests returns the requests the model has been sent, in order.
+func (s *Scripted) Requests() []model.CompletionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]model.CompletionRequest(nil), s.requests...)
+}
+
+// Calls returns the number of requests the model has been sent.
+func (s *Scripted) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// Remaining returns the number of queued replies not yet used.
+func (s *Scripted) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// describeRequest summarizes a request for error messages by its last
+// message.
+func describeRequest(request model.CompletionRequest) string {
+	if len(request.Messages) == 0 {
+		return "no messages"
+	}
+	last := request.Messages[len(request.Messages)-1]
+	text := last.Text()
+	if len(text) > 80 {
+		text = text[:77] + "..."
+	}
+	return fmt.Sprintf("%d messages, last %s: %q", len(request.Messages), last.Role, text)
+}
@@ -0,0 +1,112 @@
+package modeltest
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func userRequest(text string) model.CompletionRequest {
+	return model.CompletionRequest{Messages: []model.Message{{Role: model.RoleUser, Content: text}}}
+}
+
+func TestScripted(t *testing.T) {
+	boom := errors.New("boom")
+	s := NewScriptedText("first", "second").
+		Fail(boom).
+		When(LastMessageContains("weather"), model.CompletionResponse{Text: "sunny"})
+
+	expect := func(text string, expected string, expectedErr error) {
+		t.Helper()
+		resp, err := s.Complete(nil, userRequest(text))
+		if !errors.Is(err, expectedErr) || resp.Text != expected {
+			t.Errorf("Complete(%q) = %q, %v; expected %q, %v", text, resp.Text, err, expected, expectedErr)
+		}
+	}
+	expect("hi", "first", nil)
+	expect("what's the weather?", "sunny", nil)
+	expect("hi", "second", nil)
+	expect("hi", "", boom)
+	expect("hi", "", ErrUnexpectedRequest)
+	expect("and the weather now?", "sunny", nil)
+
+	if s.Calls() != 6 || s.Requests()[1].Messages[0].Content != "what's the weather?" {
+		t.Errorf("expected every request to be recorded, got %d", s.Calls())
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	echo := tool.NewTool[string]("echo", "echoes", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+	request := model.CompletionRequest{
+		Messages: []model.Message{
+			{Role: model.RoleSystem, Content: "You are helpful."},
+			{Role: model.RoleUser, Content: "hi"},
+		},
+		Tools: []tool.Tool{echo},
+	}
+	if !AnyMessageContains("helpful")(request) || LastMessageContains("helpful")(request) {
+		t.Errorf("unexpected message matching")
+	}
+	if !HasTool("echo")(request) || HasTool("search")(request) {
+		t.Errorf("unexpected tool matching")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	live := NewScriptedText("hello", "again").Fail(errors.New("overloaded"))
+	recorder := NewRecorder(live)
+	recorder.Complete(nil, userRequest("hi"))
+	recorder.Complete(nil, userRequest("hi"))
+	recorder.Complete(nil, userRequest("bye"))
+
+	path := filepath.Join(t.TempDir(), "cassettes", "greeting.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	replayer := NewReplayer(cassette)
+	if replayer.Description().Model != "scripted" {
+		t.Errorf("expected the recorded description, got %+v", replayer.Description())
+	}
+
+	for _, expected := range []string{"hello", "again", "again"} {
+		if resp, err := replayer.Complete(nil, userRequest("hi")); err != nil || resp.Text != expected {
+			t.Errorf("expected %q, got %q, %v", expected, resp.Text, err)
+		}
+	}
+	if _, err := replayer.Complete(nil, userRequest("bye")); err == nil || err.Error() != "overloaded" {
+		t.Errorf("expected the recorded error, got %v", err)
+	}
+	if _, err := replayer.Complete(nil, userRequest("something new")); !errors.Is(err, ErrUnexpectedRequest) {
+		t.Errorf("expected ErrUnexpectedRequest, got %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "open.json")
+	live := NewScriptedText("recorded")
+
+	t.Run("record", func(t *testing.T) {
+		m := Open(t, path, func() model.Model { return live })
+		if resp, err := m.Complete(nil, userRequest("hi")); err != nil || resp.Text != "recorded" {
+			t.Fatalf("unexpected response: %q, %v", resp.Text, err)
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		m := Open(t, path, nil)
+		if resp, err := m.Complete(nil, userRequest("hi")); err != nil || resp.Text != "recorded" {
+			t.Fatalf("unexpected response: %q, %v", resp.Text, err)
+		}
+		if live.Calls() != 1 {
+			t.Errorf("expected the replay not to call the live model")
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// StatModelRateLimited counts the calls WithRateLimit had to delay,
+// recorded on the calling tool.Context's Stats.
+const StatModelRateLimited = "model_rate_limited"
+
+// RateLimitConfig configures the WithRateLimit middleware. Zero values
+// disable the respective limit.
+type RateLimitConfig struct {
+	// RequestsPerMinute caps the number of calls started per minute.
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// TokensPerMinute caps the input and output tokens used per minute.
+	TokensPerMinute int `json:"tokens_per_minute"`
+}
+
+// WithRateLimit returns middleware that delays calls so that no more than
+// the configured requests and tokens are used in any one minute window.
+// Tokens are estimated from the request up front and corrected with the
+// reported usage once the call completes.
+//
+// Every model wrapped by the same returned Middleware shares one set of
+// limits; call WithRateLimit once per model for independent limits.
+func WithRateLimit(config RateLimitConfig) Middleware {
+	limiter := &rateLimiter{config: config}
+	return func(next Model) Model {
+		return &wrappedModel{
+			next: next,
+			complete: func(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+				entry, err := limiter.acquire(ctx, CountRequestTokens(DefaultTokenizer, request))
+				if err != nil {
+					return CompletionResponse{}, err
+				}
+				resp, err := next.Complete(ctx, request)
+				if err == nil {
+					limiter.settle(entry, resp.UsageStats.Total())
+				}
+				return resp, err
+			},
+			stream: func(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error) {
+				entry, err := limiter.acquire(ctx, CountRequestTokens(DefaultTokenizer, request))
+				if err != nil {
+					return nil, err
+				}
+				events, err := Stream(ctx, next, request)
+				if err != nil {
+					return nil, err
+				}
+
+				// Pass events through, settling usage from the final response
+				out := make(chan StreamEvent)
+				go func() {
+					defer close(out)
+					for event := range events {
+						if event.Type == StreamEventDone && event.Response != nil {
+							limiter.settle(entry, event.Response.UsageStats.Total())
+						}
+						out <- event
+					}
+				}()
+				return out, nil
+			},
+		}
+	}
+}
+
+// rateEntry is a single call within the rate limit window.
+type rateEntry struct {
+	at     time.Time
+	tokens int
+}
+
+// rateLimiter tracks calls over a sliding one minute window.
+type rateLimiter struct {
+	config RateLimitConfig
+
+	entries []*rateEntry
+	mu      sync.Mutex
+}
+
+// acquire blocks until a call using tokens fits within the limits, then
+// records it. It returns early if ctx is done.
+func (l *rateLimiter) acquire(ctx *tool.Context, tokens int) (*rateEntry, error) {
+	delayed := false
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		wait := l.wait(now, tokens)
+		if wait <= 0 {
+			entry := &rateEntry{at: now, tokens: tokens}
+			l.entries = append(l.entries, entry)
+			l.mu.Unlock()
+			return entry, nil
+		}
+		l.mu.Unlock()
+
+		if !delayed && ctx != nil {
+			ctx.Stats().Incr(StatModelRateLimited)
+		}
+		delayed = true
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// settle replaces the estimated tokens for entry with the actual usage.
+func (l *rateLimiter) settle(entry *rateEntry, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.tokens = tokens
+}
+
+// wait returns how long until a call using tokens fits within the
+// limits, or 0 if it fits now. The caller must hold l.mu.
+func (l *rateLimiter) wait(now time.Time, tokens int) time.Duration {
+	// Drop entries that have left the window
+	cutoff := now.Add(-time.Minute)
+	kept := l.entries[:0]
+	for _, entry := range l.entries {
+		if entry.at.After(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	l.entries = kept
+
+	var wait time.Duration
+	if rpm := l.config.RequestsPerMinute; rpm > 0 && len(l.entries) >= rpm {
+		// Wait for the oldest call that keeps us at the limit to expire
+		wait = l.entries[len(l.entries)-rpm].at.Sub(cutoff)
+	}
+
+	if tpm := l.config.TokensPerMinute; tpm > 0 {
+		used := 0
+		for _, entry := range l.entries {
+			used += entry.tokens
+		}
+		// Expire calls oldest first until there is room; a call larger
+		// than the whole budget only has to wait for an empty window.
+		for _, entry := range l.entries {
+			if used+tokens <= tpm {
+				break
+			}
+			used -= entry.tokens
+			if expiry := entry.at.Sub(cutoff); expiry > wait {
+				wait = expiry
+			}
+		}
+	}
+	return wait
+}
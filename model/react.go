@@ -0,0 +1,274 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hlfshell/gotonomy/parser"
+	"github.com/hlfshell/gotonomy/tool"
+	structuredparse "github.com/hlfshell/structured-parse/go"
+)
+
+// reactObservation labels tool results in the ReAct protocol.
+const reactObservation = "Observation"
+
+// observationRe finds the first observation a model writes itself,
+// after which its response is hallucinated.
+var observationRe = regexp.MustCompile(`(?im)^\s*` + reactObservation + `\s*:`)
+
+// reactStep is a model response in the ReAct protocol.
+type reactStep struct {
+	Thought     string
+	Actions     []string
+	Inputs      []any
+	FinalAnswer string
+}
+
+// reactParser parses Thought / Action / Action Input / Final Answer
+// labels; an action may be given more than once to call several tools.
+var reactParser = func() parser.Parser[reactStep] {
+	p, err := parser.NewStructuredParse(
+		[]structuredparse.Label{
+			{Name: "Thought"},
+			{Name: "Action"},
+			{Name: "Action Input", IsJSON: true},
+			{Name: "Final Answer"},
+		},
+		func(fields map[string]any) (reactStep, error) {
+			step := reactStep{
+				Thought:     labelString(fields["Thought"]),
+				FinalAnswer: labelString(fields["Final Answer"]),
+			}
+			for _, action := range labelValues(fields["Action"]) {
+				if name, ok := action.(string); ok && name != "" {
+					step.Actions = append(step.Actions, strings.TrimSpace(name))
+				}
+			}
+			step.Inputs = labelValues(fields["Action Input"])
+			return step, nil
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}()
+
+// labelValues returns a parsed label's values; a label given more than
+// once is parsed as a list.
+func labelValues(value any) []any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	case string:
+		if v == "" {
+			return nil
+		}
+	}
+	return []any{value}
+}
+
+func labelString(value any) string {
+	var parts []string
+	for _, v := range labelValues(value) {
+		if s, ok := v.(string); ok {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// reactModel adds tool calling to a model without it.
+type reactModel struct {
+	next Model
+}
+
+// WithReAct returns middleware that adds tool calling to models that lack
+// it (those whose description does not report CanUseTools), using the
+// ReAct text protocol. Requests with tools have the tools and their
+// parameter schemas described in a system message, and tool results are
+// replayed as observations. The model's Thought / Action / Action Input
+// blocks are parsed into ToolCalls, and its Final Answer becomes the
+// response text. Models that can use tools are returned unwrapped, so an
+// agent.Agent works unchanged on either.
+func WithReAct() Middleware {
+	return func(next Model) Model {
+		if next.Description().CanUseTools {
+			return next
+		}
+		return &reactModel{next: next}
+	}
+}
+
+// Description reports the inner model, now able to use tools.
+func (m *reactModel) Description() ModelDescription {
+	desc := m.next.Description()
+	desc.CanUseTools = true
+	return desc
+}
+
+// Unwrap returns the wrapped model.
+func (m *reactModel) Unwrap() Model {
+	return m.next
+}
+
+func (m *reactModel) Complete(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+	if len(request.Tools) == 0 {
+		return m.next.Complete(ctx, request)
+	}
+	if request.Config.ToolChoice == ToolChoiceNone {
+		request.Tools = nil
+		request.Config.ToolChoice = ""
+		return m.next.Complete(ctx, request)
+	}
+
+	resp, err := m.next.Complete(ctx, reactRequest(request))
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	return parseReAct(resp, request.Tools)
+}
+
+// reactRequest rewrites request for a model without tool calling: the
+// tools become instructions and tool results become observations.
+func reactRequest(request CompletionRequest) CompletionRequest {
+	messages := []Message{{Role: RoleSystem, Content: reactInstructions(request.Tools, request.Config.ToolChoice)}}
+	for _, msg := range request.Messages {
+		if len(msg.ToolCalls) > 0 {
+			messages = append(messages, reactActions(msg))
+			continue
+		}
+		if msg.Role != RoleTool && msg.ToolCallID == "" {
+			messages = append(messages, msg)
+			continue
+		}
+		observation := fmt.Sprintf("%s: %s", reactObservation, msg.Text())
+		// Results of the same step are given together
+		if last := len(messages) - 1; messages[last].Role == RoleUser && strings.HasPrefix(messages[last].Content, reactObservation+":") {
+			messages[last].Content += "\n" + observation
+			continue
+		}
+		messages = append(messages, Message{Role: RoleUser, Content: observation})
+	}
+
+	request.Messages = messages
+	request.Tools = nil
+	request.Config.ToolChoice = ""
+	request.Config.ParallelToolCalls = nil
+	// Stop the model from writing the observation itself
+	request.Config.Stop = append(slices.Clip(request.Config.Stop), reactObservation+":")
+	return request
+}
+
+// reactActions replays an assistant message's tool calls as the
+// Action / Action Input blocks the model would have written, unless the
+// message holds its own ReAct text already.
+func reactActions(msg Message) Message {
+	calls := msg.ToolCalls
+	msg.ToolCalls = nil
+	if strings.Contains(msg.Text(), "Action:") {
+		return msg
+	}
+	var sb strings.Builder
+	if text := strings.TrimSpace(msg.Text()); text != "" {
+		fmt.Fprintf(&sb, "Thought: %s\n", text)
+	}
+	for _, call := range calls {
+		input, _ := json.Marshal(call.Arguments)
+		fmt.Fprintf(&sb, "Action: %s\nAction Input: %s\n", call.Name, input)
+	}
+	msg.Content = strings.TrimSpace(sb.String())
+	msg.Parts = nil
+	return msg
+}
+
+// reactInstructions describes the tools and the ReAct protocol.
+func reactInstructions(tools []tool.Tool, choice ToolChoice) string {
+	var sb strings.Builder
+	names := make([]string, 0, len(tools))
+	sb.WriteString("You can use the following tools:\n\n")
+	for _, t := range tools {
+		names = append(names, t.Name())
+		schema, _ := json.Marshal(tool.ParametersToJSONSchema(t.Parameters()))
+		fmt.Fprintf(&sb, "%s: %s\nArguments (JSON schema): %s\n\n", t.Name(), t.Description(), schema)
+	}
+
+	fmt.Fprintf(&sb, `To use a tool, respond in exactly this format:
+
+Thought: your reasoning about what to do next
+Action: the tool to use, one of [%s]
+Action Input: the tool's arguments as a JSON object
+
+You will be given the result as an %s, after which you may use more tools. `, strings.Join(names, ", "), reactObservation)
+	switch name := choice.Tool(); {
+	case name != "":
+		fmt.Fprintf(&sb, "You must use the %s tool now.", name)
+	case choice == ToolChoiceRequired:
+		sb.WriteString("You must use a tool now.")
+	default:
+		sb.WriteString(`Once you know the answer, respond in this format instead:
+
+Thought: your reasoning
+Final Answer: your answer`)
+	}
+	return sb.String()
+}
+
+// parseReAct converts a ReAct response into tool calls, or into the final
+// answer. A response without either label is taken as the answer whole.
+func parseReAct(resp CompletionResponse, tools []tool.Tool) (CompletionResponse, error) {
+	text := resp.Text
+	if loc := observationRe.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+	text = strings.TrimSpace(text)
+	// Parse errors are non-fatal; malformed inputs are handled below
+	step, _ := reactParser.Parse(text)
+
+	if len(step.Actions) == 0 {
+		resp.Text = text
+		if step.FinalAnswer != "" {
+			resp.Text = step.FinalAnswer
+		}
+		return resp, nil
+	}
+
+	calls := make([]ToolCall, 0, len(step.Actions))
+	for i, name := range step.Actions {
+		var input any
+		if i < len(step.Inputs) {
+			input = step.Inputs[i]
+		}
+		args, err := reactArguments(name, input, tools)
+		if err != nil {
+			return CompletionResponse{}, err
+		}
+		calls = append(calls, ToolCall{Name: name, Arguments: args})
+	}
+	// The text is kept so the model sees its own reasoning replayed
+	resp.Text = text
+	resp.ToolCalls = calls
+	return resp, nil
+}
+
+// reactArguments converts an Action Input into tool arguments. Input that
+// is not a JSON object is accepted for tools taking a single parameter.
+func reactArguments(name string, input any, tools []tool.Tool) (tool.Arguments, error) {
+	switch v := input.(type) {
+	case nil:
+		return tool.Arguments{}, nil
+	case map[string]any:
+		return tool.Arguments(v), nil
+	}
+	for _, t := range tools {
+		if t.Name() == name && len(t.Parameters()) == 1 {
+			return tool.Arguments{t.Parameters()[0].Name(): input}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: action input for %s is not a JSON object: %v", ErrInvalidToolCall, name, input)
+}
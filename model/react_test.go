@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func reactTools() []tool.Tool {
+	weather := tool.NewTool[string]("weather", "Looks up the weather", []tool.Parameter{
+		tool.NewParameter[string]("city", "The city", true, "", func(v string) (string, error) { return v, nil }),
+	}, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "sunny", nil
+	})
+	clock := tool.NewTool[string]("clock", "Tells the time", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "noon", nil
+	})
+	return []tool.Tool{weather, clock}
+}
+
+func TestWithReAct_SkipsToolModels(t *testing.T) {
+	inner := &scriptedModel{desc: ModelDescription{Model: "tools", CanUseTools: true}}
+	if WithReAct()(inner) != Model(inner) {
+		t.Errorf("expected models with tool calling to be left alone")
+	}
+	if !WithReAct()(&scriptedModel{}).Description().CanUseTools {
+		t.Errorf("expected the wrapped model to report tool use")
+	}
+}
+
+func TestWithReAct_ParsesActions(t *testing.T) {
+	inner := &scriptedModel{responses: []CompletionResponse{{Text: `Thought: I need the weather and the time.
+Action: weather
+Action Input: {"city": "Paris"}
+Action: clock
+Action Input: {}
+Observation: it is sunny`}}}
+	m := WithReAct()(inner)
+
+	request := userRequest("What's it like in Paris?")
+	request.Tools = reactTools()
+	resp, err := m.Complete(nil, request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.ToolCalls) != 2 || resp.ToolCalls[0].Name != "weather" || resp.ToolCalls[0].Arguments["city"] != "Paris" || resp.ToolCalls[1].Name != "clock" {
+		t.Fatalf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if strings.Contains(resp.Text, "sunny") {
+		t.Errorf("expected the hallucinated observation to be dropped, got %q", resp.Text)
+	}
+
+	sent := inner.requests[0]
+	if len(sent.Tools) != 0 || sent.Messages[0].Role != RoleSystem {
+		t.Fatalf("expected the tools to be sent as instructions, got %+v", sent)
+	}
+	for _, expected := range []string{"weather: Looks up the weather", `"city"`, "Action Input:", "Final Answer:"} {
+		if !strings.Contains(sent.Messages[0].Content, expected) {
+			t.Errorf("expected %q in the instructions", expected)
+		}
+	}
+	if !strings.Contains(strings.Join(sent.Config.Stop, ","), "Observation:") {
+		t.Errorf("expected observations to stop the model, got %v", sent.Config.Stop)
+	}
+}
+
+func TestWithReAct_FinalAnswerAndObservations(t *testing.T) {
+	inner := &scriptedModel{responses: []CompletionResponse{{Text: "Thought: I know it now.\nFinal Answer: Sunny, at noon."}}}
+	m := WithReAct()(inner)
+
+	request := CompletionRequest{
+		Messages: []Message{
+			{Role: RoleUser, Content: "What's it like in Paris?"},
+			{Role: RoleAssistant, Content: "I need the weather and the time.", ToolCalls: []ToolCall{
+				{ID: "1", Name: "weather", Arguments: tool.Arguments{"city": "Paris"}},
+				{ID: "2", Name: "clock", Arguments: tool.Arguments{}},
+			}},
+			{Role: RoleTool, Content: "sunny", ToolCallID: "1"},
+			{Role: RoleTool, Content: "noon", ToolCallID: "2"},
+		},
+		Tools: reactTools(),
+	}
+	resp, err := m.Complete(nil, request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Text != "Sunny, at noon." || len(resp.ToolCalls) != 0 {
+		t.Errorf("expected the final answer, got %+v", resp)
+	}
+
+	sent := inner.requests[0].Messages
+	if len(sent) != 4 || sent[3].Role != RoleUser || sent[3].Content != "Observation: sunny\nObservation: noon" {
+		t.Errorf("expected the tool results as one observation, got %+v", sent)
+	}
+	if replayed := sent[2]; len(replayed.ToolCalls) != 0 || !strings.Contains(replayed.Content, "Action: weather\nAction Input: {\"city\":\"Paris\"}") || !strings.HasPrefix(replayed.Content, "Thought: I need") {
+		t.Errorf("expected the tool calls replayed as actions, got %+v", replayed)
+	}
+}
+
+func TestWithReAct_ActionInput(t *testing.T) {
+	tools := reactTools()
+	// A single parameter tool accepts a bare value
+	args, err := reactArguments("weather", "Paris", tools)
+	if err != nil || args["city"] != "Paris" {
+		t.Errorf("unexpected arguments: %v, %v", args, err)
+	}
+	if _, err := reactArguments("clock", "now", tools); !errors.Is(err, ErrInvalidToolCall) {
+		t.Errorf("expected ErrInvalidToolCall, got %v", err)
+	}
+}
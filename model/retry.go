@@ -0,0 +1,164 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// StatModelRetries counts the retries made by WithRetry, recorded on the
+// calling tool.Context's Stats.
+const StatModelRetries = "model_retries"
+
+// RetryConfig configures the WithRetry middleware.
+type RetryConfig struct {
+	// MaxRetries is the number of retries after the initial attempt.
+	MaxRetries int `json:"max_retries"`
+	// InitialBackoff is the wait before the first retry.
+	InitialBackoff time.Duration `json:"initial_backoff"`
+	// MaxBackoff caps the computed wait between attempts. A Retry-After
+	// given by the provider is honored even if longer.
+	MaxBackoff time.Duration `json:"max_backoff"`
+	// Multiplier grows the wait after each attempt.
+	Multiplier float64 `json:"multiplier"`
+	// Jitter randomizes each wait by up to this fraction (0-1) of it.
+	Jitter float64 `json:"jitter"`
+	// Retryable decides whether an error is worth retrying; if nil,
+	// IsRetryable is used.
+	Retryable func(error) bool `json:"-"`
+}
+
+// DefaultRetryConfig returns a RetryConfig with sensible defaults.
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxRetries:     3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// backoff returns how long to wait before retry number attempt (0 based)
+// after err.
+func (c RetryConfig) backoff(attempt int, err error) time.Duration {
+	if wait := RetryAfter(err); wait > 0 {
+		return wait
+	}
+
+	wait := float64(c.InitialBackoff) * math.Pow(c.Multiplier, float64(attempt))
+	if c.MaxBackoff > 0 && wait > float64(c.MaxBackoff) {
+		wait = float64(c.MaxBackoff)
+	}
+	if c.Jitter > 0 {
+		wait += wait * c.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// WithRetry returns middleware that retries failed calls with exponential
+// backoff and jitter, honoring any Retry-After the provider sent. Each
+// retry is counted under StatModelRetries in the calling context's Stats.
+// Streams are retried only when they fail before emitting any event.
+func WithRetry(config RetryConfig) Middleware {
+	if config.Multiplier <= 0 {
+		config.Multiplier = 1
+	}
+	if config.Retryable == nil {
+		config.Retryable = IsRetryable
+	}
+
+	return func(next Model) Model {
+		return &wrappedModel{
+			next: next,
+			complete: func(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+				var resp CompletionResponse
+				err := retry(ctx, config, func() error {
+					var err error
+					resp, err = next.Complete(ctx, request)
+					return err
+				})
+				return resp, err
+			},
+			stream: func(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error) {
+				var events <-chan StreamEvent
+				err := retry(ctx, config, func() error {
+					var err error
+					events, err = openStream(ctx, next, request)
+					return err
+				})
+				return events, err
+			},
+		}
+	}
+}
+
+// retry calls fn until it succeeds, returns a non-retryable error, or
+// the retries are exhausted.
+func retry(ctx *tool.Context, config RetryConfig, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= config.MaxRetries || !config.Retryable(err) {
+			return err
+		}
+
+		if ctx != nil {
+			ctx.Stats().Incr(StatModelRetries)
+		}
+		if waitErr := sleep(ctx, config.backoff(attempt, err)); waitErr != nil {
+			return fmt.Errorf("retry interrupted: %w (last error: %v)", waitErr, err)
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx *tool.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// openStream starts a stream from m, returning an error if the stream
+// fails before emitting anything. Since nothing has been delivered in that
+// case, it is safe to retry the call or try another model.
+func openStream(ctx *tool.Context, m Model, request CompletionRequest) (<-chan StreamEvent, error) {
+	events, err := Stream(ctx, m, request)
+	if err != nil {
+		return nil, err
+	}
+	first, ok := <-events
+	if !ok {
+		return events, nil
+	}
+	if first.Type == StreamEventError {
+		for range events {
+		}
+		return nil, first.Err
+	}
+	return prependEvent(first, events), nil
+}
+
+// prependEvent returns a channel yielding first followed by everything
+// from rest.
+func prependEvent(first StreamEvent, rest <-chan StreamEvent) <-chan StreamEvent {
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		out <- first
+		for event := range rest {
+			out <- event
+		}
+	}()
+	return out
+}
@@ -0,0 +1,253 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// Stats recorded by Router on the calling tool.Context.
+const (
+	// StatModelUsed holds the name of the model that last answered.
+	StatModelUsed = "model_used"
+	// StatModelFallbacks counts the times a model failed and the next
+	// candidate was tried.
+	StatModelFallbacks = "model_fallbacks"
+)
+
+// RouteStrategy determines the order in which a Router tries its models.
+type RouteStrategy string
+
+const (
+	// RouteFallback tries the models in the order given, so the first is
+	// the primary and the rest are fallbacks.
+	RouteFallback RouteStrategy = "fallback"
+	// RouteCheapest tries the models from cheapest to most expensive for
+	// the request at hand.
+	RouteCheapest RouteStrategy = "cheapest"
+)
+
+// Router is a Model that routes each request among several models. Models
+// that cannot serve a request - too small a context window, no tool
+// support, or unaccepted content - are skipped, and a failure that
+// ShouldFallback accepts moves on to the next candidate.
+type Router struct {
+	models   []Model
+	strategy RouteStrategy
+
+	// shouldFallback decides whether an error moves on to the next model
+	shouldFallback func(error) bool
+}
+
+// RouterOption configures a Router.
+type RouterOption func(*Router)
+
+// WithRouteStrategy sets the order in which models are tried. The default
+// is RouteFallback.
+func WithRouteStrategy(strategy RouteStrategy) RouterOption {
+	return func(r *Router) {
+		r.strategy = strategy
+	}
+}
+
+// WithFallbackOn sets which errors move on to the next model. The default
+// is ShouldFallback.
+func WithFallbackOn(fn func(error) bool) RouterOption {
+	return func(r *Router) {
+		r.shouldFallback = fn
+	}
+}
+
+// NewRouter creates a Router over the given models.
+func NewRouter(models []Model, options ...RouterOption) (*Router, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("%w: router requires at least one model", ErrInvalidConfig)
+	}
+	r := &Router{
+		models:         models,
+		strategy:       RouteFallback,
+		shouldFallback: ShouldFallback,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	switch r.strategy {
+	case RouteFallback, RouteCheapest:
+	default:
+		return nil, fmt.Errorf("%w: unknown route strategy %q", ErrInvalidConfig, r.strategy)
+	}
+	return r, nil
+}
+
+// NewFallback creates a Router that tries primary first and then each of
+// the fallbacks in order.
+func NewFallback(primary Model, fallbacks ...Model) *Router {
+	r, _ := NewRouter(append([]Model{primary}, fallbacks...))
+	return r
+}
+
+// ShouldFallback is the default Router policy: fall back on rate limits,
+// context or content the model cannot handle, missing models and other
+// transient provider errors, but never on cancellation.
+func ShouldFallback(err error) bool {
+	return errors.Is(err, ErrRateLimitExceeded) ||
+		errors.Is(err, ErrContextTooLong) ||
+		errors.Is(err, ErrUnsupportedContent) ||
+		errors.Is(err, ErrModelNotFound) ||
+		IsRetryable(err)
+}
+
+// Ensure Router supports streaming.
+var _ StreamingModel = (*Router)(nil)
+
+// Description describes the combined capabilities of the routed models:
+// the largest context window, tool use if any model supports it, and every
+// accepted file type. Costs are those of the first model.
+func (r *Router) Description() ModelDescription {
+	names := make([]string, 0, len(r.models))
+	desc := ModelDescription{
+		Provider: "router",
+		Costs:    r.models[0].Description().Costs,
+	}
+	seen := map[string]bool{}
+	for _, m := range r.models {
+		d := m.Description()
+		names = append(names, d.Model)
+		desc.MaxContextTokens = max(desc.MaxContextTokens, d.MaxContextTokens)
+		desc.CanUseTools = desc.CanUseTools || d.CanUseTools
+		for _, fileType := range d.AcceptsFileTypes {
+			if !seen[strings.ToLower(fileType)] {
+				seen[strings.ToLower(fileType)] = true
+				desc.AcceptsFileTypes = append(desc.AcceptsFileTypes, fileType)
+			}
+		}
+	}
+	desc.Model = fmt.Sprintf("%s(%s)", r.strategy, strings.Join(names, ","))
+	desc.Description = fmt.Sprintf("Routes requests among %s", strings.Join(names, ", "))
+	return desc
+}
+
+// Complete sends the request to each candidate model in turn until one
+// succeeds or fails with an error that should not fall back. The answering
+// model is reported in CompletionResponse.Model and under StatModelUsed.
+func (r *Router) Complete(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+	var resp CompletionResponse
+	answeredBy, err := r.route(ctx, request, func(m Model) error {
+		var err error
+		resp, err = m.Complete(ctx, request)
+		return err
+	})
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	if resp.Model == "" {
+		resp.Model = answeredBy
+	}
+	return resp, nil
+}
+
+// CompleteStream streams from the first candidate whose stream starts
+// successfully, falling back as Complete does. Once a model has begun
+// emitting events, later failures are reported on the stream as usual.
+func (r *Router) CompleteStream(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error) {
+	var events <-chan StreamEvent
+	answeredBy, err := r.route(ctx, request, func(m Model) error {
+		var err error
+		events, err = openStream(ctx, m, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Label the final response with the model that produced it
+	out := make(chan StreamEvent)
+	go func() {
+		defer close(out)
+		for event := range events {
+			if event.Type == StreamEventDone && event.Response != nil && event.Response.Model == "" {
+				resp := *event.Response
+				resp.Model = answeredBy
+				event.Response = &resp
+			}
+			out <- event
+		}
+	}()
+	return out, nil
+}
+
+// route calls fn with each candidate until one succeeds or fails with an
+// error that should not fall back, returning the answering model's name.
+func (r *Router) route(ctx *tool.Context, request CompletionRequest, fn func(Model) error) (string, error) {
+	candidates, err := r.Candidates(request)
+	if err != nil {
+		return "", err
+	}
+
+	var errs []error
+	for i, m := range candidates {
+		name := m.Description().Model
+		err := fn(m)
+		if err == nil {
+			if ctx != nil {
+				ctx.Stats().Set(StatModelUsed, name)
+			}
+			return name, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		if !r.shouldFallback(err) || i == len(candidates)-1 {
+			break
+		}
+		if ctx != nil {
+			ctx.Stats().Incr(StatModelFallbacks)
+		}
+	}
+	return "", fmt.Errorf("all routed models failed: %w", errors.Join(errs...))
+}
+
+// Candidates returns the models able to serve request, in the order they
+// would be tried.
+func (r *Router) Candidates(request CompletionRequest) ([]Model, error) {
+	tokens := CountRequestTokens(DefaultTokenizer, request)
+
+	var candidates []Model
+	var reasons []error
+	for _, m := range r.models {
+		if err := canServe(m.Description(), request, tokens); err != nil {
+			reasons = append(reasons, err)
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no routed model can serve the request: %w", errors.Join(reasons...))
+	}
+
+	if r.strategy == RouteCheapest {
+		// Assume a response about as long as the request when comparing
+		sort.SliceStable(candidates, func(i, j int) bool {
+			costI := candidates[i].Description().Costs.Cost(tokens, tokens)
+			costJ := candidates[j].Description().Costs.Cost(tokens, tokens)
+			return costI < costJ
+		})
+	}
+	return candidates, nil
+}
+
+// canServe checks the request against the model's description.
+func canServe(desc ModelDescription, request CompletionRequest, tokens int) error {
+	if len(request.Tools) > 0 && !desc.CanUseTools {
+		return fmt.Errorf("%s: %w: tools are not supported", desc.Model, ErrInvalidRequest)
+	}
+	if tokens > desc.MaxContextTokens {
+		return fmt.Errorf("%s: %w: ~%d tokens exceeds %d", desc.Model, ErrContextTooLong, tokens, desc.MaxContextTokens)
+	}
+	if err := request.ValidateFor(desc); err != nil {
+		return fmt.Errorf("%s: %w", desc.Model, err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// describedModel is a model with a configurable description and outcome.
+type describedModel struct {
+	desc  ModelDescription
+	err   error
+	calls int
+}
+
+func (m *describedModel) Description() ModelDescription {
+	return m.desc
+}
+
+func (m *describedModel) Complete(ctx *tool.Context, req CompletionRequest) (CompletionResponse, error) {
+	m.calls++
+	if m.err != nil {
+		return CompletionResponse{}, m.err
+	}
+	return CompletionResponse{Text: "from " + m.desc.Model}, nil
+}
+
+func newDescribedModel(name string, contextTokens int, tools bool, input float64) *describedModel {
+	return &describedModel{desc: ModelDescription{
+		Model:            name,
+		Provider:         "test",
+		MaxContextTokens: contextTokens,
+		CanUseTools:      tools,
+		Costs:            CostsPerToken{Input: input, Output: input},
+	}}
+}
+
+func userRequest(text string) CompletionRequest {
+	return CompletionRequest{Messages: []Message{{Role: RoleUser, Content: text}}}
+}
+
+func TestRouter_FallsBackOnRateLimit(t *testing.T) {
+	primary := newDescribedModel("primary", 1000, true, 1)
+	primary.err = ErrRateLimitExceeded
+	secondary := newDescribedModel("secondary", 1000, true, 1)
+
+	router := NewFallback(primary, secondary)
+	ctx := tool.NewContext(context.Background())
+	resp, err := router.Complete(ctx, userRequest("hi"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Model != "secondary" || resp.Text != "from secondary" {
+		t.Fatalf("expected secondary to answer, got %+v", resp)
+	}
+	if used := ctx.Stats().Get(StatModelUsed); used != "secondary" {
+		t.Errorf("expected %s stat to be secondary, got %v", StatModelUsed, used)
+	}
+	if fallbacks := ctx.Stats().GetCount(StatModelFallbacks); fallbacks == nil || *fallbacks != 1 {
+		t.Errorf("expected one fallback recorded, got %v", fallbacks)
+	}
+}
+
+func TestRouter_StopsOnPermanentErrors(t *testing.T) {
+	primary := newDescribedModel("primary", 1000, true, 1)
+	primary.err = errors.New("invalid api key")
+	secondary := newDescribedModel("secondary", 1000, true, 1)
+
+	_, err := NewFallback(primary, secondary).Complete(nil, userRequest("hi"))
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Fatalf("expected primary's error, got %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Errorf("expected no fallback for a permanent error")
+	}
+}
+
+func TestRouter_SkipsIncapableModels(t *testing.T) {
+	small := newDescribedModel("small", 5, true, 1)
+	noTools := newDescribedModel("no-tools", 1000, false, 1)
+	capable := newDescribedModel("capable", 1000, true, 1)
+	router := NewFallback(small, noTools, capable)
+
+	echo := tool.NewTool[string]("echo", "echoes", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+	req := userRequest(strings.Repeat("long request ", 10))
+	req.Tools = []tool.Tool{echo}
+
+	resp, err := router.Complete(nil, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Model != "capable" || small.calls != 0 || noTools.calls != 0 {
+		t.Fatalf("expected only the capable model to be called, got %+v", resp)
+	}
+
+	// Without a capable model the request is rejected up front
+	_, err = NewFallback(small).Complete(nil, req)
+	if !errors.Is(err, ErrContextTooLong) {
+		t.Fatalf("expected ErrContextTooLong, got %v", err)
+	}
+}
+
+func TestRouter_Cheapest(t *testing.T) {
+	pricey := newDescribedModel("pricey", 1000, true, 10)
+	cheap := newDescribedModel("cheap", 1000, true, 1)
+	tiny := newDescribedModel("tiny", 2, true, 0.1)
+
+	router, err := NewRouter([]Model{pricey, cheap, tiny}, WithRouteStrategy(RouteCheapest))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	candidates, err := router.Candidates(userRequest("a request too long for tiny"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0] != Model(cheap) || candidates[1] != Model(pricey) {
+		t.Fatalf("expected [cheap pricey], got %v", candidates)
+	}
+
+	resp, err := router.Complete(nil, userRequest("a request too long for tiny"))
+	if err != nil || resp.Model != "cheap" {
+		t.Fatalf("expected cheap model to answer, got %+v (%v)", resp, err)
+	}
+}
+
+func TestRouter_Description(t *testing.T) {
+	a := newDescribedModel("a", 100, false, 1)
+	b := newDescribedModel("b", 500, true, 2)
+	b.desc.AcceptsFileTypes = []string{"image/png"}
+
+	desc := NewFallback(a, b).Description()
+	if desc.MaxContextTokens != 500 || !desc.CanUseTools || !desc.Accepts("image/png") {
+		t.Errorf("expected combined capabilities, got %+v", desc)
+	}
+	if desc.Model != "fallback(a,b)" {
+		t.Errorf("unexpected router name %q", desc.Model)
+	}
+
+	if _, err := NewRouter(nil); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an empty router, got %v", err)
+	}
+}
+
+func TestRouter_StreamFallsBack(t *testing.T) {
+	primary := newDescribedModel("primary", 1000, true, 1)
+	primary.err = &ProviderError{Provider: "test", StatusCode: 503, Err: errors.New("down")}
+	secondary := newDescribedModel("secondary", 1000, true, 1)
+
+	events, err := Stream(nil, NewFallback(primary, secondary), userRequest("hi"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := CollectStream(events, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Model != "secondary" || resp.Text != "from secondary" {
+		t.Fatalf("expected secondary to answer, got %+v", resp)
+	}
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// StreamEventType identifies what a StreamEvent carries.
+type StreamEventType string
+
+const (
+	// StreamEventText carries an incremental piece of assistant text.
+	StreamEventText StreamEventType = "text"
+	// StreamEventToolCall carries a partial tool call (name and/or a
+	// fragment of the JSON arguments).
+	StreamEventToolCall StreamEventType = "tool_call"
+	// StreamEventDone is the final event of a successful stream and
+	// carries the fully assembled CompletionResponse.
+	StreamEventDone StreamEventType = "done"
+	// StreamEventError is the final event of a failed stream.
+	StreamEventError StreamEventType = "error"
+)
+
+// ToolCallDelta is a fragment of a tool call as it is streamed from the
+// model. Fragments sharing an Index belong to the same tool call; ID and
+// Name are typically only present on the first fragment, while Arguments
+// holds the next slice of the raw JSON arguments string.
+type ToolCallDelta struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// StreamEvent is a single event emitted while streaming a completion.
+// Exactly one of Text, ToolCall, Response or Err is meaningful, as
+// determined by Type.
+type StreamEvent struct {
+	Type     StreamEventType     `json:"type"`
+	Text     string              `json:"text,omitempty"`
+	ToolCall *ToolCallDelta      `json:"tool_call,omitempty"`
+	Response *CompletionResponse `json:"response,omitempty"`
+	Err      error               `json:"-"`
+}
+
+// StreamingModel is implemented by models that can stream their output
+// as it is generated. The returned channel is closed after a terminal
+// StreamEventDone or StreamEventError event has been sent.
+type StreamingModel interface {
+	Model
+
+	// CompleteStream generates a completion for the given request,
+	// emitting incremental events on the returned channel.
+	CompleteStream(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error)
+}
+
+// Stream streams a completion from m. If m does not implement
+// StreamingModel, the blocking Complete is called instead and its
+// result is emitted as a single text event followed by the done event,
+// so callers can treat every model uniformly.
+func Stream(ctx *tool.Context, m Model, request CompletionRequest) (<-chan StreamEvent, error) {
+	if sm, ok := m.(StreamingModel); ok {
+		return sm.CompleteStream(ctx, request)
+	}
+
+	resp, err := m.Complete(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return responseEvents(resp), nil
+}
+
+// responseEvents returns a closed channel holding resp as stream events: a
+// text event, an event per tool call with its arguments as JSON, and the
+// done event.
+func responseEvents(resp CompletionResponse) <-chan StreamEvent {
+	events := make(chan StreamEvent, 2+len(resp.ToolCalls))
+	if resp.Text != "" {
+		events <- StreamEvent{Type: StreamEventText, Text: resp.Text}
+	}
+	for i, call := range resp.ToolCalls {
+		// Each call's arguments arrive whole, in a single fragment
+		args, _ := json.Marshal(call.Arguments)
+		events <- StreamEvent{
+			Type:     StreamEventToolCall,
+			ToolCall: &ToolCallDelta{Index: i, ID: call.ID, Name: call.Name, Arguments: string(args)},
+		}
+	}
+	events <- StreamEvent{Type: StreamEventDone, Response: &resp}
+	close(events)
+	return events
+}
+
+// CollectStream drains events, calling onEvent (if non-nil) for each one,
+// and returns the final CompletionResponse or the stream's error.
+func CollectStream(events <-chan StreamEvent, onEvent func(StreamEvent)) (CompletionResponse, error) {
+	var final *CompletionResponse
+	var streamErr error
+	for event := range events {
+		if onEvent != nil {
+			onEvent(event)
+		}
+		switch event.Type {
+		case StreamEventDone:
+			final = event.Response
+		case StreamEventError:
+			streamErr = event.Err
+		}
+	}
+
+	if streamErr != nil {
+		return CompletionResponse{}, streamErr
+	}
+	if final == nil {
+		return CompletionResponse{}, errors.New("stream ended without a final response")
+	}
+	return *final, nil
+}
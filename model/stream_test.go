@@ -0,0 +1,131 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+type blockingModel struct {
+	resp CompletionResponse
+	err  error
+}
+
+func (m *blockingModel) Description() ModelDescription {
+	return ModelDescription{Model: "blocking", Provider: "test", MaxContextTokens: 1024}
+}
+
+func (m *blockingModel) Complete(ctx *tool.Context, req CompletionRequest) (CompletionResponse, error) {
+	return m.resp, m.err
+}
+
+type streamingModel struct {
+	blockingModel
+	events []StreamEvent
+}
+
+func (m *streamingModel) CompleteStream(ctx *tool.Context, req CompletionRequest) (<-chan StreamEvent, error) {
+	ch := make(chan StreamEvent, len(m.events))
+	for _, e := range m.events {
+		ch <- e
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestStream_FallsBackToComplete(t *testing.T) {
+	m := &blockingModel{resp: CompletionResponse{
+		Text:       "hello",
+		ToolCalls:  []ToolCall{{ID: "1", Name: "lookup", Arguments: tool.Arguments{"query": "go", "limit": 2.0}}},
+		UsageStats: UsageStats{InputTokens: 3, OutputTokens: 2},
+	}}
+
+	events, err := Stream(nil, m, CompletionRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var types []StreamEventType
+	var deltas []*ToolCallDelta
+	resp, err := CollectStream(events, func(e StreamEvent) {
+		types = append(types, e.Type)
+		if e.ToolCall != nil {
+			deltas = append(deltas, e.ToolCall)
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []StreamEventType{StreamEventText, StreamEventToolCall, StreamEventDone}
+	if len(types) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, types)
+		}
+	}
+	if resp.Text != "hello" || resp.UsageStats.Total() != 5 {
+		t.Fatalf("unexpected final response: %+v", resp)
+	}
+
+	var args tool.Arguments
+	if err := json.Unmarshal([]byte(deltas[0].Arguments), &args); err != nil {
+		t.Fatalf("tool call arguments %q are not JSON: %v", deltas[0].Arguments, err)
+	}
+	if deltas[0].ID != "1" || deltas[0].Name != "lookup" || !reflect.DeepEqual(args, resp.ToolCalls[0].Arguments) {
+		t.Fatalf("expected the tool call to round-trip, got %+v", deltas[0])
+	}
+}
+
+func TestStream_FallbackPropagatesError(t *testing.T) {
+	m := &blockingModel{err: errors.New("boom")}
+	if _, err := Stream(nil, m, CompletionRequest{}); err == nil {
+		t.Fatalf("expected error from Complete to be returned")
+	}
+}
+
+func TestStream_UsesStreamingModel(t *testing.T) {
+	final := CompletionResponse{Text: "ab"}
+	m := &streamingModel{events: []StreamEvent{
+		{Type: StreamEventText, Text: "a"},
+		{Type: StreamEventText, Text: "b"},
+		{Type: StreamEventDone, Response: &final},
+	}}
+
+	events, err := Stream(nil, m, CompletionRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var text string
+	resp, err := CollectStream(events, func(e StreamEvent) {
+		text += e.Text
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != "ab" || resp.Text != "ab" {
+		t.Fatalf("expected streamed text %q, got deltas %q and final %q", "ab", text, resp.Text)
+	}
+}
+
+func TestCollectStream_Errors(t *testing.T) {
+	ch := make(chan StreamEvent, 2)
+	ch <- StreamEvent{Type: StreamEventText, Text: "partial"}
+	ch <- StreamEvent{Type: StreamEventError, Err: errors.New("disconnected")}
+	close(ch)
+	if _, err := CollectStream(ch, nil); err == nil || err.Error() != "disconnected" {
+		t.Fatalf("expected stream error, got %v", err)
+	}
+
+	empty := make(chan StreamEvent)
+	close(empty)
+	if _, err := CollectStream(empty, nil); err == nil {
+		t.Fatalf("expected error for stream without a done event")
+	}
+}
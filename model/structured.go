@@ -0,0 +1,198 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// DefaultStructuredOutputRetries is how many times WithStructuredOutput
+// re-prompts by default when a response does not match its format.
+const DefaultStructuredOutputRetries = 2
+
+// ResponseFormatFor returns a json_schema ResponseFormat describing T,
+// derived with tool.TypeToJSONSchema. It is strict when the schema allows
+// it: providers' strict modes require every field of every object to be
+// required, so types with optional fields or maps are sent non-strict and
+// checked by WithStructuredOutput instead.
+func ResponseFormatFor[T any](name string) *ResponseFormat {
+	schema := tool.TypeToJSONSchema(tool.Type[T]())
+	return &ResponseFormat{
+		Type:   ResponseFormatJSONSchema,
+		Name:   name,
+		Schema: schema,
+		Strict: strictCompatible(schema),
+	}
+}
+
+// strictCompatible reports whether every object in the schema, and in
+// its $defs, has fixed properties, all of them required.
+func strictCompatible(schema map[string]any) bool {
+	if defs, ok := schema["$defs"].(map[string]any); ok {
+		for _, def := range defs {
+			if d, ok := def.(map[string]any); !ok || !strictCompatible(d) {
+				return false
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok && !strictCompatible(items) {
+		return false
+	}
+	if schema["type"] != "object" {
+		return true
+	}
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok || schema["additionalProperties"] != false {
+		return false
+	}
+	required, _ := schema["required"].([]string)
+	if len(required) != len(properties) {
+		return false
+	}
+	for _, property := range properties {
+		if p, ok := property.(map[string]any); !ok || !strictCompatible(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse checks that text conforms to the format and returns the JSON it
+// holds, tolerating the markdown code fences and surrounding prose models
+// often add. Text formats are returned unchanged.
+func (f ResponseFormat) Parse(text string) (string, error) {
+	if f.Type == ResponseFormatText || f.Type == "" {
+		return text, nil
+	}
+
+	extracted := ExtractJSON(text)
+	var value any
+	if err := json.Unmarshal([]byte(extracted), &value); err != nil {
+		return "", fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	switch f.Type {
+	case ResponseFormatJSON:
+		if _, ok := value.(map[string]any); !ok {
+			return "", fmt.Errorf("response is not a JSON object")
+		}
+	case ResponseFormatJSONSchema:
+		if err := tool.ValidateJSONSchema(f.Schema, value); err != nil {
+			return "", fmt.Errorf("response does not match the schema: %w", err)
+		}
+	}
+	return extracted, nil
+}
+
+// ExtractJSON returns the JSON object or array in text, stripping any
+// markdown code fence around it and any prose before or after it. If no
+// JSON is found, the trimmed text is returned as is.
+func ExtractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if fenced, ok := strings.CutPrefix(text, "```"); ok {
+		// Drop the fence's language tag, e.g. ```json
+		if newline := strings.IndexByte(fenced, '\n'); newline >= 0 {
+			fenced = fenced[newline+1:]
+		}
+		if end := strings.LastIndex(fenced, "```"); end >= 0 {
+			fenced = fenced[:end]
+		}
+		text = strings.TrimSpace(fenced)
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end < start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+// WithStructuredOutput returns middleware that makes requests with a JSON
+// ResponseFormat reliable on every model. Models whose description
+// reports StructuredOutput are sent the format natively; others are sent
+// the schema as an instruction instead. Either way the response is
+// checked with ResponseFormat.Parse, and on a mismatch the model is shown
+// the error and asked again, up to maxRetries times, before failing with
+// ErrInvalidResponse. The returned text is the bare JSON, and usage
+// covers every attempt. Responses that call tools are passed through, as
+// the format applies to the final answer.
+func WithStructuredOutput(maxRetries int) Middleware {
+	return func(next Model) Model {
+		complete := func(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+			format := request.Config.ResponseFormat
+			if format == nil || format.Type == ResponseFormatText {
+				return next.Complete(ctx, request)
+			}
+			if !next.Description().StructuredOutput {
+				request = instructFormat(request, *format)
+			}
+
+			var usage UsageStats
+			for attempt := 0; ; attempt++ {
+				resp, err := next.Complete(ctx, request)
+				if err != nil {
+					return CompletionResponse{}, err
+				}
+				usage = usage.Add(resp.UsageStats)
+				resp.UsageStats = usage
+				if len(resp.ToolCalls) > 0 {
+					return resp, nil
+				}
+
+				text, err := format.Parse(resp.Text)
+				if err == nil {
+					resp.Text = text
+					return resp, nil
+				}
+				if attempt >= maxRetries {
+					return CompletionResponse{}, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+				}
+				request.Messages = append(slices.Clip(request.Messages),
+					Message{Role: RoleAssistant, Content: resp.Text},
+					Message{Role: RoleUser, Content: fmt.Sprintf("Your previous response was invalid: %v\n\nRespond again with only the corrected JSON, with no markdown or other text.", err)},
+				)
+			}
+		}
+
+		return &wrappedModel{
+			next:     next,
+			complete: complete,
+			stream: func(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error) {
+				// The response can only be checked once complete, so it is
+				// delivered as a single event
+				resp, err := complete(ctx, request)
+				if err != nil {
+					return nil, err
+				}
+				return responseEvents(resp), nil
+			},
+		}
+	}
+}
+
+// instructFormat returns a copy of request asking for the format in a
+// system message, for models without native structured output.
+func instructFormat(request CompletionRequest, format ResponseFormat) CompletionRequest {
+	instruction := "Respond with only a JSON object, with no markdown or other text."
+	if format.Type == ResponseFormatJSONSchema {
+		schema, _ := json.Marshal(format.Schema)
+		instruction = fmt.Sprintf("Respond with only a JSON value matching this JSON schema, with no markdown or other text:\n%s", schema)
+	}
+
+	request.Config.ResponseFormat = nil
+	request.Messages = append(slices.Clip(request.Messages), Message{Role: RoleSystem, Content: instruction})
+	return request
+}
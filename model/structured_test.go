@@ -0,0 +1,186 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// scriptedModel returns the queued responses in order and records the
+// requests it was sent.
+type scriptedModel struct {
+	desc      ModelDescription
+	responses []CompletionResponse
+	requests  []CompletionRequest
+}
+
+func (m *scriptedModel) Description() ModelDescription {
+	return m.desc
+}
+
+func (m *scriptedModel) Complete(ctx *tool.Context, req CompletionRequest) (CompletionResponse, error) {
+	m.requests = append(m.requests, req)
+	resp := m.responses[0]
+	if len(m.responses) > 1 {
+		m.responses = m.responses[1:]
+	}
+	return resp, nil
+}
+
+type answer struct {
+	Answer     string   `json:"answer"`
+	Confidence float64  `json:"confidence"`
+	Sources    []string `json:"sources"`
+}
+
+func structuredRequest() CompletionRequest {
+	return CompletionRequest{
+		Messages: []Message{{Role: RoleUser, Content: "What is 6 x 7?"}},
+		Config:   ModelConfig{ResponseFormat: ResponseFormatFor[answer]("answer")},
+	}
+}
+
+func TestResponseFormatFor(t *testing.T) {
+	format := ResponseFormatFor[answer]("answer")
+	if format.Type != ResponseFormatJSONSchema || format.Name != "answer" || !format.Strict {
+		t.Fatalf("unexpected format: %+v", format)
+	}
+	if err := format.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type optional struct {
+		Note string `json:"note,omitempty"`
+	}
+	if ResponseFormatFor[optional]("optional").Strict {
+		t.Errorf("expected optional fields to rule out strict mode")
+	}
+	if ResponseFormatFor[map[string]int]("counts").Strict {
+		t.Errorf("expected maps to rule out strict mode")
+	}
+
+	type tree struct {
+		Name     string `json:"name"`
+		Children []tree `json:"children"`
+	}
+	if format := ResponseFormatFor[tree]("tree"); !format.Strict || format.Schema["$defs"] == nil {
+		t.Errorf("expected recursive types to be defined and strict, got %+v", format)
+	}
+	type sparseTree struct {
+		Name     string       `json:"name"`
+		Children []sparseTree `json:"children,omitempty"`
+	}
+	if ResponseFormatFor[sparseTree]("tree").Strict {
+		t.Errorf("expected optional fields in definitions to rule out strict mode")
+	}
+}
+
+func TestResponseFormat_Parse(t *testing.T) {
+	format := ResponseFormatFor[answer]("answer")
+
+	text, err := format.Parse("Here you go:\n```json\n{\"answer\": \"42\", \"confidence\": 0.9, \"sources\": []}\n```")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if text != `{"answer": "42", "confidence": 0.9, "sources": []}` {
+		t.Errorf("expected the bare JSON, got %q", text)
+	}
+
+	_, err = format.Parse(`{"answer": 42, "sources": []}`)
+	if err == nil || !strings.Contains(err.Error(), "$.answer") || !strings.Contains(err.Error(), "confidence") {
+		t.Errorf("expected errors naming the wrong and missing fields, got %v", err)
+	}
+	if _, err := (ResponseFormat{Type: ResponseFormatJSON}).Parse("[1, 2]"); err == nil {
+		t.Errorf("expected json_object to reject arrays")
+	}
+	if text, _ := (ResponseFormat{Type: ResponseFormatText}).Parse(" hi "); text != " hi " {
+		t.Errorf("expected text to be returned unchanged, got %q", text)
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"a": 1}`:                          `{"a": 1}`,
+		"```json\n{\"a\": 1}\n```":          `{"a": 1}`,
+		"```\n[1, 2]\n```":                  `[1, 2]`,
+		"Sure! {\"a\": {\"b\": 2}} Thanks.": `{"a": {"b": 2}}`,
+		"no json here":                      "no json here",
+	}
+	for input, expected := range tests {
+		if got := ExtractJSON(input); got != expected {
+			t.Errorf("ExtractJSON(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestWithStructuredOutput_Native(t *testing.T) {
+	inner := &scriptedModel{
+		desc:      ModelDescription{Model: "native", StructuredOutput: true},
+		responses: []CompletionResponse{{Text: `{"answer":"42","confidence":1,"sources":[]}`}},
+	}
+	m := WithStructuredOutput(DefaultStructuredOutputRetries)(inner)
+
+	if _, err := m.Complete(nil, structuredRequest()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sent := inner.requests[0]
+	if sent.Config.ResponseFormat == nil || len(sent.Messages) != 1 {
+		t.Errorf("expected the format to be sent natively, got %+v", sent)
+	}
+}
+
+func TestWithStructuredOutput_PromptsAndRetries(t *testing.T) {
+	inner := &scriptedModel{
+		desc: ModelDescription{Model: "plain"},
+		responses: []CompletionResponse{
+			{Text: "It's 42!", UsageStats: UsageStats{InputTokens: 10, OutputTokens: 2}},
+			{Text: "```json\n{\"answer\":\"42\",\"confidence\":1,\"sources\":[]}\n```", UsageStats: UsageStats{InputTokens: 20, OutputTokens: 8}},
+		},
+	}
+	m := WithStructuredOutput(DefaultStructuredOutputRetries)(inner)
+
+	resp, err := m.Complete(nil, structuredRequest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Text != `{"answer":"42","confidence":1,"sources":[]}` {
+		t.Errorf("expected the bare JSON, got %q", resp.Text)
+	}
+	if resp.UsageStats.InputTokens != 30 || resp.UsageStats.OutputTokens != 10 {
+		t.Errorf("expected usage across both attempts, got %+v", resp.UsageStats)
+	}
+
+	first := inner.requests[0]
+	if first.Config.ResponseFormat != nil || len(first.Messages) != 2 || !strings.Contains(first.Messages[1].Content, `"confidence"`) {
+		t.Errorf("expected the schema as an instruction instead, got %+v", first)
+	}
+	retry := inner.requests[1]
+	if len(retry.Messages) != 4 || retry.Messages[2].Content != "It's 42!" || !strings.Contains(retry.Messages[3].Content, "not valid JSON") {
+		t.Errorf("expected the invalid response and the error fed back, got %+v", retry.Messages)
+	}
+}
+
+func TestWithStructuredOutput_GivesUp(t *testing.T) {
+	inner := &scriptedModel{responses: []CompletionResponse{{Text: "never JSON"}}}
+	m := WithStructuredOutput(1)(inner)
+
+	_, err := m.Complete(nil, structuredRequest())
+	if !errors.Is(err, ErrInvalidResponse) {
+		t.Fatalf("expected ErrInvalidResponse, got %v", err)
+	}
+	if len(inner.requests) != 2 {
+		t.Errorf("expected one retry, got %d calls", len(inner.requests))
+	}
+}
+
+func TestWithStructuredOutput_PassesToolCallsThrough(t *testing.T) {
+	inner := &scriptedModel{responses: []CompletionResponse{{ToolCalls: []ToolCall{{Name: "lookup"}}}}}
+	m := WithStructuredOutput(1)(inner)
+
+	resp, err := m.Complete(nil, structuredRequest())
+	if err != nil || len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected the tool call to pass through, got %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,186 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// Tokenizer counts the tokens a piece of text will use.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// DefaultTokenizer is the Tokenizer used wherever none is given.
+var DefaultTokenizer Tokenizer = Estimator{}
+
+const (
+	// messageOverheadTokens is charged per message for its role and
+	// framing, as chat formats wrap every message in special tokens.
+	messageOverheadTokens = 4
+	// replyOverheadTokens primes the assistant's reply.
+	replyOverheadTokens = 3
+	// partTokens is charged per image or file part; providers bill these
+	// by size and detail, which is unknown here, so a high-detail image is
+	// assumed.
+	partTokens = 765
+)
+
+// Estimator is a local Tokenizer approximating the byte pair encodings
+// used by current models (e.g. cl100k) without their vocabularies. Text is
+// split the way those encoders pre-tokenize it, and each piece is charged
+// what BPE typically merges it into: a word of up to six letters, with its
+// leading space, is one token; digits are grouped in threes; punctuation
+// merges in pairs; and each CJK or other non-Latin symbol is a token of
+// its own. It tends to slightly overestimate, which is the safe side for
+// fitting a context window.
+type Estimator struct{}
+
+// CountTokens estimates the tokens text will use.
+func (Estimator) CountTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		class := runClass(nextRune(text[i:]))
+		n, size := runLength(text[i:], class)
+		switch class {
+		case classLetter:
+			tokens += (n + 5) / 6
+		case classDigit:
+			tokens += (n + 2) / 3
+		case classSpace:
+			// A single space is merged into the word that follows it
+			if n > 1 || i+size == len(text) || runClass(nextRune(text[i+size:])) != classLetter {
+				tokens++
+			}
+		case classPunct:
+			tokens += (n + 1) / 2
+		default:
+			tokens += n
+		}
+		i += size
+	}
+	return tokens
+}
+
+type charClass int
+
+const (
+	classLetter charClass = iota
+	classDigit
+	classSpace
+	classPunct
+	classSymbol
+)
+
+// runClass groups runes the way BPE pre-tokenizers split text. Scripts
+// without spaces between words (CJK and beyond) are symbols, counted one
+// token each.
+func runClass(r rune) charClass {
+	switch {
+	case r < 0x2E80 && unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	case unicode.IsSpace(r):
+		return classSpace
+	case r < utf8.RuneSelf:
+		return classPunct
+	}
+	return classSymbol
+}
+
+// runLength returns the length, in runes and in bytes, of the run of
+// class at the start of text.
+func runLength(text string, class charClass) (runes, size int) {
+	for i, r := range text {
+		if runClass(r) != class {
+			return runes, i
+		}
+		runes++
+	}
+	return runes, len(text)
+}
+
+func nextRune(text string) rune {
+	r, _ := utf8.DecodeRuneInString(text)
+	return r
+}
+
+// CountMessageTokens estimates the tokens messages will use in a request,
+// including each message's framing. Image and file parts are charged a
+// flat amount each.
+func CountMessageTokens(t Tokenizer, messages []Message) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += messageOverheadTokens + t.CountTokens(msg.Text())
+		for _, part := range msg.Parts {
+			if part.Type != PartText {
+				tokens += partTokens
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			args, _ := json.Marshal(call.Arguments)
+			tokens += t.CountTokens(call.Name) + t.CountTokens(string(args))
+		}
+	}
+	return tokens
+}
+
+// CountRequestTokens estimates the input tokens request will use: its
+// messages, the definitions of its tools and the priming of the reply.
+func CountRequestTokens(t Tokenizer, request CompletionRequest) int {
+	tokens := CountMessageTokens(t, request.Messages) + replyOverheadTokens
+	for _, tl := range request.Tools {
+		schema, _ := json.Marshal(tool.ParametersToJSONSchema(tl.Parameters()))
+		tokens += t.CountTokens(tl.Name()) + t.CountTokens(tl.Description()) + t.CountTokens(string(schema))
+	}
+	return tokens
+}
+
+// CheckContext returns ErrContextTooLong if request will not fit in the
+// context window of the model desc describes, counting with t and
+// reserving room for Config.MaxTokens of output. Models that do not
+// report a MaxContextTokens are not checked.
+func CheckContext(t Tokenizer, desc ModelDescription, request CompletionRequest) error {
+	if desc.MaxContextTokens <= 0 {
+		return nil
+	}
+	tokens := CountRequestTokens(t, request)
+	if tokens+request.Config.MaxTokens > desc.MaxContextTokens {
+		return fmt.Errorf(
+			"%w: ~%d tokens plus %d reserved for output exceeds %s's %d",
+			ErrContextTooLong, tokens, request.Config.MaxTokens, desc.Model, desc.MaxContextTokens,
+		)
+	}
+	return nil
+}
+
+// WithContextCheck returns middleware that checks every request with
+// CheckContext before it is sent, failing with ErrContextTooLong rather
+// than spending a provider call on a request that cannot fit. A nil t
+// uses DefaultTokenizer.
+func WithContextCheck(t Tokenizer) Middleware {
+	if t == nil {
+		t = DefaultTokenizer
+	}
+	return func(next Model) Model {
+		return &wrappedModel{
+			next: next,
+			complete: func(ctx *tool.Context, request CompletionRequest) (CompletionResponse, error) {
+				if err := CheckContext(t, next.Description(), request); err != nil {
+					return CompletionResponse{}, err
+				}
+				return next.Complete(ctx, request)
+			},
+			stream: func(ctx *tool.Context, request CompletionRequest) (<-chan StreamEvent, error) {
+				if err := CheckContext(t, next.Description(), request); err != nil {
+					return nil, err
+				}
+				return Stream(ctx, next, request)
+			},
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEstimator_CountTokens(t *testing.T) {
+	tests := map[string]int{
+		"":                           0,
+		"hello":                      1,
+		"hello world":                2,
+		"internationalization":       4,
+		"1234567":                    3,
+		`{"a": 1}`:                   6,
+		"line one\n\nline two":       5,
+		"日本語":                        3,
+		"The quick brown fox jumps.": 6,
+	}
+	for text, expected := range tests {
+		if got := (Estimator{}).CountTokens(text); got != expected {
+			t.Errorf("CountTokens(%q) = %d, expected %d", text, got, expected)
+		}
+	}
+}
+
+func TestCountRequestTokens(t *testing.T) {
+	request := CompletionRequest{Messages: []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Parts: []ContentPart{TextPart("What is this?"), ImagePart([]byte{1}, "image/png")}},
+	}}
+	// 2 messages of framing, 3 + 4 tokens of text, an image and the reply
+	expected := 2*messageOverheadTokens + 3 + 4 + partTokens + replyOverheadTokens
+	if got := CountRequestTokens(Estimator{}, request); got != expected {
+		t.Errorf("expected %d tokens, got %d", expected, got)
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	desc := ModelDescription{Model: "small", Provider: "test", MaxContextTokens: 100}
+	request := CompletionRequest{Messages: []Message{{Role: RoleUser, Content: strings.Repeat("word ", 50)}}}
+
+	if err := CheckContext(Estimator{}, desc, request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	request.Config.MaxTokens = 60
+	if err := CheckContext(Estimator{}, desc, request); !errors.Is(err, ErrContextTooLong) {
+		t.Errorf("expected the reserved output to count, got %v", err)
+	}
+	if err := CheckContext(Estimator{}, ModelDescription{Model: "unknown"}, request); err != nil {
+		t.Errorf("expected models without a context size to be skipped, got %v", err)
+	}
+}
+
+func TestWithContextCheck(t *testing.T) {
+	inner := &scriptedModel{
+		desc:      ModelDescription{Model: "small", Provider: "test", MaxContextTokens: 20},
+		responses: []CompletionResponse{{Text: "ok"}},
+	}
+	m := WithContextCheck(nil)(inner)
+
+	if _, err := m.Complete(nil, CompletionRequest{Messages: []Message{{Role: RoleUser, Content: "hi"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	long := CompletionRequest{Messages: []Message{{Role: RoleUser, Content: strings.Repeat("word ", 50)}}}
+	if _, err := m.Complete(nil, long); !errors.Is(err, ErrContextTooLong) {
+		t.Fatalf("expected ErrContextTooLong, got %v", err)
+	}
+	if _, err := Stream(nil, m, long); !errors.Is(err, ErrContextTooLong) {
+		t.Fatalf("expected ErrContextTooLong when streaming, got %v", err)
+	}
+	if len(inner.requests) != 1 {
+		t.Errorf("expected the long requests not to be sent, got %d calls", len(inner.requests))
+	}
+}
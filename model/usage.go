@@ -0,0 +1,53 @@
+package model
+
+import (
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// RecordUsage records the tokens resp used, and their cost, on ctx's
+// Stats under the model that produced it: resp.Model if set, and
+// otherwise m's. Costs are taken from that model's description; when m
+// routes among models (see Router), from the one that answered.
+func RecordUsage(ctx *tool.Context, m Model, resp CompletionResponse) {
+	if ctx == nil {
+		return
+	}
+	desc := m.Description()
+	name := resp.Model
+	if name == "" {
+		name = desc.Model
+	}
+	costs := desc.Costs
+	if routed, ok := routedModel(m, name); ok {
+		costs = routed.Description().Costs
+	}
+
+	usage := resp.UsageStats
+	ctx.Stats().RecordUsage(name, tool.Usage{
+		Calls:        1,
+		InputTokens:  int64(usage.InputTokens),
+		OutputTokens: int64(usage.OutputTokens),
+		Cost:         costs.Cost(usage.InputTokens, usage.OutputTokens),
+	})
+}
+
+// routedModel finds the model named name among those of a Router within
+// m's middleware chain.
+func routedModel(m Model, name string) (Model, bool) {
+	for m != nil {
+		if r, ok := m.(*Router); ok {
+			for _, candidate := range r.models {
+				if candidate.Description().Model == name {
+					return candidate, true
+				}
+			}
+			return nil, false
+		}
+		u, ok := m.(Unwrapper)
+		if !ok {
+			return nil, false
+		}
+		m = u.Unwrap()
+	}
+	return nil, false
+}
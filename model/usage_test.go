@@ -0,0 +1,49 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+func TestRecordUsage(t *testing.T) {
+	m := &scriptedModel{desc: ModelDescription{
+		Model:    "priced",
+		Provider: "test",
+		Costs:    CostsPerToken{Input: 0.01, Output: 0.02},
+	}}
+	ctx := tool.NewContext(context.Background())
+
+	RecordUsage(ctx, m, CompletionResponse{UsageStats: UsageStats{InputTokens: 100, OutputTokens: 50}})
+	RecordUsage(ctx, m, CompletionResponse{UsageStats: UsageStats{InputTokens: 10, OutputTokens: 0}})
+
+	usage := ctx.Stats().Usage()["priced"]
+	if usage.Calls != 2 || usage.InputTokens != 110 || usage.OutputTokens != 50 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if want := 0.01*110 + 0.02*50; usage.Cost < want-1e-9 || usage.Cost > want+1e-9 {
+		t.Errorf("expected cost %v, got %v", want, usage.Cost)
+	}
+
+	// A nil context is ignored
+	RecordUsage(nil, m, CompletionResponse{})
+}
+
+func TestRecordUsage_RoutedModel(t *testing.T) {
+	cheap := &scriptedModel{desc: ModelDescription{Model: "cheap", Costs: CostsPerToken{Input: 1}}}
+	dear := &scriptedModel{desc: ModelDescription{Model: "dear", Costs: CostsPerToken{Input: 10}}}
+	router, err := NewRouter([]Model{cheap, dear})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Chain(router, WithRetry(RetryConfig{}))
+	ctx := tool.NewContext(context.Background())
+
+	RecordUsage(ctx, m, CompletionResponse{Model: "dear", UsageStats: UsageStats{InputTokens: 3}})
+
+	usage := ctx.Stats().Usage()
+	if usage["dear"].Cost != 30 {
+		t.Errorf("expected the answering model's costs, got %+v", usage)
+	}
+}
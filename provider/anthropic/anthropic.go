@@ -0,0 +1,619 @@
+// Package anthropic provides an implementation of the provider interface
+// for Anthropic's Messages API.
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hlfshell/gotonomy/embedding"
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/provider"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// Constants
+const (
+	defaultBaseURL     = "https://api.anthropic.com/v1"
+	defaultTimeoutSecs = 60
+	defaultMaxRetries  = 3
+
+	// apiVersion is the Messages API version we speak
+	apiVersion = "2023-06-01"
+	// defaultMaxTokens is used as max_tokens, which the API requires
+	defaultMaxTokens = 4096
+)
+
+//go:embed models.yaml
+var defaultModelCards []byte
+
+func init() {
+	if err := model.RegisterDefaultCards("anthropic/models.yaml", defaultModelCards); err != nil {
+		panic(err)
+	}
+}
+
+// Anthropic implements the provider.Provider interface for Anthropic.
+type Anthropic struct {
+	config     provider.Config
+	baseURL    string
+	httpClient *http.Client
+	cards      *model.CardRegistry
+
+	// models added with AddModel, keyed by model name
+	modelCards   map[string]model.ModelDescription
+	modelCardsMu sync.RWMutex
+
+	// middleware per model name, built from config on first use so that
+	// rate limits are shared by every instance of a model
+	middleware   map[string][]model.Middleware
+	middlewareMu sync.Mutex
+}
+
+// NewAnthropicProvider creates a new Anthropic provider with the given configuration.
+// Failed requests are retried MaxRetries times, or 3 if it is zero; a
+// negative MaxRetries disables retries.
+func NewAnthropicProvider(config provider.Config) (provider.Provider, error) {
+	// Validate the API key
+	if config.APIKey == "" {
+		return nil, errors.New("API key is required for Anthropic provider")
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	cards, err := config.ModelCards()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Anthropic{
+		config:     config,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: config.Timeout()},
+		cards:      cards,
+		modelCards: make(map[string]model.ModelDescription),
+		middleware: make(map[string][]model.Middleware),
+	}, nil
+}
+
+// Name returns the name of the provider.
+func (p *Anthropic) Name() string {
+	return "Anthropic"
+}
+
+// Description returns a human-readable description of the provider.
+func (p *Anthropic) Description() string {
+	return "Anthropic provides the Claude family of language and vision-language models."
+}
+
+// DefaultConfig returns the default configuration for the provider.
+func (p *Anthropic) DefaultConfig() provider.Config {
+	return provider.Config{
+		BaseURL:        defaultBaseURL,
+		TimeoutSeconds: defaultTimeoutSecs,
+		MaxRetries:     defaultMaxRetries,
+	}
+}
+
+// ListAvailableModels returns the models known from model cards, plus any
+// others the API reports.
+func (p *Anthropic) ListAvailableModels(ctx context.Context) ([]model.ModelDescription, error) {
+	modelDescriptions := p.cardList()
+	known := make(map[string]bool, len(modelDescriptions))
+	for _, desc := range modelDescriptions {
+		known[desc.Model] = true
+	}
+
+	var listing struct {
+		Data []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/models", nil, &listing); err != nil {
+		// If the API call fails but we have model cards, return those
+		if len(modelDescriptions) > 0 {
+			return modelDescriptions, nil
+		}
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	for _, m := range listing.Data {
+		if known[m.ID] {
+			continue
+		}
+		// Every current Claude model has a 200k window and supports tools
+		// and images; model cards refine this where it differs.
+		modelDescriptions = append(modelDescriptions, model.ModelDescription{
+			Model:            m.ID,
+			Provider:         "anthropic",
+			MaxContextTokens: 200000,
+			Description:      m.DisplayName,
+			CanUseTools:      true,
+			AcceptsFileTypes: []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+		})
+	}
+
+	return modelDescriptions, nil
+}
+
+// card returns the card for the named model; models added with AddModel
+// take precedence over the card registry.
+func (p *Anthropic) card(modelName string) (model.ModelDescription, bool) {
+	p.modelCardsMu.RLock()
+	desc, ok := p.modelCards[modelName]
+	p.modelCardsMu.RUnlock()
+	if ok {
+		return desc, true
+	}
+	return p.cards.Get("anthropic", modelName)
+}
+
+// cardList returns every model with a card, including added models.
+func (p *Anthropic) cardList() []model.ModelDescription {
+	p.modelCardsMu.RLock()
+	defer p.modelCardsMu.RUnlock()
+	var descs []model.ModelDescription
+	for _, desc := range p.cards.List("anthropic") {
+		if _, added := p.modelCards[desc.Model]; !added {
+			descs = append(descs, desc)
+		}
+	}
+	for _, desc := range p.modelCards {
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+// ListAvailableEmbeddingModels returns no models; Anthropic does not
+// offer embeddings.
+func (p *Anthropic) ListAvailableEmbeddingModels(ctx context.Context) ([]embedding.ModelInfo, error) {
+	return []embedding.ModelInfo{}, nil
+}
+
+// GetModel returns a model instance by name.
+func (p *Anthropic) GetModel(ctx context.Context, modelName string) (model.Model, error) {
+	// First check model cards
+	modelInfo, found := p.card(modelName)
+	if !found {
+		// Fallback to listing from API
+		models, err := p.ListAvailableModels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+
+		for _, info := range models {
+			if info.Model == modelName {
+				modelInfo = info
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w: %s", model.ErrModelNotFound, modelName)
+		}
+	}
+
+	// Create the model, wrapped in the retry and rate limit middleware
+	// the provider config calls for
+	var m model.Model = &AnthropicModel{
+		provider:  p,
+		modelInfo: modelInfo,
+	}
+	return model.Chain(m, p.modelMiddleware(modelName)...), nil
+}
+
+// modelMiddleware returns the middleware for the named model, creating it
+// from the provider config on first use.
+func (p *Anthropic) modelMiddleware(modelName string) []model.Middleware {
+	p.middlewareMu.Lock()
+	defer p.middlewareMu.Unlock()
+	middleware, ok := p.middleware[modelName]
+	if !ok {
+		middleware = p.config.Middleware(defaultMaxRetries)
+		p.middleware[modelName] = middleware
+	}
+	return middleware
+}
+
+// AddModel allows you to add a model instance by ModelDescription.
+// This is useful for adding custom models or models discovered at runtime.
+func (p *Anthropic) AddModel(ctx context.Context, modelDesc model.ModelDescription) error {
+	// Validate the model description
+	if err := modelDesc.Validate(); err != nil {
+		return fmt.Errorf("invalid model description: %w", err)
+	}
+
+	// Ensure the provider matches
+	if modelDesc.Provider != "anthropic" {
+		return fmt.Errorf("model provider mismatch: expected 'anthropic', got '%s'", modelDesc.Provider)
+	}
+
+	p.modelCardsMu.Lock()
+	p.modelCards[modelDesc.Model] = modelDesc
+	p.modelCardsMu.Unlock()
+	return nil
+}
+
+// GetEmbeddingModel always fails; Anthropic does not offer embeddings.
+func (p *Anthropic) GetEmbeddingModel(ctx context.Context, modelName string) (embedding.EmbeddingModel, error) {
+	return nil, fmt.Errorf("%w: Anthropic does not offer embedding models", model.ErrModelNotFound)
+}
+
+// do sends a request to the API and decodes the JSON response into out.
+// Failed calls are returned as *model.ProviderError.
+func (p *Anthropic) do(ctx context.Context, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-api-key", p.config.APIKey)
+	req.Header.Set("anthropic-version", apiVersion)
+	req.Header.Set("content-type", "application/json")
+	for key, value := range p.config.AdditionalHeaders {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return &model.ProviderError{Provider: "anthropic", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// responseError converts a failed API response into a *model.ProviderError.
+func responseError(resp *http.Response) error {
+	var apiErr struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	message := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error.Message != "" {
+		message = fmt.Sprintf("%s: %s", apiErr.Error.Type, apiErr.Error.Message)
+	}
+
+	err := errors.New(message)
+	if strings.Contains(message, "prompt is too long") {
+		err = fmt.Errorf("%w: %s", model.ErrContextTooLong, message)
+	}
+	return &model.ProviderError{
+		Provider:   "anthropic",
+		StatusCode: resp.StatusCode,
+		RetryAfter: model.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		Err:        err,
+	}
+}
+
+// AnthropicModel implements the model.Model interface for Anthropic.
+type AnthropicModel struct {
+	provider  *Anthropic
+	modelInfo model.ModelDescription
+}
+
+// Ensure AnthropicModel satisfies the model.Model interface.
+var _ model.Model = (*AnthropicModel)(nil)
+
+// Description returns information about the model.
+func (m *AnthropicModel) Description() model.ModelDescription {
+	return m.modelInfo
+}
+
+// Complete generates a completion for the given request.
+func (m *AnthropicModel) Complete(ctx *tool.Context, request model.CompletionRequest) (model.CompletionResponse, error) {
+	params, err := m.buildMessagesRequest(request)
+	if err != nil {
+		return model.CompletionResponse{}, err
+	}
+
+	var resp messagesResponse
+	if err := m.provider.do(ctx, http.MethodPost, "/messages", params, &resp); err != nil {
+		return model.CompletionResponse{}, fmt.Errorf("failed to create completion: %w", err)
+	}
+
+	return m.responseFromMessages(resp)
+}
+
+// messagesRequest is the body of a Messages API call.
+type messagesRequest struct {
+	Model       string           `json:"model"`
+	MaxTokens   int              `json:"max_tokens"`
+	System      string           `json:"system,omitempty"`
+	Messages    []message        `json:"messages"`
+	Tools       []toolDefinition `json:"tools,omitempty"`
+	Temperature *float64         `json:"temperature,omitempty"`
+	TopP        *float64         `json:"top_p,omitempty"`
+	Stop        []string         `json:"stop_sequences,omitempty"`
+	ToolChoice  *toolChoice      `json:"tool_choice,omitempty"`
+}
+
+// toolChoice is one of auto, any, tool (with Name) or none.
+type toolChoice struct {
+	Type                   string `json:"type"`
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// contentBlock is any of the text, image, document, tool_use and
+// tool_result blocks; only the fields relevant to Type are set.
+type contentBlock struct {
+	Type   string  `json:"type"`
+	Text   string  `json:"text,omitempty"`
+	Source *source `json:"source,omitempty"`
+
+	// tool_use; input is required, if empty, on those we send
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input *map[string]any `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+// source holds the content of an image or document block.
+type source struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type toolDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type messagesResponse struct {
+	ID         string         `json:"id"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// applyConfig sets the request parameters the model config calls for.
+// The API has no seed or penalty parameters, so those are ignored.
+func applyConfig(params *messagesRequest, config model.ModelConfig, hasTools bool) {
+	if config.MaxTokens > 0 {
+		params.MaxTokens = config.MaxTokens
+	}
+	if config.Temperature > 0 {
+		temperature := float64(config.Temperature)
+		params.Temperature = &temperature
+	}
+	if config.TopP > 0 {
+		topP := float64(config.TopP)
+		params.TopP = &topP
+	}
+	params.Stop = config.Stop
+
+	// tool_choice may only be sent alongside tools
+	if !hasTools {
+		return
+	}
+	choice := &toolChoice{Type: "auto"}
+	switch config.ToolChoice {
+	case "", model.ToolChoiceAuto:
+	case model.ToolChoiceNone:
+		choice.Type = "none"
+	case model.ToolChoiceRequired:
+		choice.Type = "any"
+	default:
+		choice.Type, choice.Name = "tool", config.ToolChoice.Tool()
+	}
+	if config.ParallelToolCalls != nil && !*config.ParallelToolCalls {
+		choice.DisableParallelToolUse = true
+	}
+	if config.ToolChoice != "" || choice.DisableParallelToolUse {
+		params.ToolChoice = choice
+	}
+}
+
+// buildMessagesRequest validates the request and converts it into a
+// Messages API request. System messages that open the conversation are
+// hoisted into the system prompt, as the API has no system role; any
+// later system messages are sent as user text so their position in the
+// conversation is kept. Assistant tool calls become tool_use blocks and
+// tool messages tool_result blocks, and consecutive messages of the same
+// role are merged into one turn.
+func (m *AnthropicModel) buildMessagesRequest(request model.CompletionRequest) (messagesRequest, error) {
+	// Validate the request, including content parts against what the model accepts
+	if err := request.ValidateFor(m.modelInfo); err != nil {
+		return messagesRequest{}, fmt.Errorf("invalid request: %w", err)
+	}
+
+	params := messagesRequest{
+		Model:     m.modelInfo.Model,
+		MaxTokens: defaultMaxTokens,
+	}
+	applyConfig(&params, request.Config, len(request.Tools) > 0)
+
+	var system []string
+	for _, msg := range request.Messages {
+		var role string
+		var blocks []contentBlock
+
+		switch msg.Role {
+		case model.RoleSystem:
+			if len(params.Messages) == 0 {
+				system = append(system, msg.Text())
+				continue
+			}
+			role, blocks = "user", textBlocks(msg.Text())
+		case model.RoleUser:
+			converted, err := contentBlocks(msg)
+			if err != nil {
+				return messagesRequest{}, err
+			}
+			role, blocks = "user", converted
+		case model.RoleAssistant:
+			for _, part := range msg.Parts {
+				if part.Type != model.PartText {
+					return messagesRequest{}, fmt.Errorf("%w: %s messages only support text parts", model.ErrUnsupportedContent, msg.Role)
+				}
+			}
+			role, blocks = "assistant", textBlocks(msg.Text())
+			for _, call := range msg.ToolCalls {
+				input := map[string]any(call.Arguments)
+				if input == nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, contentBlock{
+					Type:  "tool_use",
+					ID:    call.ID,
+					Name:  call.Name,
+					Input: &input,
+				})
+			}
+		case model.RoleTool:
+			// Tool results need the ID of the tool_use they answer
+			if msg.ToolCallID == "" {
+				continue
+			}
+			role = "user"
+			blocks = []contentBlock{{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Text(),
+			}}
+		default:
+			return messagesRequest{}, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+		if last := len(params.Messages) - 1; last >= 0 && params.Messages[last].Role == role {
+			params.Messages[last].Content = append(params.Messages[last].Content, blocks...)
+			continue
+		}
+		params.Messages = append(params.Messages, message{Role: role, Content: blocks})
+	}
+	params.System = strings.Join(system, "\n\n")
+
+	for _, t := range request.Tools {
+		params.Tools = append(params.Tools, toolDefinition{
+			Name:        t.Name(),
+			Description: t.Description(),
+			InputSchema: tool.ParametersToJSONSchema(t.Parameters()),
+		})
+	}
+
+	return params, nil
+}
+
+// textBlocks returns a single text block, or none for empty text since
+// the API rejects empty text blocks.
+func textBlocks(text string) []contentBlock {
+	if text == "" {
+		return nil
+	}
+	return []contentBlock{{Type: "text", Text: text}}
+}
+
+// contentBlocks converts a user message into content blocks. Images and
+// PDFs are sent inline as base64 or by URL.
+func contentBlocks(msg model.Message) ([]contentBlock, error) {
+	if len(msg.Parts) == 0 {
+		return textBlocks(msg.Content), nil
+	}
+
+	blocks := make([]contentBlock, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case model.PartText:
+			blocks = append(blocks, textBlocks(part.Text)...)
+		case model.PartImage, model.PartFile:
+			blockType := "image"
+			if part.Type == model.PartFile {
+				blockType = "document"
+			}
+			var src source
+			switch {
+			case len(part.Data) > 0:
+				src = source{Type: "base64", MediaType: part.MIMEType, Data: base64.StdEncoding.EncodeToString(part.Data)}
+			case part.URL != "":
+				src = source{Type: "url", URL: part.URL}
+			default:
+				return nil, fmt.Errorf("%w: Anthropic does not accept files by ID", model.ErrUnsupportedContent)
+			}
+			blocks = append(blocks, contentBlock{Type: blockType, Source: &src})
+		}
+	}
+	return blocks, nil
+}
+
+// responseFromMessages converts a Messages API response into our generic
+// format.
+func (m *AnthropicModel) responseFromMessages(resp messagesResponse) (model.CompletionResponse, error) {
+	var text strings.Builder
+	var toolCalls []model.ToolCall
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			var args tool.Arguments
+			if block.Input != nil {
+				args = tool.Arguments(*block.Input)
+			}
+			toolCalls = append(toolCalls, model.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: args,
+			})
+		}
+	}
+
+	return model.CompletionResponse{
+		Text:      text.String(),
+		ToolCalls: toolCalls,
+		UsageStats: model.UsageStats{
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+		},
+		Model: m.modelInfo.Model,
+	}, nil
+}
@@ -0,0 +1,332 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/provider"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// newTestModel spins up a provider against the given handler and returns
+// a model registered under the name "test-model".
+func newTestModel(t *testing.T, handler http.HandlerFunc) *AnthropicModel {
+	t.Helper()
+	return getTestModel(t, provider.Config{MaxRetries: -1}, handler).(*AnthropicModel)
+}
+
+// getTestModel is newTestModel with a custom provider config, returning
+// the model as GetModel does (including any middleware).
+func getTestModel(t *testing.T, config provider.Config, handler http.HandlerFunc) model.Model {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config.APIKey = "test-key"
+	config.BaseURL = server.URL
+	p, err := NewAnthropicProvider(config)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	desc := model.ModelDescription{
+		Model:            "test-model",
+		Provider:         "anthropic",
+		MaxContextTokens: 200000,
+		CanUseTools:      true,
+		AcceptsFileTypes: []string{"image/png"},
+	}
+	if err := p.AddModel(context.Background(), desc); err != nil {
+		t.Fatalf("failed to add model: %v", err)
+	}
+	m, err := p.GetModel(context.Background(), "test-model")
+	if err != nil {
+		t.Fatalf("failed to get model: %v", err)
+	}
+	return m
+}
+
+func TestComplete(t *testing.T) {
+	var body messagesRequest
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != apiVersion {
+			t.Errorf("missing auth or version headers: %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"model": "test-model",
+			"content": [
+				{"type": "text", "text": "Let me look that up."},
+				{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": {"q": "go"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 12, "output_tokens": 8}
+		}`)
+	})
+
+	lookup := tool.NewTool[string]("lookup", "Looks things up", []tool.Parameter{
+		tool.NewParameter[string]("q", "The query", true, "", nil),
+	}, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+
+	resp, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{
+			{Role: model.RoleSystem, Content: "You are helpful."},
+			{Role: model.RoleSystem, Content: "Be brief."},
+			{Role: model.RoleUser, Parts: []model.ContentPart{
+				model.TextPart("What is this?"),
+				model.ImagePart([]byte("png"), "image/png"),
+			}},
+			{Role: model.RoleAssistant, Content: "A gopher.", ToolCalls: []model.ToolCall{
+				{ID: "toolu_a", Name: "lookup", Arguments: tool.Arguments{"q": "gopher"}},
+				{ID: "toolu_b", Name: "lookup"},
+			}},
+			{Role: model.RoleTool, Content: "result one", ToolCallID: "toolu_a"},
+			{Role: model.RoleTool, Content: "result two", ToolCallID: "toolu_b"},
+		},
+		Tools: []tool.Tool{lookup},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Request mapping
+	if body.System != "You are helpful.\n\nBe brief." {
+		t.Errorf("expected leading system messages to be hoisted, got %q", body.System)
+	}
+	if body.MaxTokens != defaultMaxTokens {
+		t.Errorf("expected max_tokens %d, got %d", defaultMaxTokens, body.MaxTokens)
+	}
+	if len(body.Messages) != 3 {
+		t.Fatalf("expected user, assistant and merged tool result turns, got %+v", body.Messages)
+	}
+	user := body.Messages[0]
+	if user.Role != "user" || len(user.Content) != 2 || user.Content[1].Type != "image" {
+		t.Fatalf("unexpected user turn: %+v", user)
+	}
+	if src := user.Content[1].Source; src == nil || src.Type != "base64" || src.MediaType != "image/png" || src.Data != "cG5n" {
+		t.Errorf("unexpected image source: %+v", src)
+	}
+	assistant := body.Messages[1]
+	if len(assistant.Content) != 3 || assistant.Content[1].Type != "tool_use" || assistant.Content[1].ID != "toolu_a" ||
+		assistant.Content[1].Input == nil || (*assistant.Content[1].Input)["q"] != "gopher" {
+		t.Fatalf("unexpected assistant turn: %+v", assistant)
+	}
+	if input := assistant.Content[2].Input; input == nil || len(*input) != 0 {
+		t.Errorf("expected an empty input for a call without arguments, got %v", input)
+	}
+	results := body.Messages[2]
+	if results.Role != "user" || len(results.Content) != 2 ||
+		results.Content[0].Type != "tool_result" || results.Content[0].ToolUseID != "toolu_a" ||
+		results.Content[1].ToolUseID != "toolu_b" {
+		t.Errorf("unexpected tool result turn: %+v", results)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Name != "lookup" || body.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("unexpected tools: %+v", body.Tools)
+	}
+
+	// Response mapping
+	if resp.Text != "Let me look that up." {
+		t.Errorf("unexpected text %q", resp.Text)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_1" || resp.ToolCalls[0].Name != "lookup" || resp.ToolCalls[0].Arguments["q"] != "go" {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.UsageStats.InputTokens != 12 || resp.UsageStats.OutputTokens != 8 {
+		t.Errorf("unexpected usage: %+v", resp.UsageStats)
+	}
+	if resp.Model != "test-model" {
+		t.Errorf("expected model name in response, got %q", resp.Model)
+	}
+}
+
+func TestComplete_LaterSystemMessagesStayInPlace(t *testing.T) {
+	var body messagesRequest
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"usage":{}}`)
+	})
+
+	_, err := m.Complete(nil, model.CompletionRequest{Messages: []model.Message{
+		{Role: model.RoleUser, Content: "hi"},
+		{Role: model.RoleAssistant, Content: "hello"},
+		{Role: model.RoleSystem, Content: "Tool lookup returned: 42"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body.System != "" || len(body.Messages) != 3 || body.Messages[2].Role != "user" {
+		t.Fatalf("expected the late system message as a user turn, got %+v", body)
+	}
+}
+
+func TestComplete_ModelConfig(t *testing.T) {
+	var body messagesRequest
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"usage":{}}`)
+	})
+	lookup := tool.NewTool[string]("lookup", "Looks things up", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+
+	parallel := false
+	_, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+		Tools:    []tool.Tool{lookup},
+		Config: model.ModelConfig{
+			MaxTokens:         512,
+			Stop:              []string{"END"},
+			ToolChoice:        model.ToolChoiceRequired,
+			ParallelToolCalls: &parallel,
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body.MaxTokens != 512 || len(body.Stop) != 1 {
+		t.Errorf("unexpected request: %+v", body)
+	}
+	if body.ToolChoice == nil || body.ToolChoice.Type != "any" || !body.ToolChoice.DisableParallelToolUse {
+		t.Errorf("unexpected tool_choice: %+v", body.ToolChoice)
+	}
+}
+
+func TestComplete_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		check   func(error) bool
+		retryIn time.Duration
+	}{
+		{
+			name:    "rate limited",
+			status:  http.StatusTooManyRequests,
+			body:    `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+			check:   func(err error) bool { return errors.Is(err, model.ErrRateLimitExceeded) },
+			retryIn: 3 * time.Second,
+		},
+		{
+			name:   "prompt too long",
+			status: http.StatusBadRequest,
+			body:   `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 250000 tokens > 200000 maximum"}}`,
+			check:  func(err error) bool { return errors.Is(err, model.ErrContextTooLong) && !model.IsRetryable(err) },
+		},
+		{
+			name:   "overloaded",
+			status: 529,
+			body:   `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			check:  model.IsRetryable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "3")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+			_, err := m.Complete(nil, model.CompletionRequest{
+				Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+			})
+			if err == nil || !tt.check(err) {
+				t.Fatalf("unexpected error classification: %v", err)
+			}
+			if tt.retryIn > 0 && model.RetryAfter(err) != tt.retryIn {
+				t.Errorf("expected Retry-After %s, got %s", tt.retryIn, model.RetryAfter(err))
+			}
+		})
+	}
+}
+
+func TestGetModel_RetriesByDefault(t *testing.T) {
+	calls := 0
+	m := getTestModel(t, provider.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(529)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+	})
+
+	resp, err := m.Complete(tool.NewContext(context.Background()), model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Text != "ok" || calls != 2 {
+		t.Fatalf("expected success after one retry, got %q after %d calls", resp.Text, calls)
+	}
+}
+
+func TestListAvailableModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"claude-carded","display_name":"Claude Carded","type":"model"},{"id":"claude-test","display_name":"Claude Test","type":"model"}]}`)
+	}))
+	defer server.Close()
+
+	cards := model.NewCardRegistry()
+	err := cards.Load("cards.yaml", []byte(`
+model: claude-carded
+provider: anthropic
+max_context_tokens: 100000
+costs: {input: 0.000001, output: 0.000002}
+`))
+	if err != nil {
+		t.Fatalf("failed to load cards: %v", err)
+	}
+
+	p, err := NewAnthropicProvider(provider.Config{APIKey: "test-key", BaseURL: server.URL, Cards: cards})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	models, err := p.ListAvailableModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(models) != 2 || models[0].Model != "claude-carded" || models[1].Model != "claude-test" || models[1].Validate() != nil {
+		t.Fatalf("unexpected models: %+v", models)
+	}
+
+	m, err := p.GetModel(context.Background(), "claude-carded")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if desc := m.Description(); desc.MaxContextTokens != 100000 || desc.Costs.Output != 0.000002 {
+		t.Errorf("expected the model card to describe the model, got %+v", desc)
+	}
+
+	if _, err := p.GetModel(context.Background(), "missing"); !errors.Is(err, model.ErrModelNotFound) {
+		t.Errorf("expected ErrModelNotFound, got %v", err)
+	}
+	if _, err := p.GetEmbeddingModel(context.Background(), "any"); err == nil {
+		t.Errorf("expected embeddings to be unsupported")
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/hlfshell/gotonomy/provider/anthropic
+
+go 1.24.2
+
+require github.com/hlfshell/gotonomy v0.1.0
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/hlfshell/structured-parse/go v1.0.3 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/hlfshell/gotonomy => ../..
@@ -0,0 +1,701 @@
+// Package ollama provides an implementation of the provider interface for
+// locally hosted models served by Ollama's native API. Installed models
+// and their capabilities are discovered from the server itself.
+//
+// Servers exposing an OpenAI-compatible API instead, such as llama.cpp's
+// llama-server, can be used through the openai provider by setting
+// provider.Config.BaseURL.
+package ollama
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hlfshell/gotonomy/embedding"
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/provider"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// Constants
+const (
+	defaultBaseURL     = "http://localhost:11434"
+	defaultTimeoutSecs = 300
+	defaultMaxRetries  = 1
+
+	// defaultContextTokens is assumed when the server does not report a
+	// model's context length
+	defaultContextTokens = 2048
+)
+
+//go:embed models.yaml
+var defaultModelCards []byte
+
+func init() {
+	if err := model.RegisterDefaultCards("ollama/models.yaml", defaultModelCards); err != nil {
+		panic(err)
+	}
+}
+
+// Capabilities reported by /api/show
+const (
+	capabilityCompletion = "completion"
+	capabilityTools      = "tools"
+	capabilityVision     = "vision"
+	capabilityEmbedding  = "embedding"
+)
+
+// Ollama implements the provider.Provider interface for an Ollama server.
+type Ollama struct {
+	config     provider.Config
+	baseURL    string
+	httpClient *http.Client
+	cards      *model.CardRegistry
+
+	// models added with AddModel, keyed by model name
+	modelCards   map[string]model.ModelDescription
+	modelCardsMu sync.RWMutex
+
+	// middleware per model name, built from config on first use so that
+	// rate limits are shared by every instance of a model
+	middleware   map[string][]model.Middleware
+	middlewareMu sync.Mutex
+}
+
+// NewOllamaProvider creates a new Ollama provider with the given
+// configuration. No API key is required; BaseURL defaults to a local
+// server. Failed requests are retried MaxRetries times, or once if it is
+// zero; a negative MaxRetries disables retries.
+func NewOllamaProvider(config provider.Config) (provider.Provider, error) {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	cards, err := config.ModelCards()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ollama{
+		config:     config,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: config.Timeout()},
+		cards:      cards,
+		modelCards: make(map[string]model.ModelDescription),
+		middleware: make(map[string][]model.Middleware),
+	}, nil
+}
+
+// Name returns the name of the provider.
+func (p *Ollama) Name() string {
+	return "Ollama"
+}
+
+// Description returns a human-readable description of the provider.
+func (p *Ollama) Description() string {
+	return "Ollama serves open models such as Llama, Mistral and Qwen locally."
+}
+
+// DefaultConfig returns the default configuration for the provider.
+func (p *Ollama) DefaultConfig() provider.Config {
+	return provider.Config{
+		BaseURL:        defaultBaseURL,
+		TimeoutSeconds: defaultTimeoutSecs,
+		MaxRetries:     defaultMaxRetries,
+	}
+}
+
+// installedModel is a model installed on the server along with the
+// metadata reported by /api/show.
+type installedModel struct {
+	Name         string
+	Family       string
+	Capabilities []string
+	ModelInfo    map[string]any
+}
+
+// has returns true if the server reports the given capability.
+func (m installedModel) has(capability string) bool {
+	return slices.Contains(m.Capabilities, capability)
+}
+
+// isEmbedding returns true for embedding-only models.
+func (m installedModel) isEmbedding() bool {
+	return m.has(capabilityEmbedding) && !m.has(capabilityCompletion)
+}
+
+// metadata returns the integer model_info value with the given suffix,
+// e.g. "context_length" for "llama.context_length".
+func (m installedModel) metadata(suffix string) int {
+	for key, value := range m.ModelInfo {
+		if !strings.HasSuffix(key, "."+suffix) {
+			continue
+		}
+		if number, ok := value.(float64); ok {
+			return int(number)
+		}
+	}
+	return 0
+}
+
+// description converts the model into a ModelDescription.
+func (m installedModel) description() model.ModelDescription {
+	desc := model.ModelDescription{
+		Model:            m.Name,
+		Provider:         "ollama",
+		MaxContextTokens: m.metadata("context_length"),
+		Description:      fmt.Sprintf("Locally served %s model", m.Name),
+		CanUseTools:      m.has(capabilityTools),
+		// Ollama constrains output to a JSON schema via its format field
+		StructuredOutput: true,
+	}
+	if m.Family != "" {
+		desc.Description = fmt.Sprintf("Locally served %s model (%s family)", m.Name, m.Family)
+	}
+	if desc.MaxContextTokens == 0 {
+		desc.MaxContextTokens = defaultContextTokens
+	}
+	if m.has(capabilityVision) {
+		desc.AcceptsFileTypes = []string{"image/jpeg", "image/png"}
+	}
+	return desc
+}
+
+// installedModels lists the models installed on the server along with
+// their metadata.
+func (p *Ollama) installedModels(ctx context.Context) ([]installedModel, error) {
+	var tags struct {
+		Models []struct {
+			Name    string `json:"name"`
+			Details struct {
+				Family string `json:"family"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+
+	installed := make([]installedModel, 0, len(tags.Models))
+	for _, tag := range tags.Models {
+		var show struct {
+			Capabilities []string       `json:"capabilities"`
+			ModelInfo    map[string]any `json:"model_info"`
+		}
+		if err := p.do(ctx, http.MethodPost, "/api/show", map[string]string{"model": tag.Name}, &show); err != nil {
+			return nil, fmt.Errorf("failed to describe model %s: %w", tag.Name, err)
+		}
+
+		m := installedModel{
+			Name:         tag.Name,
+			Family:       tag.Details.Family,
+			Capabilities: show.Capabilities,
+			ModelInfo:    show.ModelInfo,
+		}
+		// Servers predating capability reporting only ran chat models,
+		// bar the embedding models they had to be told about by name
+		if len(m.Capabilities) == 0 {
+			m.Capabilities = []string{capabilityCompletion}
+			if strings.Contains(tag.Name, "embed") {
+				m.Capabilities = []string{capabilityEmbedding}
+			}
+		}
+		installed = append(installed, m)
+	}
+	return installed, nil
+}
+
+// ListAvailableModels returns the chat models installed on the server,
+// with context length, tool and vision support as reported by the server.
+// Model cards, including those added via AddModel, take precedence.
+func (p *Ollama) ListAvailableModels(ctx context.Context) ([]model.ModelDescription, error) {
+	p.modelCardsMu.RLock()
+	modelDescriptions := make([]model.ModelDescription, 0, len(p.modelCards))
+	for _, desc := range p.modelCards {
+		modelDescriptions = append(modelDescriptions, desc)
+	}
+	p.modelCardsMu.RUnlock()
+
+	installed, err := p.installedModels(ctx)
+	if err != nil {
+		// If the server is unreachable but we have model cards, return those
+		if len(modelDescriptions) > 0 {
+			return modelDescriptions, nil
+		}
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	for _, m := range installed {
+		if m.isEmbedding() {
+			continue
+		}
+		p.modelCardsMu.RLock()
+		_, added := p.modelCards[m.Name]
+		p.modelCardsMu.RUnlock()
+		if added {
+			continue
+		}
+		if desc, ok := p.registryCard(m.Name); ok {
+			modelDescriptions = append(modelDescriptions, desc)
+			continue
+		}
+		modelDescriptions = append(modelDescriptions, m.description())
+	}
+	return modelDescriptions, nil
+}
+
+// card returns the card for the named model; models added with AddModel
+// take precedence over the card registry.
+func (p *Ollama) card(modelName string) (model.ModelDescription, bool) {
+	p.modelCardsMu.RLock()
+	desc, ok := p.modelCards[modelName]
+	p.modelCardsMu.RUnlock()
+	if ok {
+		return desc, true
+	}
+	return p.registryCard(modelName)
+}
+
+// registryCard looks the named model up in the card registry. Ollama
+// names carry a tag, so cards written for the untagged name also match
+// the default ":latest" tag.
+func (p *Ollama) registryCard(modelName string) (model.ModelDescription, bool) {
+	if desc, ok := p.cards.Get("ollama", modelName); ok {
+		return desc, true
+	}
+	base, found := strings.CutSuffix(modelName, ":latest")
+	if !found {
+		return model.ModelDescription{}, false
+	}
+	desc, ok := p.cards.Get("ollama", base)
+	desc.Model = modelName
+	return desc, ok
+}
+
+// ListAvailableEmbeddingModels returns the embedding models installed on
+// the server, with their dimensions as reported by the server.
+func (p *Ollama) ListAvailableEmbeddingModels(ctx context.Context) ([]embedding.ModelInfo, error) {
+	installed, err := p.installedModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedding models: %w", err)
+	}
+
+	embeddingModels := []embedding.ModelInfo{}
+	for _, m := range installed {
+		if !m.isEmbedding() {
+			continue
+		}
+		embeddingModels = append(embeddingModels, embedding.ModelInfo{
+			Name:                  m.Name,
+			Provider:              "ollama",
+			Dimensions:            m.metadata("embedding_length"),
+			SupportedContentTypes: []embedding.ContentType{embedding.TextContent},
+			Description:           fmt.Sprintf("Locally served %s embedding model", m.Name),
+		})
+	}
+	return embeddingModels, nil
+}
+
+// GetModel returns a model instance by name.
+func (p *Ollama) GetModel(ctx context.Context, modelName string) (model.Model, error) {
+	// First check model cards
+	modelInfo, found := p.card(modelName)
+	if !found {
+		// Fallback to listing from the server
+		models, err := p.ListAvailableModels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+
+		for _, info := range models {
+			if info.Model == modelName {
+				modelInfo = info
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w: %s", model.ErrModelNotFound, modelName)
+		}
+	}
+
+	// Create the model, wrapped in the retry and rate limit middleware
+	// the provider config calls for
+	var m model.Model = &OllamaModel{
+		provider:  p,
+		modelInfo: modelInfo,
+	}
+	return model.Chain(m, p.modelMiddleware(modelName)...), nil
+}
+
+// modelMiddleware returns the middleware for the named model, creating it
+// from the provider config on first use.
+func (p *Ollama) modelMiddleware(modelName string) []model.Middleware {
+	p.middlewareMu.Lock()
+	defer p.middlewareMu.Unlock()
+	middleware, ok := p.middleware[modelName]
+	if !ok {
+		middleware = p.config.Middleware(defaultMaxRetries)
+		p.middleware[modelName] = middleware
+	}
+	return middleware
+}
+
+// AddModel allows you to add a model instance by ModelDescription.
+// This is useful for adding custom models or models discovered at runtime.
+func (p *Ollama) AddModel(ctx context.Context, modelDesc model.ModelDescription) error {
+	// Validate the model description
+	if err := modelDesc.Validate(); err != nil {
+		return fmt.Errorf("invalid model description: %w", err)
+	}
+
+	// Ensure the provider matches
+	if modelDesc.Provider != "ollama" {
+		return fmt.Errorf("model provider mismatch: expected 'ollama', got '%s'", modelDesc.Provider)
+	}
+
+	p.modelCardsMu.Lock()
+	p.modelCards[modelDesc.Model] = modelDesc
+	p.modelCardsMu.Unlock()
+	return nil
+}
+
+// GetEmbeddingModel returns an embedding model instance by name.
+func (p *Ollama) GetEmbeddingModel(ctx context.Context, modelName string) (embedding.EmbeddingModel, error) {
+	models, err := p.ListAvailableEmbeddingModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range models {
+		if info.Name == modelName {
+			return &OllamaEmbeddingModel{
+				provider:  p,
+				modelInfo: info,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: embedding model %s", model.ErrModelNotFound, modelName)
+}
+
+// do sends a request to the server and decodes the JSON response into out.
+// Failed calls are returned as *model.ProviderError.
+func (p *Ollama) do(ctx context.Context, method, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.config.APIKey != "" {
+		// Ollama itself is unauthenticated, but it is often fronted by a proxy
+		req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	}
+	for key, value := range p.config.AdditionalHeaders {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return &model.ProviderError{Provider: "ollama", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// responseError converts a failed response into a *model.ProviderError.
+func responseError(resp *http.Response) error {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	message := strings.TrimSpace(string(raw))
+	if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error != "" {
+		message = apiErr.Error
+	}
+
+	err := errors.New(message)
+	if resp.StatusCode == http.StatusNotFound {
+		err = fmt.Errorf("%w: %s", model.ErrModelNotFound, message)
+	}
+	return &model.ProviderError{
+		Provider:   "ollama",
+		StatusCode: resp.StatusCode,
+		RetryAfter: model.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		Err:        err,
+	}
+}
+
+// OllamaModel implements the model.Model interface for Ollama models.
+type OllamaModel struct {
+	provider  *Ollama
+	modelInfo model.ModelDescription
+}
+
+// Ensure OllamaModel satisfies the model.Model interface.
+var _ model.Model = (*OllamaModel)(nil)
+
+// Description returns information about the model.
+func (m *OllamaModel) Description() model.ModelDescription {
+	return m.modelInfo
+}
+
+// Complete generates a completion for the given request.
+func (m *OllamaModel) Complete(ctx *tool.Context, request model.CompletionRequest) (model.CompletionResponse, error) {
+	params, err := m.buildChatRequest(request)
+	if err != nil {
+		return model.CompletionResponse{}, err
+	}
+
+	var resp chatResponse
+	if err := m.provider.do(ctx, http.MethodPost, "/api/chat", params, &resp); err != nil {
+		return model.CompletionResponse{}, fmt.Errorf("failed to create completion: %w", err)
+	}
+
+	toolCalls := make([]model.ToolCall, 0, len(resp.Message.ToolCalls))
+	for _, tc := range resp.Message.ToolCalls {
+		toolCalls = append(toolCalls, model.ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
+	}
+
+	return model.CompletionResponse{
+		Text:      resp.Message.Content,
+		ToolCalls: toolCalls,
+		UsageStats: model.UsageStats{
+			InputTokens:  resp.PromptEvalCount,
+			OutputTokens: resp.EvalCount,
+		},
+		Model: m.modelInfo.Model,
+	}, nil
+}
+
+// chatRequest is the body of an /api/chat call.
+type chatRequest struct {
+	Model    string         `json:"model"`
+	Messages []chatMessage  `json:"messages"`
+	Tools    []chatTool     `json:"tools,omitempty"`
+	Stream   bool           `json:"stream"`
+	Options  map[string]any `json:"options,omitempty"`
+	// Format is "json" or a JSON schema the response must follow
+	Format any `json:"format,omitempty"`
+}
+
+type chatMessage struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	Images    []string       `json:"images,omitempty"`
+	ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
+	// ToolName names the tool a tool message is the result of
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments tool.Arguments `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	Model           string      `json:"model"`
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+// options converts the model config into Ollama's sampling options. The
+// context window is set to contextTokens, the model's advertised
+// MaxContextTokens, as Ollama otherwise runs models with a small default
+// window and silently truncates longer prompts.
+func options(config model.ModelConfig, contextTokens int) map[string]any {
+	options := map[string]any{}
+	if contextTokens > 0 {
+		options["num_ctx"] = contextTokens
+	}
+	if config.Temperature > 0 {
+		options["temperature"] = config.Temperature
+	}
+	if config.MaxTokens > 0 {
+		options["num_predict"] = config.MaxTokens
+	}
+	if config.TopP > 0 {
+		options["top_p"] = config.TopP
+	}
+	if len(config.Stop) > 0 {
+		options["stop"] = config.Stop
+	}
+	if config.Seed != nil {
+		options["seed"] = *config.Seed
+	}
+	if config.PresencePenalty != 0 {
+		options["presence_penalty"] = config.PresencePenalty
+	}
+	if config.FrequencyPenalty != 0 {
+		options["frequency_penalty"] = config.FrequencyPenalty
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// buildChatRequest validates the request and converts it into an
+// /api/chat request. Images are sent inline as base64; Ollama cannot
+// fetch image URLs or accept other files.
+func (m *OllamaModel) buildChatRequest(request model.CompletionRequest) (chatRequest, error) {
+	// Validate the request, including content parts against what the model accepts
+	if err := request.ValidateFor(m.modelInfo); err != nil {
+		return chatRequest{}, fmt.Errorf("invalid request: %w", err)
+	}
+
+	params := chatRequest{
+		Model:    m.modelInfo.Model,
+		Messages: make([]chatMessage, 0, len(request.Messages)),
+	}
+	params.Options = options(request.Config, m.modelInfo.MaxContextTokens)
+	if format := request.Config.ResponseFormat; format != nil {
+		switch format.Type {
+		case model.ResponseFormatJSON:
+			params.Format = "json"
+		case model.ResponseFormatJSONSchema:
+			params.Format = format.Schema
+		}
+	}
+
+	// Ollama matches tool results to calls by the tool's name
+	toolNames := map[string]string{}
+	for _, msg := range request.Messages {
+		switch msg.Role {
+		case model.RoleSystem, model.RoleUser, model.RoleAssistant, model.RoleTool:
+		default:
+			return chatRequest{}, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+
+		converted := chatMessage{Role: string(msg.Role), Content: msg.Text()}
+		for _, call := range msg.ToolCalls {
+			var tc chatToolCall
+			tc.ID = call.ID
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Arguments
+			converted.ToolCalls = append(converted.ToolCalls, tc)
+			toolNames[call.ID] = call.Name
+		}
+		if msg.Role == model.RoleTool {
+			converted.ToolName = toolNames[msg.ToolCallID]
+		}
+		for _, part := range msg.Parts {
+			switch {
+			case part.Type == model.PartText:
+			case part.Type == model.PartImage && len(part.Data) > 0:
+				converted.Images = append(converted.Images, base64.StdEncoding.EncodeToString(part.Data))
+			default:
+				return chatRequest{}, fmt.Errorf("%w: Ollama only accepts inline images", model.ErrUnsupportedContent)
+			}
+		}
+		params.Messages = append(params.Messages, converted)
+	}
+
+	for _, t := range request.Tools {
+		// Ollama cannot force a tool call, but can be limited to the
+		// chosen tool or to none
+		choice := request.Config.ToolChoice
+		if choice == model.ToolChoiceNone || (choice.Tool() != "" && choice.Tool() != t.Name()) {
+			continue
+		}
+		var definition chatTool
+		definition.Type = "function"
+		definition.Function.Name = t.Name()
+		definition.Function.Description = t.Description()
+		definition.Function.Parameters = tool.ParametersToJSONSchema(t.Parameters())
+		params.Tools = append(params.Tools, definition)
+	}
+
+	return params, nil
+}
+
+// OllamaEmbeddingModel implements the embedding.EmbeddingModel interface
+// for Ollama.
+type OllamaEmbeddingModel struct {
+	provider  *Ollama
+	modelInfo embedding.ModelInfo
+}
+
+// GetInfo returns information about the embedding model.
+func (m *OllamaEmbeddingModel) GetInfo() embedding.ModelInfo {
+	return m.modelInfo
+}
+
+// Embed generates embeddings for the given request.
+func (m *OllamaEmbeddingModel) Embed(ctx context.Context, request embedding.EmbeddingRequest) (embedding.EmbeddingResponse, error) {
+	texts := make([]string, 0, len(request.Contents))
+	for _, content := range request.Contents {
+		if !m.SupportsContentType(content.Type) {
+			return embedding.EmbeddingResponse{}, fmt.Errorf("content type %s not supported by model %s", content.Type, m.modelInfo.Name)
+		}
+		texts = append(texts, content.Text)
+	}
+
+	var resp struct {
+		Embeddings      [][]float32 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+	}
+	body := map[string]any{"model": m.modelInfo.Name, "input": texts}
+	if err := m.provider.do(ctx, http.MethodPost, "/api/embed", body, &resp); err != nil {
+		return embedding.EmbeddingResponse{}, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+
+	embeddings := make([]embedding.Embedding, 0, len(resp.Embeddings))
+	for i, vector := range resp.Embeddings {
+		embeddings = append(embeddings, embedding.Embedding{Vector: vector, Index: i})
+	}
+	return embedding.EmbeddingResponse{
+		Embeddings: embeddings,
+		UsageStats: embedding.UsageStats{TokensProcessed: resp.PromptEvalCount},
+	}, nil
+}
+
+// SupportsContentType checks if the model supports a specific content type.
+func (m *OllamaEmbeddingModel) SupportsContentType(contentType embedding.ContentType) bool {
+	return slices.Contains(m.modelInfo.SupportedContentTypes, contentType)
+}
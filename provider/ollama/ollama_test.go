@@ -0,0 +1,277 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hlfshell/gotonomy/embedding"
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/provider"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// newTestServer stands in for an Ollama server with a chat model that
+// supports tools and vision, an older chat model without capability
+// reporting, and an embedding model. Chat requests are passed to chat, and
+// the provider knows only the given model cards.
+func newTestServer(t *testing.T, chat http.HandlerFunc, cards ...model.ModelDescription) provider.Provider {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[
+			{"name":"llava:7b","details":{"family":"llama"}},
+			{"name":"legacy:latest","details":{}},
+			{"name":"nomic-embed-text:latest","details":{"family":"nomic-bert"}}
+		]}`)
+	})
+	mux.HandleFunc("POST /api/show", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Model {
+		case "llava:7b":
+			fmt.Fprint(w, `{"capabilities":["completion","tools","vision"],"model_info":{"general.architecture":"llama","llama.context_length":32768}}`)
+		case "legacy:latest":
+			fmt.Fprint(w, `{"model_info":{}}`)
+		case "nomic-embed-text:latest":
+			fmt.Fprint(w, `{"capabilities":["embedding"],"model_info":{"nomic-bert.context_length":2048,"nomic-bert.embedding_length":768}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Model)
+		}
+	})
+	mux.HandleFunc("POST /api/embed", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"nomic-embed-text:latest","embeddings":[[0.1,0.2],[0.3,0.4]],"prompt_eval_count":6}`)
+	})
+	if chat != nil {
+		mux.HandleFunc("POST /api/chat", chat)
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	registry := model.NewCardRegistry()
+	if err := registry.Add(cards...); err != nil {
+		t.Fatalf("failed to add cards: %v", err)
+	}
+	p, err := NewOllamaProvider(provider.Config{BaseURL: server.URL, Cards: registry})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	return p
+}
+
+func TestListAvailableModels(t *testing.T) {
+	p := newTestServer(t, nil)
+
+	models, err := p.ListAvailableModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	byName := map[string]model.ModelDescription{}
+	for _, m := range models {
+		byName[m.Model] = m
+	}
+	if len(byName) != 2 {
+		t.Fatalf("expected two chat models, got %+v", models)
+	}
+
+	llava := byName["llava:7b"]
+	if llava.MaxContextTokens != 32768 || !llava.CanUseTools || !llava.Accepts("image/png") {
+		t.Errorf("expected server metadata to be used, got %+v", llava)
+	}
+	legacy := byName["legacy:latest"]
+	if legacy.MaxContextTokens != defaultContextTokens || legacy.CanUseTools || legacy.Validate() != nil {
+		t.Errorf("expected conservative defaults for legacy model, got %+v", legacy)
+	}
+}
+
+func TestListAvailableModels_ModelCards(t *testing.T) {
+	p := newTestServer(t, nil, model.ModelDescription{
+		Model:            "legacy",
+		Provider:         "ollama",
+		MaxContextTokens: 8192,
+		CanUseTools:      true,
+	})
+
+	models, err := p.ListAvailableModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range models {
+		if m.Model == "legacy:latest" && (m.MaxContextTokens != 8192 || !m.CanUseTools) {
+			t.Errorf("expected the untagged card to describe legacy:latest, got %+v", m)
+		}
+	}
+
+	m, err := p.GetModel(context.Background(), "legacy:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if desc := m.Description(); desc.Model != "legacy:latest" || desc.MaxContextTokens != 8192 {
+		t.Errorf("unexpected description: %+v", desc)
+	}
+}
+
+func TestListAvailableEmbeddingModels(t *testing.T) {
+	p := newTestServer(t, nil)
+
+	models, err := p.ListAvailableEmbeddingModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "nomic-embed-text:latest" || models[0].Dimensions != 768 {
+		t.Fatalf("unexpected embedding models: %+v", models)
+	}
+
+	m, err := p.GetEmbeddingModel(context.Background(), "nomic-embed-text:latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := m.Embed(context.Background(), embedding.EmbeddingRequest{Contents: []embedding.Content{
+		{Type: embedding.TextContent, Text: "a"},
+		{Type: embedding.TextContent, Text: "b"},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Embeddings) != 2 || resp.Embeddings[1].Vector[1] != 0.4 || resp.UsageStats.TokensProcessed != 6 {
+		t.Errorf("unexpected embeddings: %+v", resp)
+	}
+
+	if _, err := p.GetEmbeddingModel(context.Background(), "llava:7b"); !errors.Is(err, model.ErrModelNotFound) {
+		t.Errorf("expected chat models not to be returned as embedding models, got %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var body chatRequest
+	p := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{
+			"model": "llava:7b",
+			"message": {"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "lookup", "arguments": {"q": "go"}}}]},
+			"done": true,
+			"prompt_eval_count": 20,
+			"eval_count": 5
+		}`)
+	})
+
+	m, err := p.GetModel(context.Background(), "llava:7b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lookup := tool.NewTool[string]("lookup", "Looks things up", []tool.Parameter{
+		tool.NewParameter[string]("q", "The query", true, "", nil),
+	}, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+	resp, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{
+			{Role: model.RoleSystem, Content: "Be brief."},
+			{Role: model.RoleUser, Parts: []model.ContentPart{
+				model.TextPart("What is this?"),
+				model.ImagePart([]byte("png"), "image/png"),
+			}},
+			{Role: model.RoleAssistant, ToolCalls: []model.ToolCall{{ID: "call_1", Name: "lookup", Arguments: tool.Arguments{"q": "gopher"}}}},
+			{Role: model.RoleTool, Content: "a gopher", ToolCallID: "call_1"},
+		},
+		Tools: []tool.Tool{lookup},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if body.Model != "llava:7b" || body.Stream {
+		t.Errorf("unexpected request: %+v", body)
+	}
+	// The window the model advertises is the one the server uses
+	if body.Options["num_ctx"] != 32768.0 {
+		t.Errorf("expected num_ctx to be the advertised context window, got %v", body.Options)
+	}
+	if len(body.Messages) != 4 || body.Messages[1].Content != "What is this?" || len(body.Messages[1].Images) != 1 || body.Messages[1].Images[0] != "cG5n" {
+		t.Errorf("unexpected messages: %+v", body.Messages)
+	}
+	if calls := body.Messages[2].ToolCalls; len(calls) != 1 || calls[0].Function.Name != "lookup" || calls[0].Function.Arguments["q"] != "gopher" {
+		t.Errorf("unexpected assistant tool calls: %+v", body.Messages[2])
+	}
+	if result := body.Messages[3]; result.Role != "tool" || result.ToolName != "lookup" || result.Content != "a gopher" {
+		t.Errorf("unexpected tool result: %+v", result)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Type != "function" || body.Tools[0].Function.Name != "lookup" {
+		t.Errorf("unexpected tools: %+v", body.Tools)
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "lookup" || resp.ToolCalls[0].Arguments["q"] != "go" {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.UsageStats.InputTokens != 20 || resp.UsageStats.OutputTokens != 5 || resp.Model != "llava:7b" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestComplete_Errors(t *testing.T) {
+	p := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model 'llava:7b' not found, try pulling it first"}`)
+	})
+	m, err := p.GetModel(context.Background(), "llava:7b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if !errors.Is(err, model.ErrModelNotFound) {
+		t.Fatalf("expected ErrModelNotFound, got %v", err)
+	}
+
+	_, err = m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Parts: []model.ContentPart{
+			model.ImageURLPart("https://example.com/cat.png", "image/png"),
+		}}},
+	})
+	if !errors.Is(err, model.ErrUnsupportedContent) {
+		t.Fatalf("expected ErrUnsupportedContent for image URLs, got %v", err)
+	}
+}
+
+// TestComplete_RetriesByDefault verifies that a provider configured
+// without MaxRetries retries server errors once, its default.
+func TestComplete_RetriesByDefault(t *testing.T) {
+	calls := 0
+	p := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":"model runner has unexpectedly stopped"}`)
+			return
+		}
+		fmt.Fprint(w, `{"model":"llava:7b","message":{"role":"assistant","content":"ok"},"done":true}`)
+	})
+	m, err := p.GetModel(context.Background(), "llava:7b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Text != "ok" || calls != 2 {
+		t.Fatalf("expected success after one retry, got %q after %d calls", resp.Text, calls)
+	}
+}
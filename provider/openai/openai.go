@@ -3,10 +3,12 @@ package openai
 
 import (
 	"context"
+	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/hlfshell/gotonomy/embedding"
 	"github.com/hlfshell/gotonomy/model"
@@ -23,15 +25,40 @@ const (
 	defaultBaseURL     = "https://api.openai.com/v1"
 	defaultTimeoutSecs = 30
 	defaultMaxRetries  = 3
+
+	// defaultContextTokens is assumed for models the API lists that have no
+	// model card; add a card for accurate limits and costs.
+	defaultContextTokens = 4096
 )
 
+//go:embed models.yaml
+var defaultModelCards []byte
+
+func init() {
+	if err := model.RegisterDefaultCards("openai/models.yaml", defaultModelCards); err != nil {
+		panic(err)
+	}
+}
+
 // OpenAI implements the provider.Provider interface for OpenAI.
 type OpenAI struct {
-	client     openai.Client
-	modelCards map[string]model.ModelDescription // Cache of loaded model cards keyed by model name
+	client openai.Client
+	config provider.Config
+	cards  *model.CardRegistry
+
+	// models added with AddModel, keyed by model name
+	modelCards   map[string]model.ModelDescription
+	modelCardsMu sync.RWMutex
+
+	// middleware per model name, built from config on first use so that
+	// rate limits are shared by every instance of a model
+	middleware   map[string][]model.Middleware
+	middlewareMu sync.Mutex
 }
 
 // NewOpenAIProvider creates a new OpenAI provider with the given configuration.
+// Failed requests are retried MaxRetries times, or 3 if it is zero, as the
+// SDK would by default; a negative MaxRetries disables retries.
 func NewOpenAIProvider(config provider.Config) (provider.Provider, error) {
 	// Validate the API key
 	if config.APIKey == "" {
@@ -53,22 +80,29 @@ func NewOpenAIProvider(config provider.Config) (provider.Provider, error) {
 		opts = append(opts, option.WithOrganization(config.OrganizationID))
 	}
 
+	// Retries are handled by our model middleware (see GetModel) so that
+	// they are classified, rate limited and recorded consistently; the
+	// SDK's own retries would compound with them.
+	opts = append(opts, option.WithMaxRetries(0))
+	if config.TimeoutSeconds > 0 {
+		opts = append(opts, option.WithRequestTimeout(config.Timeout()))
+	}
+
+	cards, err := config.ModelCards()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the OpenAI client
 	client := openai.NewClient(opts...)
 
-	provider := &OpenAI{
+	return &OpenAI{
 		client:     client,
+		config:     config,
+		cards:      cards,
 		modelCards: make(map[string]model.ModelDescription),
-	}
-
-	// Load model cards from the provider's directory
-	if err := provider.loadModelCards(); err != nil {
-		// Log but don't fail - model cards are optional, we can still use API
-		// In production, you might want to use a logger here
-		_ = err
-	}
-
-	return provider, nil
+		middleware: make(map[string][]model.Middleware),
+	}, nil
 }
 
 // Name returns the name of the provider.
@@ -90,81 +124,71 @@ func (p *OpenAI) DefaultConfig() provider.Config {
 	}
 }
 
-// loadModelCards loads model cards from the provider's models.yaml file.
-// It uses the default paths which will automatically find provider model cards.
-// TODO: Implement model card loading when model.LoadDefaultPaths() is available
-func (p *OpenAI) loadModelCards() error {
-	// Model card loading is temporarily disabled until model.LoadDefaultPaths() is implemented
-	// The provider will still work by discovering models via the API
-	return nil
-}
-
 // ListAvailableModels returns a list of available models from OpenAI.
-// It uses model cards as the primary source, with API results as a fallback.
+// Model cards are the primary source; chat models the API lists without a
+// card are included with a conservative context window.
 func (p *OpenAI) ListAvailableModels(ctx context.Context) ([]model.ModelDescription, error) {
 	// Start with models from model cards
-	modelDescriptions := make([]model.ModelDescription, 0, len(p.modelCards))
-	for _, desc := range p.modelCards {
-		modelDescriptions = append(modelDescriptions, desc)
+	modelDescriptions := p.cardList()
+	known := make(map[string]bool, len(modelDescriptions))
+	for _, desc := range modelDescriptions {
+		known[desc.Model] = true
 	}
 
-	// Optionally merge with API results for models not in cards
-	// This allows discovering new models via API while using cards for known models
+	// Merge with API results for models not in cards, so that new models
+	// are discovered while cards describe known ones
 	apiModels, err := p.client.Models.List(ctx)
 	if err != nil {
 		// If API call fails but we have model cards, return those
 		if len(modelDescriptions) > 0 {
 			return modelDescriptions, nil
 		}
-		return nil, fmt.Errorf("failed to list models: %w", err)
+		return nil, fmt.Errorf("failed to list models: %w", wrapError(err))
 	}
 
-	// Add API models that aren't already in our cards
 	for _, m := range apiModels.Data {
 		// Only include chat models
-		if strings.Contains(m.ID, "gpt") {
-			// Check if we already have this model from cards
-			if _, exists := p.modelCards[m.ID]; exists {
-				continue
-			}
-
-			// Create a basic ModelDescription for API-discovered models
-			info := model.ModelDescription{
-				Model:       m.ID,
-				Provider:    "openai",
-				Description: fmt.Sprintf("OpenAI %s model", m.ID),
-				CanUseTools: true,
-			}
+		if !strings.Contains(m.ID, "gpt") || known[m.ID] {
+			continue
+		}
+		modelDescriptions = append(modelDescriptions, model.ModelDescription{
+			Model:            m.ID,
+			Provider:         "openai",
+			Description:      fmt.Sprintf("OpenAI %s model", m.ID),
+			MaxContextTokens: defaultContextTokens,
+			CanUseTools:      true,
+		})
+	}
 
-			// Set context window size based on model (fallback defaults)
-			switch {
-			case strings.Contains(m.ID, "gpt-4o"):
-				info.MaxContextTokens = 128000
-			case strings.Contains(m.ID, "gpt-4-turbo"):
-				info.MaxContextTokens = 128000
-			case strings.Contains(m.ID, "gpt-4-32k"):
-				info.MaxContextTokens = 32768
-			case strings.Contains(m.ID, "gpt-4"):
-				info.MaxContextTokens = 8192
-			case strings.Contains(m.ID, "gpt-3.5-turbo-16k"):
-				info.MaxContextTokens = 16384
-			case strings.Contains(m.ID, "gpt-3.5-turbo"):
-				info.MaxContextTokens = 4096
-			default:
-				info.MaxContextTokens = 4096
-			}
+	return modelDescriptions, nil
+}
 
-			// Set default costs (should be updated in model cards)
-			info.Costs = model.CostsPerToken{
-				Input:  0.00001,
-				Output: 0.00003,
-			}
+// card returns the card for the named model; models added with AddModel
+// take precedence over the card registry.
+func (p *OpenAI) card(modelName string) (model.ModelDescription, bool) {
+	p.modelCardsMu.RLock()
+	desc, ok := p.modelCards[modelName]
+	p.modelCardsMu.RUnlock()
+	if ok {
+		return desc, true
+	}
+	return p.cards.Get("openai", modelName)
+}
 
-			modelDescriptions = append(modelDescriptions, info)
+// cardList returns every model with a card, including added models.
+func (p *OpenAI) cardList() []model.ModelDescription {
+	p.modelCardsMu.RLock()
+	defer p.modelCardsMu.RUnlock()
+	var descs []model.ModelDescription
+	for _, desc := range p.cards.List("openai") {
+		if _, added := p.modelCards[desc.Model]; !added {
+			descs = append(descs, desc)
 		}
 	}
-
-	return modelDescriptions, nil
+	for _, desc := range p.modelCards {
+		descs = append(descs, desc)
+	}
+	return descs
 }
 
 // ListAvailableEmbeddingModels returns a list of available embedding models from OpenAI.
@@ -199,8 +223,8 @@ func (p *OpenAI) ListAvailableEmbeddingModels(ctx context.Context) ([]embedding.
 
 // GetModel returns a model instance by name.
 func (p *OpenAI) GetModel(ctx context.Context, modelName string) (model.Model, error) {
-	// First check model cards cache
-	modelInfo, found := p.modelCards[modelName]
+	// First check model cards
+	modelInfo, found := p.card(modelName)
 	if !found {
 		// Fallback to listing from API
 		models, err := p.ListAvailableModels(ctx)
@@ -218,15 +242,48 @@ func (p *OpenAI) GetModel(ctx context.Context, modelName string) (model.Model, e
 		}
 
 		if !found {
-			return nil, fmt.Errorf("model %s not found", modelName)
+			return nil, fmt.Errorf("%w: %s", model.ErrModelNotFound, modelName)
 		}
 	}
 
-	// Create and return the model
-	return &OpenAIModel{
+	// Create the model, wrapped in the retry and rate limit middleware
+	// the provider config calls for
+	var m model.Model = &OpenAIModel{
 		provider:  p,
 		modelInfo: modelInfo,
-	}, nil
+	}
+	return model.Chain(m, p.modelMiddleware(modelName)...), nil
+}
+
+// modelMiddleware returns the middleware for the named model, creating it
+// from the provider config on first use.
+func (p *OpenAI) modelMiddleware(modelName string) []model.Middleware {
+	p.middlewareMu.Lock()
+	defer p.middlewareMu.Unlock()
+	middleware, ok := p.middleware[modelName]
+	if !ok {
+		middleware = p.config.Middleware(defaultMaxRetries)
+		p.middleware[modelName] = middleware
+	}
+	return middleware
+}
+
+// wrapError converts OpenAI API errors into model.ProviderErrors so that
+// middleware can classify them.
+func wrapError(err error) error {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	providerErr := &model.ProviderError{
+		Provider:   "openai",
+		StatusCode: apiErr.StatusCode,
+		Err:        err,
+	}
+	if apiErr.Response != nil {
+		providerErr.RetryAfter = model.ParseRetryAfter(apiErr.Response.Header.Get("Retry-After"))
+	}
+	return providerErr
 }
 
 // AddModel allows you to add a model instance by ModelDescription.
@@ -242,8 +299,9 @@ func (p *OpenAI) AddModel(ctx context.Context, modelDesc model.ModelDescription)
 		return fmt.Errorf("model provider %s does not match OpenAI provider", modelDesc.Provider)
 	}
 
-	// Add to cache
+	p.modelCardsMu.Lock()
 	p.modelCards[modelDesc.Model] = modelDesc
+	p.modelCardsMu.Unlock()
 	return nil
 }
 
@@ -276,12 +334,16 @@ func (p *OpenAI) GetEmbeddingModel(ctx context.Context, modelName string) (embed
 	}, nil
 }
 
-// OpenAIModel implements the model.Model interface for OpenAI models.
+// OpenAIModel implements the model.Model and model.StreamingModel
+// interfaces for OpenAI models.
 type OpenAIModel struct {
 	provider  *OpenAI
 	modelInfo model.ModelDescription
 }
 
+// Ensure OpenAIModel supports streaming.
+var _ model.StreamingModel = (*OpenAIModel)(nil)
+
 // Description returns information about the model.
 func (m *OpenAIModel) Description() model.ModelDescription {
 	return m.modelInfo
@@ -289,9 +351,115 @@ func (m *OpenAIModel) Description() model.ModelDescription {
 
 // Complete generates a completion for the given request.
 func (m *OpenAIModel) Complete(ctx *tool.Context, request model.CompletionRequest) (model.CompletionResponse, error) {
-	// Validate the request
-	if err := request.Validate(); err != nil {
-		return model.CompletionResponse{}, fmt.Errorf("invalid request: %w", err)
+	chatParams, err := m.buildChatParams(request)
+	if err != nil {
+		return model.CompletionResponse{}, err
+	}
+
+	// Make the request; ctx carries the execution's cancellation and
+	// deadline (a nil ctx behaves like context.Background())
+	completion, err := m.provider.client.Chat.Completions.New(ctx, chatParams)
+	if err != nil {
+		return model.CompletionResponse{}, fmt.Errorf("failed to create completion: %w", wrapError(err))
+	}
+
+	resp, err := responseFromCompletion(completion)
+	resp.Model = m.modelInfo.Model
+	return resp, err
+}
+
+// CompleteStream generates a completion for the given request, emitting
+// text and tool call fragments as they arrive. The final event carries
+// the assembled response, including usage stats.
+func (m *OpenAIModel) CompleteStream(ctx *tool.Context, request model.CompletionRequest) (<-chan model.StreamEvent, error) {
+	chatParams, err := m.buildChatParams(request)
+	if err != nil {
+		return nil, err
+	}
+	// Ask for a trailing usage chunk so the final response has token counts
+	chatParams.StreamOptions = openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: param.NewOpt(true),
+	}
+
+	stream := m.provider.client.Chat.Completions.NewStreaming(ctx, chatParams)
+
+	events := make(chan model.StreamEvent)
+	go func() {
+		defer close(events)
+		defer stream.Close()
+
+		// send delivers a delta, giving up once ctx is cancelled; the
+		// terminal event is always delivered since consumers drain the
+		// channel until it is closed.
+		send := func(event model.StreamEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				events <- model.StreamEvent{
+					Type: model.StreamEventError,
+					Err:  fmt.Errorf("failed to stream completion: %w", ctx.Err()),
+				}
+				return false
+			}
+		}
+
+		acc := openai.ChatCompletionAccumulator{}
+		for stream.Next() {
+			chunk := stream.Current()
+			acc.AddChunk(chunk)
+
+			// We only ever request a single choice
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			delta := chunk.Choices[0].Delta
+			if delta.Content != "" && !send(model.StreamEvent{
+				Type: model.StreamEventText,
+				Text: delta.Content,
+			}) {
+				return
+			}
+			for _, tc := range delta.ToolCalls {
+				if !send(model.StreamEvent{
+					Type: model.StreamEventToolCall,
+					ToolCall: &model.ToolCallDelta{
+						Index:     int(tc.Index),
+						ID:        tc.ID,
+						Name:      tc.Function.Name,
+						Arguments: tc.Function.Arguments,
+					},
+				}) {
+					return
+				}
+			}
+		}
+		if err := stream.Err(); err != nil {
+			events <- model.StreamEvent{
+				Type: model.StreamEventError,
+				Err:  fmt.Errorf("failed to stream completion: %w", wrapError(err)),
+			}
+			return
+		}
+
+		resp, err := responseFromCompletion(&acc.ChatCompletion)
+		if err != nil {
+			events <- model.StreamEvent{Type: model.StreamEventError, Err: err}
+			return
+		}
+		resp.Model = m.modelInfo.Model
+		events <- model.StreamEvent{Type: model.StreamEventDone, Response: &resp}
+	}()
+
+	return events, nil
+}
+
+// buildChatParams validates the request and converts it into OpenAI's
+// chat completion parameters.
+func (m *OpenAIModel) buildChatParams(request model.CompletionRequest) (openai.ChatCompletionNewParams, error) {
+	// Validate the request, including content parts against what the model accepts
+	if err := request.ValidateFor(m.modelInfo); err != nil {
+		return openai.ChatCompletionNewParams{}, fmt.Errorf("invalid request: %w", err)
 	}
 
 	// Convert messages to OpenAI format
@@ -299,10 +467,31 @@ func (m *OpenAIModel) Complete(ctx *tool.Context, request model.CompletionReques
 	for _, msg := range request.Messages {
 		var messageUnion openai.ChatCompletionMessageParamUnion
 
+		// Only user messages may carry non-text parts; every other role
+		// is sent as its flattened text.
+		if msg.Role != model.RoleUser {
+			for _, part := range msg.Parts {
+				if part.Type != model.PartText {
+					return openai.ChatCompletionNewParams{}, fmt.Errorf("%w: %s messages only support text parts", model.ErrUnsupportedContent, msg.Role)
+				}
+			}
+			msg.Content = msg.Text()
+		}
+
 		// Content is a union type - we'll use OfString for simple text content
+		// and an array of content parts for multimodal messages
 		contentUnion := openai.ChatCompletionUserMessageParamContentUnion{
 			OfString: param.NewOpt(msg.Content),
 		}
+		if len(msg.Parts) > 0 {
+			parts, err := contentPartsToOpenAI(msg.Parts)
+			if err != nil {
+				return openai.ChatCompletionNewParams{}, err
+			}
+			contentUnion = openai.ChatCompletionUserMessageParamContentUnion{
+				OfArrayOfContentParts: parts,
+			}
+		}
 
 		switch msg.Role {
 		case model.RoleSystem:
@@ -321,13 +510,33 @@ func (m *OpenAIModel) Complete(ctx *tool.Context, request model.CompletionReques
 				},
 			}
 		case model.RoleAssistant:
-			assistantContent := openai.ChatCompletionAssistantMessageParamContentUnion{
-				OfString: param.NewOpt(msg.Content),
+			assistant := &openai.ChatCompletionAssistantMessageParam{}
+			// Content may be omitted when the message only calls tools
+			if msg.Content != "" || len(msg.ToolCalls) == 0 {
+				assistant.Content = openai.ChatCompletionAssistantMessageParamContentUnion{
+					OfString: param.NewOpt(msg.Content),
+				}
+			}
+			for _, call := range msg.ToolCalls {
+				args, err := json.Marshal(call.Arguments)
+				if err != nil {
+					return openai.ChatCompletionNewParams{}, fmt.Errorf("%w: arguments of %s: %v", model.ErrInvalidToolCall, call.Name, err)
+				}
+				if call.Arguments == nil {
+					args = []byte("{}")
+				}
+				assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+					OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID: call.ID,
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+							Name:      call.Name,
+							Arguments: string(args),
+						},
+					},
+				})
 			}
 			messageUnion = openai.ChatCompletionMessageParamUnion{
-				OfAssistant: &openai.ChatCompletionAssistantMessageParam{
-					Content: assistantContent,
-				},
+				OfAssistant: assistant,
 			}
 		case model.RoleTool:
 			// Tool messages need a tool_call_id to match the original tool call
@@ -345,7 +554,7 @@ func (m *OpenAIModel) Complete(ctx *tool.Context, request model.CompletionReques
 				},
 			}
 		default:
-			return model.CompletionResponse{}, fmt.Errorf("unsupported message role: %s", msg.Role)
+			return openai.ChatCompletionNewParams{}, fmt.Errorf("unsupported message role: %s", msg.Role)
 		}
 
 		openaiMessages = append(openaiMessages, messageUnion)
@@ -357,11 +566,6 @@ func (m *OpenAIModel) Complete(ctx *tool.Context, request model.CompletionReques
 		Messages: openaiMessages,
 	}
 
-	// Set temperature from config
-	if request.Config.Temperature > 0 {
-		chatParams.Temperature = param.NewOpt(float64(request.Config.Temperature))
-	}
-
 	// Convert tools if provided
 	if len(request.Tools) > 0 {
 		openaiTools := make([]openai.ChatCompletionToolUnionParam, 0, len(request.Tools))
@@ -393,12 +597,108 @@ func (m *OpenAIModel) Complete(ctx *tool.Context, request model.CompletionReques
 		chatParams.Tools = openaiTools
 	}
 
-	// Make the request
-	completion, err := m.provider.client.Chat.Completions.New(context.Background(), chatParams)
-	if err != nil {
-		return model.CompletionResponse{}, fmt.Errorf("failed to create completion: %w", err)
+	applyConfig(&chatParams, request.Config)
+	return chatParams, nil
+}
+
+// applyConfig sets the request parameters the model config calls for,
+// leaving unset ones to OpenAI's defaults.
+func applyConfig(chatParams *openai.ChatCompletionNewParams, config model.ModelConfig) {
+	if config.Temperature > 0 {
+		chatParams.Temperature = param.NewOpt(float64(config.Temperature))
+	}
+	if config.MaxTokens > 0 {
+		chatParams.MaxCompletionTokens = param.NewOpt(int64(config.MaxTokens))
+	}
+	if config.TopP > 0 {
+		chatParams.TopP = param.NewOpt(float64(config.TopP))
+	}
+	if len(config.Stop) > 0 {
+		chatParams.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: config.Stop}
+	}
+	if config.Seed != nil {
+		chatParams.Seed = param.NewOpt(*config.Seed)
+	}
+	if config.PresencePenalty != 0 {
+		chatParams.PresencePenalty = param.NewOpt(float64(config.PresencePenalty))
+	}
+	if config.FrequencyPenalty != 0 {
+		chatParams.FrequencyPenalty = param.NewOpt(float64(config.FrequencyPenalty))
+	}
+
+	if name := config.ToolChoice.Tool(); name != "" {
+		chatParams.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+			OfFunctionToolChoice: &openai.ChatCompletionNamedToolChoiceParam{
+				Function: openai.ChatCompletionNamedToolChoiceFunctionParam{Name: name},
+			},
+		}
+	} else if config.ToolChoice != "" {
+		chatParams.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{
+			OfAuto: param.NewOpt(string(config.ToolChoice)),
+		}
 	}
+	// OpenAI rejects parallel_tool_calls on requests without tools
+	if config.ParallelToolCalls != nil && len(chatParams.Tools) > 0 {
+		chatParams.ParallelToolCalls = param.NewOpt(*config.ParallelToolCalls)
+	}
+
+	if format := config.ResponseFormat; format != nil {
+		switch format.Type {
+		case model.ResponseFormatText:
+			chatParams.ResponseFormat.OfText = &shared.ResponseFormatTextParam{}
+		case model.ResponseFormatJSON:
+			chatParams.ResponseFormat.OfJSONObject = &shared.ResponseFormatJSONObjectParam{}
+		case model.ResponseFormatJSONSchema:
+			chatParams.ResponseFormat.OfJSONSchema = &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   format.Name,
+					Schema: format.Schema,
+					Strict: param.NewOpt(format.Strict),
+				},
+			}
+		}
+	}
+}
+
+// contentPartsToOpenAI converts multimodal parts into OpenAI content parts.
+// Images are sent as URLs (inline data becomes a data URL) and files as
+// either file IDs or inline file data.
+func contentPartsToOpenAI(parts []model.ContentPart) ([]openai.ChatCompletionContentPartUnionParam, error) {
+	out := make([]openai.ChatCompletionContentPartUnionParam, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case model.PartText:
+			out = append(out, openai.TextContentPart(part.Text))
+		case model.PartImage:
+			url := part.URL
+			if len(part.Data) > 0 {
+				url = part.DataURL()
+			}
+			out = append(out, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+				URL: url,
+			}))
+		case model.PartFile:
+			file := openai.ChatCompletionContentPartFileFileParam{}
+			switch {
+			case part.FileID != "":
+				file.FileID = param.NewOpt(part.FileID)
+			case len(part.Data) > 0:
+				file.FileData = param.NewOpt(part.DataURL())
+			default:
+				return nil, fmt.Errorf("%w: OpenAI does not accept files by URL", model.ErrUnsupportedContent)
+			}
+			if part.Filename != "" {
+				file.Filename = param.NewOpt(part.Filename)
+			}
+			out = append(out, openai.FileContentPart(file))
+		}
+	}
+	return out, nil
+}
 
+// responseFromCompletion converts an OpenAI chat completion (either
+// returned directly or accumulated from a stream) into our generic format.
+func responseFromCompletion(completion *openai.ChatCompletion) (model.CompletionResponse, error) {
 	// Convert the response
 	if len(completion.Choices) == 0 {
 		return model.CompletionResponse{}, errors.New("no choices in response")
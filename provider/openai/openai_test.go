@@ -0,0 +1,453 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hlfshell/gotonomy/model"
+	"github.com/hlfshell/gotonomy/provider"
+	"github.com/hlfshell/gotonomy/tool"
+)
+
+// newTestModel spins up a provider against the given handler and returns
+// a model registered under the name "test-model".
+func newTestModel(t *testing.T, handler http.HandlerFunc) *OpenAIModel {
+	t.Helper()
+	return getTestModel(t, provider.Config{MaxRetries: -1}, handler).(*OpenAIModel)
+}
+
+// getTestModel is newTestModel with a custom provider config, returning
+// the model as GetModel does (including any middleware).
+func getTestModel(t *testing.T, config provider.Config, handler http.HandlerFunc) model.Model {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config.APIKey = "test-key"
+	config.BaseURL = server.URL
+	p, err := NewOpenAIProvider(config)
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+	desc := model.ModelDescription{
+		Model:            "test-model",
+		Provider:         "openai",
+		MaxContextTokens: 8192,
+		CanUseTools:      true,
+	}
+	if err := p.AddModel(context.Background(), desc); err != nil {
+		t.Fatalf("failed to add model: %v", err)
+	}
+	m, err := p.GetModel(context.Background(), "test-model")
+	if err != nil {
+		t.Fatalf("failed to get model: %v", err)
+	}
+	return m
+}
+
+func sseChunk(body string) string {
+	return fmt.Sprintf("data: %s\n\n", body)
+}
+
+func TestCompleteStream(t *testing.T) {
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"test-model","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"test-model","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"test-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"test-model","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go\"}"}}]}}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"test-model","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"test-model","choices":[],"usage":{"prompt_tokens":7,"completion_tokens":4,"total_tokens":11}}`,
+		}
+		for _, c := range chunks {
+			fmt.Fprint(w, sseChunk(c))
+		}
+		fmt.Fprint(w, sseChunk("[DONE]"))
+	})
+
+	events, err := m.CompleteStream(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var text, args string
+	resp, err := model.CollectStream(events, func(e model.StreamEvent) {
+		switch e.Type {
+		case model.StreamEventText:
+			text += e.Text
+		case model.StreamEventToolCall:
+			args += e.ToolCall.Arguments
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+
+	if text != "Hello" {
+		t.Errorf("expected streamed text %q, got %q", "Hello", text)
+	}
+	if args != `{"q":"go"}` {
+		t.Errorf("expected streamed arguments %q, got %q", `{"q":"go"}`, args)
+	}
+	if resp.Text != "Hello" {
+		t.Errorf("expected final text %q, got %q", "Hello", resp.Text)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "lookup" || resp.ToolCalls[0].Arguments["q"] != "go" {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.UsageStats.InputTokens != 7 || resp.UsageStats.OutputTokens != 4 {
+		t.Errorf("unexpected usage stats: %+v", resp.UsageStats)
+	}
+}
+
+func TestCompleteStream_ServerError(t *testing.T) {
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"message":"bad request","type":"invalid_request_error"}}`)
+	})
+
+	events, err := m.CompleteStream(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error opening stream: %v", err)
+	}
+	if _, err := model.CollectStream(events, nil); err == nil {
+		t.Fatalf("expected stream error")
+	}
+}
+
+func TestComplete_MultimodalParts(t *testing.T) {
+	var body map[string]any
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"a cat"}}],"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`)
+	})
+	m.modelInfo.AcceptsFileTypes = []string{"image/png"}
+
+	resp, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{
+			Role: model.RoleUser,
+			Parts: []model.ContentPart{
+				model.TextPart("what is this?"),
+				model.ImagePart([]byte("png"), "image/png"),
+				model.ImageURLPart("https://example.com/cat.png", "image/png"),
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Text != "a cat" {
+		t.Errorf("expected %q, got %q", "a cat", resp.Text)
+	}
+
+	messages := body["messages"].([]any)
+	content, ok := messages[0].(map[string]any)["content"].([]any)
+	if !ok || len(content) != 3 {
+		t.Fatalf("expected 3 content parts, got %#v", messages[0])
+	}
+	if content[0].(map[string]any)["type"] != "text" {
+		t.Errorf("expected text part first, got %#v", content[0])
+	}
+	inline := content[1].(map[string]any)["image_url"].(map[string]any)["url"]
+	if inline != "data:image/png;base64,cG5n" {
+		t.Errorf("expected inline image as data URL, got %v", inline)
+	}
+	remote := content[2].(map[string]any)["image_url"].(map[string]any)["url"]
+	if remote != "https://example.com/cat.png" {
+		t.Errorf("expected remote image URL, got %v", remote)
+	}
+}
+
+func TestComplete_ModelConfig(t *testing.T) {
+	var body map[string]any
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"{}"}}]}`)
+	})
+
+	lookup := tool.NewTool[string]("lookup", "Looks things up", nil, func(ctx *tool.Context, args tool.Arguments) (string, error) {
+		return "", nil
+	})
+	seed := int64(42)
+	parallel := false
+	_, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+		Tools:    []tool.Tool{lookup},
+		Config: model.ModelConfig{
+			MaxTokens:         128,
+			TopP:              0.5,
+			Stop:              []string{"END"},
+			Seed:              &seed,
+			PresencePenalty:   0.5,
+			FrequencyPenalty:  -0.5,
+			ToolChoice:        "lookup",
+			ParallelToolCalls: &parallel,
+			ResponseFormat: &model.ResponseFormat{
+				Type:   model.ResponseFormatJSONSchema,
+				Name:   "answer",
+				Schema: map[string]any{"type": "object"},
+				Strict: true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]any{
+		"max_completion_tokens": 128.0,
+		"top_p":                 0.5,
+		"seed":                  42.0,
+		"presence_penalty":      0.5,
+		"frequency_penalty":     -0.5,
+		"parallel_tool_calls":   false,
+	}
+	for key, value := range expected {
+		if body[key] != value {
+			t.Errorf("expected %s=%v, got %v", key, value, body[key])
+		}
+	}
+	if stop, _ := body["stop"].([]any); len(stop) != 1 || stop[0] != "END" {
+		t.Errorf("unexpected stop: %v", body["stop"])
+	}
+	choice, _ := body["tool_choice"].(map[string]any)
+	if choice["type"] != "function" || choice["function"].(map[string]any)["name"] != "lookup" {
+		t.Errorf("unexpected tool_choice: %v", body["tool_choice"])
+	}
+	format, _ := body["response_format"].(map[string]any)
+	schema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || schema["name"] != "answer" || schema["strict"] != true {
+		t.Errorf("unexpected response_format: %v", body["response_format"])
+	}
+}
+
+func TestComplete_ToolCallRoundTrip(t *testing.T) {
+	var body map[string]any
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"test-model","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_2","type":"function","function":{"name":"lookup","arguments":"{\"query\":\"more\"}"}}]}}]}`)
+	})
+
+	resp, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{
+			{Role: model.RoleUser, Content: "look it up"},
+			{Role: model.RoleAssistant, ToolCalls: []model.ToolCall{
+				{ID: "call_1", Name: "lookup", Arguments: tool.Arguments{"query": "answer"}},
+			}},
+			{Role: model.RoleTool, Content: "42", ToolCallID: "call_1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "call_2" || resp.ToolCalls[0].Arguments["query"] != "more" {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+
+	messages := body["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %#v", messages)
+	}
+	assistant := messages[1].(map[string]any)
+	if _, ok := assistant["content"]; ok {
+		t.Errorf("expected no content on a tool calling message, got %#v", assistant)
+	}
+	calls, _ := assistant["tool_calls"].([]any)
+	if len(calls) != 1 {
+		t.Fatalf("expected the assistant's tool call, got %#v", assistant)
+	}
+	call := calls[0].(map[string]any)
+	function := call["function"].(map[string]any)
+	if call["id"] != "call_1" || call["type"] != "function" || function["name"] != "lookup" || function["arguments"] != `{"query":"answer"}` {
+		t.Errorf("unexpected tool call %#v", call)
+	}
+	result := messages[2].(map[string]any)
+	if result["role"] != "tool" || result["tool_call_id"] != "call_1" || result["content"] != "42" {
+		t.Errorf("unexpected tool result %#v", result)
+	}
+}
+
+func TestComplete_RejectsUnsupportedContent(t *testing.T) {
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request should not reach the server")
+	})
+
+	_, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{
+			Role:  model.RoleUser,
+			Parts: []model.ContentPart{model.ImagePart([]byte("png"), "image/png")},
+		}},
+	})
+	if !errors.Is(err, model.ErrUnsupportedContent) {
+		t.Fatalf("expected ErrUnsupportedContent, got %v", err)
+	}
+}
+
+func TestComplete_HonorsContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		// Hang until the client goes away or the test ends
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	// Registered after the server so it runs before the server closes
+	t.Cleanup(func() { close(release) })
+
+	parent, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := m.Complete(tool.NewContext(parent), model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("request was not aborted promptly, took %s", elapsed)
+	}
+}
+
+func TestGetModel_RetriesPerConfig(t *testing.T) {
+	calls := 0
+	m := getTestModel(t, provider.Config{MaxRetries: 2}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"slow down","type":"rate_limit_error"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+	})
+	if _, ok := m.(model.StreamingModel); !ok {
+		t.Fatalf("expected wrapped model to still support streaming")
+	}
+
+	ctx := tool.NewContext(context.Background())
+	resp, err := m.Complete(ctx, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Text != "ok" || calls != 2 {
+		t.Fatalf("expected success after one retry, got %q after %d calls", resp.Text, calls)
+	}
+	if retries := ctx.Stats().GetCount(model.StatModelRetries); retries == nil || *retries != 1 {
+		t.Errorf("expected 1 retry recorded, got %v", retries)
+	}
+}
+
+func TestGetModel_RetriesByDefault(t *testing.T) {
+	calls := 0
+	m := getTestModel(t, provider.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":{"message":"overloaded","type":"server_error"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"c1","object":"chat.completion","created":1,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+	})
+
+	resp, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatalf("expected a zero config to retry, got %v", err)
+	}
+	if resp.Text != "ok" || calls != 2 {
+		t.Fatalf("expected success after one retry, got %q after %d calls", resp.Text, calls)
+	}
+}
+
+func TestComplete_ClassifiesAPIErrors(t *testing.T) {
+	m := newTestModel(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"slow down","type":"rate_limit_error"}}`)
+	})
+
+	_, err := m.Complete(nil, model.CompletionRequest{
+		Messages: []model.Message{{Role: model.RoleUser, Content: "hi"}},
+	})
+	if !errors.Is(err, model.ErrRateLimitExceeded) {
+		t.Fatalf("expected ErrRateLimitExceeded, got %v", err)
+	}
+	if wait := model.RetryAfter(err); wait != 7*time.Second {
+		t.Errorf("expected Retry-After of 7s, got %s", wait)
+	}
+}
+
+func TestListAvailableModels_ModelCards(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"object":"list","data":[
+			{"id":"gpt-carded","object":"model","created":1,"owned_by":"openai"},
+			{"id":"gpt-new","object":"model","created":1,"owned_by":"openai"},
+			{"id":"whisper-1","object":"model","created":1,"owned_by":"openai"}
+		]}`)
+	}))
+	defer server.Close()
+
+	cards := model.NewCardRegistry()
+	if err := cards.Add(model.ModelDescription{Model: "gpt-carded", Provider: "openai", MaxContextTokens: 64000}); err != nil {
+		t.Fatalf("failed to add card: %v", err)
+	}
+	p, err := NewOpenAIProvider(provider.Config{APIKey: "test-key", BaseURL: server.URL, Cards: cards})
+	if err != nil {
+		t.Fatalf("failed to create provider: %v", err)
+	}
+
+	models, err := p.ListAvailableModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(models) != 2 || models[0].Model != "gpt-carded" || models[0].MaxContextTokens != 64000 ||
+		models[1].Model != "gpt-new" || models[1].MaxContextTokens != defaultContextTokens {
+		t.Fatalf("unexpected models: %+v", models)
+	}
+
+	m, err := p.GetModel(context.Background(), "gpt-carded")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Description().MaxContextTokens != 64000 {
+		t.Errorf("expected the model card to describe the model, got %+v", m.Description())
+	}
+	if _, err := p.GetModel(context.Background(), "missing"); !errors.Is(err, model.ErrModelNotFound) {
+		t.Errorf("expected ErrModelNotFound, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ package provider
 
 import (
 	"context"
+	"time"
 
 	"github.com/hlfshell/gotonomy/embedding"
 	"github.com/hlfshell/gotonomy/model"
@@ -45,4 +46,62 @@ type Config struct {
 	TimeoutSeconds    int               `json:"timeout_seconds"`
 	MaxRetries        int               `json:"max_retries"`
 	AdditionalHeaders map[string]string `json:"additional_headers"`
+
+	// RequestsPerMinute and TokensPerMinute limit each model obtained
+	// from the provider; 0 means unlimited.
+	RequestsPerMinute int `json:"requests_per_minute"`
+	TokensPerMinute   int `json:"tokens_per_minute"`
+
+	// Cards supplies the model cards the provider describes its models
+	// with; if nil, the shared registry from model.LoadDefaultPaths is used.
+	Cards *model.CardRegistry `json:"-"`
+}
+
+// ModelCards returns the card registry the config calls for.
+func (c Config) ModelCards() (*model.CardRegistry, error) {
+	if c.Cards != nil {
+		return c.Cards, nil
+	}
+	return model.LoadDefaultPaths()
+}
+
+// Timeout returns TimeoutSeconds as a duration.
+func (c Config) Timeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// RateLimit returns the per-model rate limits in the config.
+func (c Config) RateLimit() model.RateLimitConfig {
+	return model.RateLimitConfig{
+		RequestsPerMinute: c.RequestsPerMinute,
+		TokensPerMinute:   c.TokensPerMinute,
+	}
+}
+
+// Retries returns the number of times failed requests are retried:
+// MaxRetries, or defaultRetries, the provider's default, if it is zero.
+// A negative MaxRetries disables retries.
+func (c Config) Retries(defaultRetries int) int {
+	if c.MaxRetries == 0 {
+		return defaultRetries
+	}
+	return max(c.MaxRetries, 0)
+}
+
+// Middleware returns the model middleware the config calls for: retries,
+// as many as Retries gives for the provider's defaultRetries, and rate
+// limiting when limits are set. The returned rate limiter is stateful, so
+// providers should call this once per model and reuse the result so
+// limits hold across GetModel calls.
+func (c Config) Middleware(defaultRetries int) []model.Middleware {
+	var middleware []model.Middleware
+	if retries := c.Retries(defaultRetries); retries > 0 {
+		retry := model.DefaultRetryConfig()
+		retry.MaxRetries = retries
+		middleware = append(middleware, model.WithRetry(retry))
+	}
+	if c.RequestsPerMinute > 0 || c.TokensPerMinute > 0 {
+		middleware = append(middleware, model.WithRateLimit(c.RateLimit()))
+	}
+	return middleware
 }
@@ -0,0 +1,335 @@
+package tool
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Coerce converts value, as decoded from JSON into an any, to type t:
+// numbers to integer kinds if they are whole and fit, objects to structs
+// and maps, and arrays to slices and arrays of their element types, all
+// the way down. Values already assignable to t are returned as they are,
+// and nil becomes t's zero value. Types that unmarshal themselves from
+// JSON, e.g. time.Time, are decoded as encoding/json would. Every value
+// that cannot be converted is reported with its path, e.g.
+// "$.filters[0].limit: 2.5 is not an integer", as are object fields that
+// t does not have.
+func Coerce(value any, t reflect.Type) (any, error) {
+	var errs []error
+	v := coerce(value, t, "$", &errs)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return v.Interface(), nil
+}
+
+func coerce(value any, t reflect.Type, path string, errs *[]error) reflect.Value {
+	if value == nil {
+		return reflect.Zero(t)
+	}
+	if reflect.TypeOf(value).AssignableTo(t) {
+		return reflect.ValueOf(value)
+	}
+	mismatch := func() reflect.Value {
+		*errs = append(*errs, fmt.Errorf("%s: expected %s, got %s", path, t, jsonTypeName(value)))
+		return reflect.Zero(t)
+	}
+
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return unmarshalInto(value, t, path, errs)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := coerce(value, t.Elem(), path, errs)
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := toFloat(value)
+		if !ok {
+			return mismatch()
+		}
+		out := reflect.New(t).Elem()
+		switch {
+		case number != math.Trunc(number):
+			*errs = append(*errs, fmt.Errorf("%s: %v is not an integer", path, value))
+		case number < math.MinInt64 || number >= math.MaxInt64 || out.OverflowInt(int64(number)):
+			*errs = append(*errs, fmt.Errorf("%s: %v overflows %s", path, value, t))
+		default:
+			out.SetInt(int64(number))
+		}
+		return out
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		number, ok := toFloat(value)
+		if !ok {
+			return mismatch()
+		}
+		out := reflect.New(t).Elem()
+		switch {
+		case number != math.Trunc(number):
+			*errs = append(*errs, fmt.Errorf("%s: %v is not an integer", path, value))
+		case number < 0 || number >= math.MaxUint64 || out.OverflowUint(uint64(number)):
+			*errs = append(*errs, fmt.Errorf("%s: %v overflows %s", path, value, t))
+		default:
+			out.SetUint(uint64(number))
+		}
+		return out
+
+	case reflect.Float32, reflect.Float64:
+		number, ok := toFloat(value)
+		if !ok {
+			return mismatch()
+		}
+		out := reflect.New(t).Elem()
+		if out.OverflowFloat(number) {
+			*errs = append(*errs, fmt.Errorf("%s: %v overflows %s", path, value, t))
+			return out
+		}
+		out.SetFloat(number)
+		return out
+
+	case reflect.String, reflect.Bool:
+		// Named types such as a string enum
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != t.Kind() {
+			return mismatch()
+		}
+		return rv.Convert(t)
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string
+			if _, ok := value.(string); ok {
+				return unmarshalInto(value, t, path, errs)
+			}
+		}
+		items, ok := toSlice(value)
+		if !ok {
+			return mismatch()
+		}
+		out := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			out.Index(i).Set(coerce(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs))
+		}
+		return out
+
+	case reflect.Array:
+		items, ok := toSlice(value)
+		if !ok {
+			return mismatch()
+		}
+		out := reflect.New(t).Elem()
+		if len(items) != t.Len() {
+			*errs = append(*errs, fmt.Errorf("%s: expected %d elements, got %d", path, t.Len(), len(items)))
+			return out
+		}
+		for i, item := range items {
+			out.Index(i).Set(coerce(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs))
+		}
+		return out
+
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		out := reflect.MakeMapWithSize(t, len(object))
+		for _, key := range sortedKeys(object) {
+			fieldPath := path + "." + key
+			k, err := mapKey(key, t.Key())
+			if err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: %w", fieldPath, err))
+				continue
+			}
+			out.SetMapIndex(k, coerce(object[key], t.Elem(), fieldPath, errs))
+		}
+		return out
+
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		out := reflect.New(t).Elem()
+		fields := structFields(t)
+		for _, key := range sortedKeys(object) {
+			fieldPath := path + "." + key
+			index, ok := fields[key]
+			if !ok {
+				// encoding/json matches names case-insensitively
+				for name, i := range fields {
+					if strings.EqualFold(name, key) {
+						index, ok = i, true
+						break
+					}
+				}
+			}
+			if !ok {
+				*errs = append(*errs, fmt.Errorf("%s: unexpected field", fieldPath))
+				continue
+			}
+			field, ok := fieldByIndex(out, index)
+			if !ok {
+				*errs = append(*errs, fmt.Errorf("%s: cannot set embedded pointer to unexported struct", fieldPath))
+				continue
+			}
+			field.Set(coerce(object[key], field.Type(), fieldPath, errs))
+		}
+		return out
+	}
+	return mismatch()
+}
+
+// unmarshalInto decodes value into a new t by way of its JSON encoding.
+func unmarshalInto(value any, t reflect.Type, path string, errs *[]error) reflect.Value {
+	out := reflect.New(t)
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(data, out.Interface())
+	}
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+	}
+	return out.Elem()
+}
+
+// toFloat returns a number of any numeric type as a float64.
+func toFloat(value any) (float64, bool) {
+	if number, ok := value.(json.Number); ok {
+		f, err := number.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// toSlice returns the elements of a slice or array of any type.
+func toSlice(value any) ([]any, bool) {
+	if items, ok := value.([]any); ok {
+		return items, true
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]any, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+// mapKey converts an object key to a map key of type t, as encoding/json
+// does: string kinds as they are, integer kinds parsed.
+func mapKey(key string, t reflect.Type) (reflect.Value, error) {
+	out := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		out.SetString(key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, t.Bits())
+		if err != nil {
+			return out, fmt.Errorf("key %q is not a valid %s", key, t)
+		}
+		out.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, t.Bits())
+		if err != nil {
+			return out, fmt.Errorf("key %q is not a valid %s", key, t)
+		}
+		out.SetUint(n)
+	default:
+		return out, fmt.Errorf("unsupported key type %s", t)
+	}
+	return out, nil
+}
+
+// structFields maps the JSON names of a struct's fields to their index,
+// promoting the fields of embedded structs as encoding/json does.
+func structFields(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	var addFields func(t reflect.Type, index []int)
+	addFields = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, skip := jsonField(field)
+			if skip {
+				continue
+			}
+			fieldIndex := append(append([]int{}, index...), i)
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+				addFields(fieldType, fieldIndex)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			// Shallower fields win, as in encoding/json
+			if existing, ok := fields[name]; !ok || len(fieldIndex) < len(existing) {
+				fields[name] = fieldIndex
+			}
+		}
+	}
+	addFields(t, nil)
+	return fields
+}
+
+// fieldByIndex returns the field of v at index, allocating any nil
+// embedded struct pointers on the way. It fails, as encoding/json does,
+// if such a pointer is to an unexported type.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// sortedKeys returns an object's keys in order, so errors are reported
+// deterministically.
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
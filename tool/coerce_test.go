@@ -0,0 +1,163 @@
+package tool
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type coerceFilter struct {
+	Field string `json:"field"`
+	Limit int    `json:"limit"`
+}
+
+type coerceQuery struct {
+	Filters []coerceFilter  `json:"filters"`
+	Weights map[string]int8 `json:"weights,omitempty"`
+	Since   time.Time       `json:"since,omitempty"`
+	Page    *uint           `json:"page,omitempty"`
+	Box     [2]float32      `json:"box,omitempty"`
+}
+
+// decoded returns value as json.Unmarshal would hand it to a tool.
+func decoded(t *testing.T, value string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestCoerce(t *testing.T) {
+	value := decoded(t, `{
+		"filters": [{"field": "name", "limit": 10}],
+		"weights": {"a": 3},
+		"since": "2024-05-01T00:00:00Z",
+		"page": 2,
+		"box": [1.5, 2]
+	}`)
+
+	got, err := Coerce(value, reflect.TypeFor[coerceQuery]())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	query := got.(coerceQuery)
+	if len(query.Filters) != 1 || query.Filters[0] != (coerceFilter{Field: "name", Limit: 10}) {
+		t.Errorf("unexpected filters %+v", query.Filters)
+	}
+	if query.Weights["a"] != 3 || query.Page == nil || *query.Page != 2 || query.Box != [2]float32{1.5, 2} {
+		t.Errorf("unexpected query %+v", query)
+	}
+	if !query.Since.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected since %v", query.Since)
+	}
+}
+
+func TestCoerce_Scalars(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		typ   reflect.Type
+		want  any
+		err   string
+	}{
+		{"whole number to int", 42.0, reflect.TypeFor[int](), 42, ""},
+		{"int to int64", 7, reflect.TypeFor[int64](), int64(7), ""},
+		{"fraction", 2.5, reflect.TypeFor[int](), nil, "$: 2.5 is not an integer"},
+		{"overflow", 300.0, reflect.TypeFor[int8](), nil, "$: 300 overflows int8"},
+		{"negative unsigned", -1.0, reflect.TypeFor[uint](), nil, "$: -1 overflows uint"},
+		{"float32 overflow", 1e40, reflect.TypeFor[float32](), nil, "$: 1e+40 overflows float32"},
+		{"string to int", "42", reflect.TypeFor[int](), nil, "$: expected int, got string"},
+		{"named string", "pass", reflect.TypeFor[coerceVerdict](), coerceVerdict("pass"), ""},
+		{"typed slice", []any{"a", "b"}, reflect.TypeFor[[]string](), []string{"a", "b"}, ""},
+		{"bytes", "aGk=", reflect.TypeFor[[]byte](), []byte("hi"), ""},
+		{"array length", []any{1.0}, reflect.TypeFor[[2]int](), nil, "$: expected 2 elements, got 1"},
+		{"int keys", map[string]any{"1": "a"}, reflect.TypeFor[map[int]string](), map[int]string{1: "a"}, ""},
+		{"bad key", map[string]any{"x": "a"}, reflect.TypeFor[map[int]string](), nil, `$.x: key "x" is not a valid int`},
+		{"nil", nil, reflect.TypeFor[[]int](), []int(nil), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Coerce(tt.value, tt.typ)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// coerceVerdict is a named string type, as enums usually are.
+type coerceVerdict string
+
+func TestCoerce_ReportsEveryField(t *testing.T) {
+	value := decoded(t, `{"filters": [{"field": 1, "limit": 1.5}, {"feild": "x"}], "page": -2}`)
+
+	_, err := Coerce(value, reflect.TypeFor[coerceQuery]())
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := []string{
+		"$.filters[0].field: expected string, got number",
+		"$.filters[0].limit: 1.5 is not an integer",
+		"$.filters[1].feild: unexpected field",
+		"$.page: -2 overflows uint",
+	}
+	if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors %q, want %q", got, want)
+	}
+}
+
+func TestCoerce_EmbeddedAndCaseInsensitive(t *testing.T) {
+	type Base struct {
+		ID string `json:"id"`
+	}
+	type item struct {
+		*Base
+		Name string
+	}
+
+	got, err := Coerce(map[string]any{"id": "1", "name": "x"}, reflect.TypeFor[item]())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if it := got.(item); it.Base == nil || it.ID != "1" || it.Name != "x" {
+		t.Errorf("unexpected item %+v", it)
+	}
+}
+
+func TestParameter_Value_Coerces(t *testing.T) {
+	count := NewParameter[int]("count", "A count", true, 0, nil)
+	if got, err := count.Value(float64(3)); err != nil || got != 3 {
+		t.Errorf("expected 3, got %v (%v)", got, err)
+	}
+	if _, err := count.Value(3.5); err == nil || err.Error() != "parameter count: $: 3.5 is not an integer" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	tool := NewTool[int]("sum", "Sums filter limits",
+		[]Parameter{NewParameter[[]coerceFilter]("filters", "Filters", true, nil, nil)},
+		func(ctx *Context, args Arguments) (int, error) {
+			total := 0
+			for _, filter := range args["filters"].([]coerceFilter) {
+				total += filter.Limit
+			}
+			return total, nil
+		},
+	)
+	result := tool.Execute(nil, decoded(t, `{"filters": [{"field": "a", "limit": 1}, {"field": "b", "limit": 2}]}`).(map[string]any))
+	if result.Errored() || result.GetResult() != 3 {
+		t.Errorf("expected 3, got %v (%v)", result.GetResult(), result.GetError())
+	}
+}
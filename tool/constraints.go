@@ -0,0 +1,217 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// ParameterOption configures a Parameter created with NewParameter or
+// FieldParameter, most often by constraining the values it accepts.
+// Constraints are enforced by Value, so every tool call is checked
+// against them, and described in the parameter's JSON schema so the
+// model can keep to them in the first place.
+type ParameterOption func(*Parameter)
+
+// constraints are the checks a Parameter's values must pass beyond its
+// type.
+type constraints struct {
+	enum       []any
+	minimum    *float64
+	maximum    *float64
+	minLength  *int
+	maxLength  *int
+	pattern    *regexp.Regexp
+	validators []func(value any) error
+	// items constrains the items of a slice or array
+	items *constraints
+	// err is a constraint that could not be set up, e.g. a bad pattern
+	err error
+}
+
+// WithEnum limits the parameter to the given values, which are
+// converted to its type, or the type it points to, as arguments are.
+func WithEnum(values ...any) ParameterOption {
+	return func(p *Parameter) {
+		t := p.Type()
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		for _, value := range values {
+			v, err := Coerce(value, t)
+			if err != nil {
+				p.constraints.err = errors.Join(p.constraints.err, fmt.Errorf("enum value %v: %w", value, err))
+				continue
+			}
+			p.constraints.enum = append(p.constraints.enum, v)
+		}
+	}
+}
+
+// WithMinimum sets the least value a numeric parameter may take.
+func WithMinimum(min float64) ParameterOption {
+	return func(p *Parameter) {
+		p.constraints.minimum = &min
+	}
+}
+
+// WithMaximum sets the greatest value a numeric parameter may take.
+func WithMaximum(max float64) ParameterOption {
+	return func(p *Parameter) {
+		p.constraints.maximum = &max
+	}
+}
+
+// WithMinLength sets the fewest characters a string parameter may have.
+func WithMinLength(n int) ParameterOption {
+	return func(p *Parameter) {
+		p.constraints.minLength = &n
+	}
+}
+
+// WithMaxLength sets the most characters a string parameter may have.
+func WithMaxLength(n int) ParameterOption {
+	return func(p *Parameter) {
+		p.constraints.maxLength = &n
+	}
+}
+
+// WithPattern requires a string parameter to match a regular expression.
+func WithPattern(pattern string) ParameterOption {
+	return func(p *Parameter) {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			p.constraints.err = errors.Join(p.constraints.err, fmt.Errorf("invalid pattern: %w", err))
+			return
+		}
+		p.constraints.pattern = re
+	}
+}
+
+// WithDescription replaces the parameter's description, e.g. one that
+// FieldParameter took from the field's description tag.
+func WithDescription(description string) ParameterOption {
+	return func(p *Parameter) {
+		p.description = description
+	}
+}
+
+// WithValidator checks the parameter's values with validate, whose error
+// is reported to the model as is; e.g.
+//
+//	tool.WithValidator(func(date string) error {
+//		if _, err := time.Parse(time.DateOnly, date); err != nil {
+//			return fmt.Errorf("%q is not a date in the form YYYY-MM-DD", date)
+//		}
+//		return nil
+//	})
+func WithValidator[T any](validate func(T) error) ParameterOption {
+	return func(p *Parameter) {
+		p.constraints.validators = append(p.constraints.validators, func(value any) error {
+			v, ok := value.(T)
+			if !ok {
+				return fmt.Errorf("validator expects %s, got %T", Type[T](), value)
+			}
+			return validate(v)
+		})
+	}
+}
+
+// Validate checks a value of the parameter's type against its
+// constraints, reporting every one it breaks. Nil values, i.e. omitted
+// optional arguments, are not checked.
+func (p *Parameter) Validate(value any) error {
+	if value == nil {
+		return nil
+	}
+	c := p.constraints
+	if c.err != nil {
+		return fmt.Errorf("parameter %s: %w", p.Name(), c.err)
+	}
+
+	errs := c.check(value)
+	for _, validate := range c.validators {
+		if err := validate(value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("parameter %s: %w", p.Name(), err)
+	}
+	return errors.Join(errs...)
+}
+
+// check returns the ways a value, or the value it points to, breaks the
+// constraints, and those of its items if it has items constraints.
+func (c constraints) check(value any) []error {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	value = rv.Interface()
+
+	var errs []error
+	if len(c.enum) > 0 && !slices.ContainsFunc(c.enum, func(allowed any) bool { return reflect.DeepEqual(allowed, value) }) {
+		errs = append(errs, fmt.Errorf("%v is not one of %v", value, c.enum))
+	}
+	if number, ok := toFloat(value); ok {
+		if c.minimum != nil && number < *c.minimum {
+			errs = append(errs, fmt.Errorf("%v is less than the minimum of %v", value, *c.minimum))
+		}
+		if c.maximum != nil && number > *c.maximum {
+			errs = append(errs, fmt.Errorf("%v is greater than the maximum of %v", value, *c.maximum))
+		}
+	}
+	if rv.Kind() == reflect.String {
+		s := rv.String()
+		length := utf8.RuneCountInString(s)
+		if c.minLength != nil && length < *c.minLength {
+			errs = append(errs, fmt.Errorf("%q is shorter than the minimum length of %d", s, *c.minLength))
+		}
+		if c.maxLength != nil && length > *c.maxLength {
+			errs = append(errs, fmt.Errorf("%q is longer than the maximum length of %d", s, *c.maxLength))
+		}
+		if c.pattern != nil && !c.pattern.MatchString(s) {
+			errs = append(errs, fmt.Errorf("%q does not match the pattern %q", s, c.pattern))
+		}
+	}
+	if c.items != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) {
+		for i := 0; i < rv.Len(); i++ {
+			for _, err := range c.items.check(rv.Index(i).Interface()) {
+				errs = append(errs, fmt.Errorf("$[%d]: %w", i, err))
+			}
+		}
+	}
+	return errs
+}
+
+// describe adds the parameter's constraints to its JSON schema.
+func (c constraints) describe(schema map[string]any) {
+	if len(c.enum) > 0 {
+		schema["enum"] = c.enum
+	}
+	if c.minimum != nil {
+		schema["minimum"] = *c.minimum
+	}
+	if c.maximum != nil {
+		schema["maximum"] = *c.maximum
+	}
+	if c.minLength != nil {
+		schema["minLength"] = *c.minLength
+	}
+	if c.maxLength != nil {
+		schema["maxLength"] = *c.maxLength
+	}
+	if c.pattern != nil {
+		schema["pattern"] = c.pattern.String()
+	}
+	if items, ok := schema["items"].(map[string]any); ok && c.items != nil {
+		c.items.describe(items)
+	}
+}
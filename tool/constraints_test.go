@@ -0,0 +1,96 @@
+package tool
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParameter_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		param Parameter
+		value any
+		err   string
+	}{
+		{"enum", NewParameter[string]("unit", "", true, "", nil, WithEnum("celsius", "fahrenheit")), "kelvin", "parameter unit: kelvin is not one of [celsius fahrenheit]"},
+		{"enum coerced", NewParameter[int64]("n", "", true, 0, nil, WithEnum(1, 2)), int64(2), ""},
+		{"minimum", NewParameter[int]("limit", "", true, 0, nil, WithMinimum(1)), 0, "parameter limit: 0 is less than the minimum of 1"},
+		{"maximum", NewParameter[float64]("ratio", "", true, 0, nil, WithMaximum(1)), 1.5, "parameter ratio: 1.5 is greater than the maximum of 1"},
+		{"min length", NewParameter[string]("q", "", true, "", nil, WithMinLength(3)), "ab", `parameter q: "ab" is shorter than the minimum length of 3`},
+		{"max length", NewParameter[string]("q", "", true, "", nil, WithMaxLength(2)), "héé", `parameter q: "héé" is longer than the maximum length of 2`},
+		{"pattern", NewParameter[string]("id", "", true, "", nil, WithPattern(`^[a-z]+$`)), "A1", `parameter id: "A1" does not match the pattern "^[a-z]+$"`},
+		{"bad pattern", NewParameter[string]("id", "", true, "", nil, WithPattern(`(`)), "a", "parameter id: invalid pattern: error parsing regexp: missing closing ): `(`"},
+		{"validator", NewParameter[int]("n", "", true, 0, nil, WithValidator(func(n int) error {
+			if n%2 != 0 {
+				return fmt.Errorf("%d is odd", n)
+			}
+			return nil
+		})), 3, "parameter n: 3 is odd"},
+		{"nil", NewParameter[int]("n", "", false, 0, nil, WithMinimum(1)), nil, ""},
+		{"aggregated", NewParameter[string]("q", "", true, "", nil, WithMinLength(3), WithPattern(`^\d+$`)), "a", "parameter q: \"a\" is shorter than the minimum length of 3\nparameter q: \"a\" does not match the pattern \"^\\\\d+$\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.param.Validate(tt.value)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestParametersToJSONSchema_Constraints(t *testing.T) {
+	schema := ParametersToJSONSchema([]Parameter{
+		NewParameter[string]("unit", "Unit", true, "", nil, WithEnum("c", "f")),
+		NewParameter[int]("days", "Days", false, 1, nil, WithMinimum(1), WithMaximum(14)),
+		NewParameter[string]("city", "City", true, "", nil, WithMinLength(2), WithMaxLength(40), WithPattern(`^\p{L}`)),
+	})
+	props := schema["properties"].(map[string]any)
+
+	if unit := props["unit"].(map[string]any); !reflect.DeepEqual(unit["enum"], []any{"c", "f"}) {
+		t.Errorf("unexpected unit schema: %v", unit)
+	}
+	if days := props["days"].(map[string]any); days["minimum"] != 1.0 || days["maximum"] != 14.0 {
+		t.Errorf("unexpected days schema: %v", days)
+	}
+	city := props["city"].(map[string]any)
+	if city["minLength"] != 2 || city["maxLength"] != 40 || city["pattern"] != `^\p{L}` {
+		t.Errorf("unexpected city schema: %v", city)
+	}
+
+	if err := ValidateJSONSchema(schema, map[string]any{"unit": "k", "days": 20.0, "city": "x"}); err == nil {
+		t.Error("expected the schema to enforce the constraints")
+	}
+}
+
+func TestTool_Execute_ReportsEveryInvalidArgument(t *testing.T) {
+	tool := NewTool[string]("forecast", "Forecasts weather",
+		[]Parameter{
+			NewParameter[string]("city", "City", true, "", nil, WithMinLength(2)),
+			NewParameter[int]("days", "Days", false, 1, nil, WithMaximum(14)),
+		},
+		func(ctx *Context, args Arguments) (string, error) { return "sunny", nil },
+	)
+
+	result := tool.Execute(nil, Arguments{"city": "x", "days": 30.0, "units": "c"})
+	if !result.Errored() {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		`argument city: parameter city: "x" is shorter than the minimum length of 2`,
+		"argument days: parameter days: 30 is greater than the maximum of 14",
+		"unknown argument: units",
+	} {
+		if !strings.Contains(result.GetError().Error(), expected) {
+			t.Errorf("expected %q in %v", expected, result.GetError())
+		}
+	}
+}
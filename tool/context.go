@@ -1,9 +1,11 @@
 package tool
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hlfshell/gotonomy/data/ledger"
@@ -11,6 +13,12 @@ import (
 
 type ContextID string
 
+// Context is a node in an Execution. It also implements context.Context:
+// each node carries a standard context derived from its parent's, so
+// cancelling a node (or the whole Execution) tears down its entire
+// subtree, and a *Context can be passed directly to any API expecting
+// a context.Context. All context.Context methods are safe on a nil
+// *Context, behaving like context.Background().
 type Context struct {
 	id        ContextID
 	toolName  string
@@ -38,9 +46,24 @@ type Context struct {
 
 	stats Stats
 
+	// std carries cancellation, deadlines and values for this node; it
+	// is derived from the parent node's std (or the Execution's).
+	std    context.Context
+	cancel context.CancelFunc
+
 	mu sync.RWMutex
 }
 
+// Ensure Context satisfies context.Context.
+var _ context.Context = (*Context)(nil)
+
+// NewContext creates a blank Context bound to parent. Passing it to a
+// tool's Execute makes that tool the root of a new Execution whose
+// cancellation and deadline follow parent.
+func NewContext(parent context.Context) *Context {
+	return blankContext(newExecution(parent))
+}
+
 func (c *Context) MarshalJSON() ([]byte, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -134,6 +157,15 @@ func (c *Context) ID() ContextID {
 	return c.id
 }
 
+// Parent returns the node's parent, or nil if it is the root of its
+// execution.
+func (c *Context) Parent() *Context {
+	if c.execution == nil || c.parent == "" {
+		return nil
+	}
+	return c.execution.Context(c.parent)
+}
+
 // SetOutput sets the output result for this node
 func (c *Context) SetOutput(output ResultInterface) {
 	c.mu.Lock()
@@ -141,6 +173,112 @@ func (c *Context) SetOutput(output ResultInterface) {
 	c.output = output
 }
 
+// stdContext returns the standard context backing this node.
+func (c *Context) stdContext() context.Context {
+	if c == nil {
+		return context.Background()
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.std == nil {
+		return context.Background()
+	}
+	return c.std
+}
+
+// Deadline implements context.Context.
+func (c *Context) Deadline() (time.Time, bool) {
+	return c.stdContext().Deadline()
+}
+
+// Done implements context.Context.
+func (c *Context) Done() <-chan struct{} {
+	return c.stdContext().Done()
+}
+
+// Err implements context.Context.
+func (c *Context) Err() error {
+	return c.stdContext().Err()
+}
+
+// Value implements context.Context.
+func (c *Context) Value(key any) any {
+	return c.stdContext().Value(key)
+}
+
+// Cancel cancels this node and every node derived from it. Other
+// branches of the Execution are unaffected.
+func (c *Context) Cancel() {
+	if c == nil {
+		return
+	}
+	c.mu.RLock()
+	cancel := c.cancel
+	c.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// SetDeadline narrows this node's deadline for the rest of its life.
+// Only children created afterwards inherit it, so call it before
+// spawning child tools. To narrow it for a single call, use
+// NarrowDeadline.
+func (c *Context) SetDeadline(deadline time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	parent := c.std
+	if parent == nil {
+		parent = context.Background()
+	}
+	std, cancel := context.WithDeadline(parent, deadline)
+	// Cancelling the node releases every deadline set on it
+	previous := c.cancel
+	c.std = std
+	c.cancel = func() {
+		cancel()
+		if previous != nil {
+			previous()
+		}
+	}
+}
+
+// SetTimeout narrows this node's deadline to timeout from now. See
+// SetDeadline.
+func (c *Context) SetTimeout(timeout time.Duration) {
+	c.SetDeadline(time.Now().Add(timeout))
+}
+
+// NarrowDeadline narrows this node's deadline until the returned function
+// is called, which restores the deadline it had before and releases the
+// timer. Children created in between inherit the narrower deadline and
+// are cancelled by restore; e.g. to bound a single Execute:
+//
+//	defer ctx.NarrowTimeout(time.Minute)()
+func (c *Context) NarrowDeadline(deadline time.Time) (restore func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	previous := c.std
+	parent := previous
+	if parent == nil {
+		parent = context.Background()
+	}
+	std, cancel := context.WithDeadline(parent, deadline)
+	c.std = std
+	return func() {
+		c.mu.Lock()
+		c.std = previous
+		c.mu.Unlock()
+		cancel()
+	}
+}
+
+// NarrowTimeout narrows this node's deadline to timeout from now until
+// the returned function is called. See NarrowDeadline.
+func (c *Context) NarrowTimeout(timeout time.Duration) (restore func()) {
+	return c.NarrowDeadline(time.Now().Add(timeout))
+}
+
 // isBlank returns true if the context is blank (both ID and tool name are unset)
 func (c *Context) isBlank() bool {
 	return c.id == "" && c.toolName == ""
@@ -178,6 +316,7 @@ func PrepareContext(ctx *Context, tool Tool, args Arguments) *Context {
 }
 
 func blankContext(e *Execution) *Context {
+	std, cancel := context.WithCancel(e.stdContext())
 	return &Context{
 		id:          "",
 		toolName:    "",
@@ -191,6 +330,8 @@ func blankContext(e *Execution) *Context {
 		contextData: nil,          // will be set in fillBlankContext
 		scopedData:  make(map[string]*ledger.ScopedLedger),
 		stats:       Stats{},
+		std:         std,
+		cancel:      cancel,
 		mu:          sync.RWMutex{},
 	}
 }
@@ -1,7 +1,9 @@
 package tool
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -47,8 +49,6 @@ func TestNode_SetOutput(t *testing.T) {
 	}
 }
 
-
-
 func TestNode_Data(t *testing.T) {
 	rootTool := newMockTool("test-tool")
 	_, node := NewExecution(rootTool, Arguments{})
@@ -361,7 +361,6 @@ func TestNode_DataSharing(t *testing.T) {
 		t.Fatal("Root and child2 should share same globalData")
 	}
 
-
 	// But contextData should be different
 	if root.contextData == child1.contextData {
 		t.Fatal("Root and child1 should have different contextData")
@@ -427,3 +426,125 @@ func TestNode_ParentRelationship(t *testing.T) {
 		t.Errorf("Child should have parent %s, got %s", root.ID(), child.parent)
 	}
 }
+
+func TestNode_Cancellation(t *testing.T) {
+	rootTool := newMockTool("root-tool")
+	e, root := NewExecution(rootTool, Arguments{})
+	childA := e.createChild(root.ID(), newMockTool("a"), Arguments{})
+	childB := e.createChild(root.ID(), newMockTool("b"), Arguments{})
+	grandchild := e.createChild(childA.ID(), newMockTool("c"), Arguments{})
+
+	// Cancelling a branch only affects its subtree
+	childA.Cancel()
+	if grandchild.Err() == nil {
+		t.Error("Grandchild should be cancelled with its parent")
+	}
+	if childB.Err() != nil || root.Err() != nil {
+		t.Error("Sibling and root should be unaffected by cancelling a branch")
+	}
+
+	// Cancelling the execution tears down the whole tree
+	e.Cancel()
+	select {
+	case <-childB.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Cancelling the execution should cancel every node")
+	}
+	if !errors.Is(root.Err(), context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", root.Err())
+	}
+}
+
+func TestNode_NewContextFollowsParent(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+
+	ctx := NewContext(parent)
+	if !ctx.isBlank() {
+		t.Fatal("NewContext should return a blank context")
+	}
+
+	var observed error
+	tl := NewTool[string]("waiter", "waits", nil, func(ctx *Context, args Arguments) (string, error) {
+		if ctx.Value(key{}) != "value" {
+			t.Error("Values from the parent context should be visible")
+		}
+		cancel()
+		<-ctx.Done()
+		observed = ctx.Err()
+		return "", ctx.Err()
+	})
+	result := tl.Execute(ctx, Arguments{})
+	if !result.Errored() {
+		t.Error("Expected an error result after cancellation")
+	}
+	if !errors.Is(observed, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", observed)
+	}
+
+	// New work on a cancelled context is refused before the handler runs
+	called := false
+	again := NewTool[string]("again", "runs", nil, func(ctx *Context, args Arguments) (string, error) {
+		called = true
+		return "", nil
+	})
+	if !again.Execute(ctx, Arguments{}).Errored() || called {
+		t.Error("Tools should not run on a cancelled context")
+	}
+}
+
+func TestNode_SetTimeout(t *testing.T) {
+	_, root := NewExecution(newMockTool("root-tool"), Arguments{})
+	root.SetTimeout(10 * time.Millisecond)
+	if _, ok := root.Deadline(); !ok {
+		t.Fatal("Expected a deadline to be set")
+	}
+
+	child := root.execution.createChild(root.ID(), newMockTool("child"), Arguments{})
+	select {
+	case <-child.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Child should inherit the parent's deadline")
+	}
+	if !errors.Is(child.Err(), context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", child.Err())
+	}
+}
+
+func TestNode_SetDeadlineKeepsCancel(t *testing.T) {
+	_, root := NewExecution(newMockTool("root-tool"), Arguments{})
+	root.SetTimeout(time.Hour)
+	child := root.execution.createChild(root.ID(), newMockTool("child"), Arguments{})
+	root.SetTimeout(time.Hour)
+
+	// Cancelling the node cancels what derived from every deadline set on it
+	root.Cancel()
+	if !errors.Is(child.Err(), context.Canceled) {
+		t.Errorf("Expected the child to be cancelled, got %v", child.Err())
+	}
+}
+
+func TestNode_NarrowTimeout(t *testing.T) {
+	_, root := NewExecution(newMockTool("root-tool"), Arguments{})
+	restore := root.NarrowTimeout(time.Hour)
+	if _, ok := root.Deadline(); !ok {
+		t.Fatal("Expected a deadline to be set")
+	}
+	child := root.execution.createChild(root.ID(), newMockTool("child"), Arguments{})
+
+	restore()
+	if _, ok := root.Deadline(); ok || root.Err() != nil {
+		t.Error("Expected restore to remove the deadline")
+	}
+	if !errors.Is(child.Err(), context.Canceled) {
+		t.Errorf("Expected children made in between to be released, got %v", child.Err())
+	}
+}
+
+func TestNode_NilContextIsBackground(t *testing.T) {
+	var ctx *Context
+	if ctx.Err() != nil || ctx.Done() != nil || ctx.Value("k") != nil {
+		t.Error("A nil Context should behave like context.Background()")
+	}
+	ctx.Cancel()
+}
@@ -1,6 +1,7 @@
 package tool
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -21,27 +22,63 @@ type Execution struct {
 	data       *ledger.Ledger
 	globalData *ledger.ScopedLedger
 
+	// std is the standard context every node derives from; cancelling
+	// it tears down the whole execution tree.
+	std    context.Context
+	cancel context.CancelFunc
+
 	// Mutex for thread safety
 	mu sync.RWMutex
 }
 
 // NewExecution creates a fresh execution and a fully initialized root context.
 func NewExecution(tool Tool, args Arguments) (*Execution, *Context) {
+	return NewExecutionWithContext(context.Background(), tool, args)
+}
+
+// NewExecutionWithContext creates a fresh execution whose cancellation
+// and deadline follow parent, along with its fully initialized root context.
+func NewExecutionWithContext(parent context.Context, tool Tool, args Arguments) (*Execution, *Context) {
+	e := newExecution(parent)
+	root := blankContext(e)
+	fillBlankContext(root, tool, args) // sets id, toolName, contextData, root id, etc.
+	return e, root
+}
+
+func newExecution(parent context.Context) *Execution {
+	if parent == nil {
+		parent = context.Background()
+	}
 	data := ledger.NewLedger()
 
 	globalData, _ := ledger.NewScoped(data, "global")
 
-	e := &Execution{
+	std, cancel := context.WithCancel(parent)
+	return &Execution{
 		root:       "",
 		ctxs:       make(map[ContextID]*Context),
 		data:       data,
 		globalData: globalData,
+		std:        std,
+		cancel:     cancel,
 		mu:         sync.RWMutex{},
 	}
+}
 
-	root := blankContext(e)
-	fillBlankContext(root, tool, args) // sets id, toolName, contextData, root id, etc.
-	return e, root
+// Cancel cancels every context in the execution, signalling all running
+// tools, agents and model calls to stop.
+func (e *Execution) Cancel() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+}
+
+// stdContext returns the execution's base standard context.
+func (e *Execution) stdContext() context.Context {
+	if e.std == nil {
+		return context.Background()
+	}
+	return e.std
 }
 
 // Tree returns an adjacency list mapping each context ID to the IDs of its direct children (if any).
@@ -161,6 +198,9 @@ func (e *Execution) createChild(parentID ContextID, tool Tool, args Arguments) *
 		return nil
 	}
 
+	// Derive from the parent so cancelling it cancels this child too
+	std, cancel := context.WithCancel(parent.stdContext())
+
 	child := &Context{
 		id:          id,
 		toolName:    tool.Name(),
@@ -174,6 +214,8 @@ func (e *Execution) createChild(parentID ContextID, tool Tool, args Arguments) *
 		stats:       Stats{},
 		input:       args,
 		output:      nil,
+		std:         std,
+		cancel:      cancel,
 		mu:          sync.RWMutex{},
 	}
 
@@ -0,0 +1,52 @@
+package tool
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// NewToolFromFunc makes a tool of an ordinary Go function, deriving its
+// parameters from the fields of the function's input struct and their
+// tags with StructParameters, as the tools gentool generates do. The
+// arguments are validated and converted with Coerce before being decoded
+// into In, and the function's output is wrapped in a Result.
+//
+// In must be a struct or a pointer to one; NewToolFromFunc panics
+// otherwise.
+//
+// Example:
+//
+//	type ForecastRequest struct {
+//		City string `json:"city" description:"The city to forecast" minLength:"2"`
+//		Days int    `json:"days,omitempty" minimum:"1" maximum:"7" default:"3"`
+//	}
+//
+//	forecast := tool.NewToolFromFunc("get_forecast", "Gets the weather forecast", weather.Forecast)
+func NewToolFromFunc[In, Out any](
+	name, description string,
+	fn func(ctx *Context, in In) (Out, error),
+) Tool {
+	inType := Type[In]()
+	structType := inType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("tool %s: input must be a struct, got %s", name, inType))
+	}
+
+	return NewTool[Out](
+		name,
+		description,
+		StructParameters(structType),
+		func(ctx *Context, args Arguments) (Out, error) {
+			// Omitted optional arguments are left as their fields' zero value
+			in, err := Coerce(args, inType)
+			if err != nil {
+				var zero Out
+				return zero, fmt.Errorf("tool %s: %w", name, err)
+			}
+			return fn(ctx, in.(In))
+		},
+	)
+}
@@ -0,0 +1,167 @@
+package tool
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type forecastRequest struct {
+	City   string   `json:"city" description:"The city to forecast" minLength:"2"`
+	Days   int      `json:"days,omitempty" minimum:"1" maximum:"7" default:"3"`
+	Unit   string   `json:"unit,omitempty" enum:"celsius,fahrenheit"`
+	Extras []string `json:"extras" required:"false"`
+	Note   string   `json:"-"`
+	hidden string
+}
+
+type forecast struct {
+	City string
+	Days int
+	Unit string
+}
+
+func getForecast(ctx *Context, in forecastRequest) (forecast, error) {
+	if in.City == "Atlantis" {
+		return forecast{}, errors.New("no such city")
+	}
+	return forecast{City: in.City, Days: in.Days, Unit: in.Unit}, nil
+}
+
+func TestNewToolFromFunc_Parameters(t *testing.T) {
+	tool := NewToolFromFunc("get_forecast", "Gets the forecast", getForecast)
+
+	params := tool.Parameters()
+	var names []string
+	for _, p := range params {
+		names = append(names, p.Name())
+	}
+	if want := []string{"city", "days", "unit", "extras"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("parameters = %v, want %v", names, want)
+	}
+
+	city, days, extras := params[0], params[1], params[3]
+	if city.Description() != "The city to forecast" || !city.Required() || city.Type() != Type[string]() {
+		t.Errorf("city = %+v", city)
+	}
+	if days.Required() || days.Default() != 3 || days.Type() != Type[int]() {
+		t.Errorf("days = %+v", days)
+	}
+	if extras.Required() || extras.Type() != Type[[]string]() {
+		t.Errorf("extras = %+v", extras)
+	}
+
+	schema := ParametersToJSONSchema(params)
+	properties := schema["properties"].(map[string]any)
+	if got := properties["days"].(map[string]any)["maximum"]; got != 7.0 {
+		t.Errorf("days maximum = %v, want 7", got)
+	}
+	if got := properties["unit"].(map[string]any)["enum"]; !reflect.DeepEqual(got, []any{"celsius", "fahrenheit"}) {
+		t.Errorf("unit enum = %v", got)
+	}
+	if got := schema["required"]; !reflect.DeepEqual(got, []string{"city"}) {
+		t.Errorf("required = %v, want [city]", got)
+	}
+}
+
+func TestNewToolFromFunc_Execute(t *testing.T) {
+	tool := NewToolFromFunc("get_forecast", "Gets the forecast", getForecast)
+
+	result := tool.Execute(nil, decoded(t, `{"city": "Boston", "unit": "celsius", "extras": ["wind"]}`).(map[string]any))
+	if result.Errored() {
+		t.Fatalf("Execute() errored unexpectedly: %v", result.GetError())
+	}
+	typed, ok := result.(Result[forecast])
+	if !ok {
+		t.Fatalf("result is %T, want Result[forecast]", result)
+	}
+	if want := (forecast{City: "Boston", Days: 3, Unit: "celsius"}); typed.Result != want {
+		t.Errorf("result = %+v, want %+v", typed.Result, want)
+	}
+
+	result = tool.Execute(nil, Arguments{"city": "Boston", "days": 2.0})
+	if got := result.GetResult().(forecast).Days; got != 2 {
+		t.Errorf("days = %v, want 2", got)
+	}
+}
+
+func TestNewToolFromFunc_InvalidArguments(t *testing.T) {
+	tool := NewToolFromFunc("get_forecast", "Gets the forecast", getForecast)
+
+	tests := []struct {
+		name string
+		args Arguments
+		want string
+	}{
+		{"missing required", Arguments{}, "argument city"},
+		{"too short", Arguments{"city": "B"}, "shorter than the minimum length"},
+		{"fractional", Arguments{"city": "Boston", "days": 2.5}, "not an integer"},
+		{"out of range", Arguments{"city": "Boston", "days": 10.0}, "greater than the maximum"},
+		{"not in enum", Arguments{"city": "Boston", "unit": "kelvin"}, "not one of"},
+		{"unknown", Arguments{"city": "Boston", "note": "hi"}, "note"},
+		{"function error", Arguments{"city": "Atlantis"}, "no such city"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tool.Execute(nil, tt.args)
+			if !result.Errored() {
+				t.Fatalf("Execute() = %v, want an error", result.GetResult())
+			}
+			if !strings.Contains(result.GetError().Error(), tt.want) {
+				t.Errorf("error = %v, want it to mention %q", result.GetError(), tt.want)
+			}
+		})
+	}
+}
+
+type Pagination struct {
+	Page int `json:"page,omitempty"`
+}
+
+type searchRequest struct {
+	*Pagination
+	Query string `json:"query"`
+}
+
+func TestNewToolFromFunc_PointerInputAndEmbeddedFields(t *testing.T) {
+	tool := NewToolFromFunc("search", "Searches", func(ctx *Context, in *searchRequest) (string, error) {
+		return strings.Repeat(in.Query, in.Page), nil
+	})
+	if n := len(tool.Parameters()); n != 2 {
+		t.Fatalf("len(Parameters()) = %d, want 2", n)
+	}
+
+	result := tool.Execute(nil, Arguments{"query": "go", "page": 2.0})
+	if result.Errored() {
+		t.Fatalf("Execute() errored unexpectedly: %v", result.GetError())
+	}
+	if result.GetResult() != "gogo" {
+		t.Errorf("result = %v, want gogo", result.GetResult())
+	}
+}
+
+func TestNewToolFromFunc_BadTag(t *testing.T) {
+	type request struct {
+		Count int `json:"count" maximum:"lots"`
+	}
+	tool := NewToolFromFunc("count", "Counts", func(ctx *Context, in request) (int, error) {
+		return in.Count, nil
+	})
+
+	result := tool.Execute(nil, Arguments{"count": 1.0})
+	if !result.Errored() || !strings.Contains(result.GetError().Error(), "maximum tag") {
+		t.Errorf("Execute() error = %v, want a maximum tag error", result.GetError())
+	}
+}
+
+func TestNewToolFromFunc_NonStructInput(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewToolFromFunc() did not panic for a string input")
+		}
+	}()
+	NewToolFromFunc("echo", "Echoes", func(ctx *Context, in string) (string, error) {
+		return in, nil
+	})
+}
@@ -1,6 +1,7 @@
 package tool
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -13,6 +14,7 @@ type Parameter struct {
 	required       bool
 	defaultValue   any
 	stringFunction func(value any) (string, error)
+	constraints    constraints
 }
 
 // String returns a human-readable string for the given value using the parameter's
@@ -64,14 +66,16 @@ func (p *Parameter) Default() any {
 //		false,
 //		10,
 //		func(v int) (string, error) { return fmt.Sprintf("%d", v), nil },
+//		WithMinimum(1), WithMaximum(100), // constraints
 //	)
 func NewParameter[T any](
 	name, description string,
 	required bool,
 	defaultValue T,
 	stringFunc func(T) (string, error),
+	options ...ParameterOption,
 ) Parameter {
-	return Parameter{
+	param := Parameter{
 		name:         name,
 		description:  description,
 		value_type:   Type[T](),
@@ -87,6 +91,10 @@ func NewParameter[T any](
 			return stringFunc(v)
 		},
 	}
+	for _, option := range options {
+		option(&param)
+	}
+	return param
 }
 
 // Required returns whether the argument is required.
@@ -117,21 +125,70 @@ func (a *Parameter) TypeCheck(value any) error {
 	return nil
 }
 
-// Value applies defaulting and type validation and returns the final value.
+// Value applies defaulting, coercion and type validation and returns the final value.
 // Semantics:
 // - If value is nil and a non-nil default exists, the default is used.
 // - If after defaulting the value is nil and the parameter is required, an error is returned.
 // - A required parameter with a non-nil default is considered satisfied after defaults are applied.
+// - Values decoded from JSON are converted to the parameter's type with Coerce (e.g. float64 to int).
+// - Struct, slice and map values so converted must match the JSON schema of the type, tags and all.
+// - The value must then pass the parameter's constraints; see Validate.
 func (a *Parameter) Value(value any) (any, error) {
 	if value == nil && a.Default() != nil {
 		value = a.Default()
 	}
+	if value != nil && a.Type() != nil {
+		coerced, err := Coerce(value, a.Type())
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", a.Name(), err)
+		}
+		if err := a.checkSchema(value); err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", a.Name(), err)
+		}
+		value = coerced
+	}
 	if err := a.TypeCheck(value); err != nil {
 		return nil, err
 	}
+	if err := a.Validate(value); err != nil {
+		return nil, err
+	}
 	return value, nil
 }
 
+// checkSchema checks a value of a struct, slice or map parameter that is
+// not already of its type, i.e. as decoded from JSON, against the JSON
+// schema of the type, enforcing the constraints in its struct tags and
+// the presence of required fields that Coerce alone does not.
+func (a *Parameter) checkSchema(value any) error {
+	t := a.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return nil
+	}
+	if reflect.TypeOf(value).AssignableTo(a.Type()) {
+		return nil
+	}
+	// Types that decode themselves may accept other shapes than their schema's
+	if t != timeType && (reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType)) {
+		return nil
+	}
+	// Normalize to the form json.Unmarshal produces, e.g. int to float64
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	return ValidateJSONSchema(TypeToJSONSchema(a.Type()), decoded)
+}
+
 // typeToJSONSchemaType maps Go reflect.Kind to JSON schema type string.
 func typeToJSONSchemaType(kind reflect.Kind) string {
 	switch kind {
@@ -154,15 +211,17 @@ func typeToJSONSchemaType(kind reflect.Kind) string {
 }
 
 // ParametersToJSONSchema converts a list of Parameters to a JSON schema map.
+// Each parameter is described in full as TypeToJSONSchema describes its type,
+// with the definitions of any recursive types gathered in the root's $defs.
 func ParametersToJSONSchema(params []Parameter) map[string]any {
 	properties := make(map[string]any, len(params))
 	required := make([]string, 0, len(params))
 
+	g := newSchemaGenerator()
 	for _, param := range params {
-		prop := map[string]any{
-			"type":        typeToJSONSchemaType(param.Type().Kind()),
-			"description": param.Description(),
-		}
+		prop := g.schema(param.Type())
+		prop["description"] = param.Description()
+		param.constraints.describe(prop)
 		if param.Default() != nil {
 			prop["default"] = param.Default()
 		}
@@ -181,6 +240,9 @@ func ParametersToJSONSchema(params []Parameter) map[string]any {
 	if len(required) > 0 {
 		schema["required"] = required
 	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
 
 	return schema
 }
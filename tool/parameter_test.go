@@ -3,6 +3,7 @@ package tool
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -245,6 +246,44 @@ func TestParameter_Value(t *testing.T) {
 	}
 }
 
+// TestParameter_Value_StructTags tests that the tags of struct parameters are enforced
+func TestParameter_Value_StructTags(t *testing.T) {
+	type query struct {
+		Limit int    `json:"limit" minimum:"1" maximum:"10"`
+		Sort  string `json:"sort" enum:"a,b"`
+	}
+	param := NewParameter[query]("query", "The query", true, query{}, nil)
+
+	got, err := param.Value(map[string]any{"limit": 5, "sort": "a"})
+	if err != nil {
+		t.Fatalf("Value() errored unexpectedly: %v", err)
+	}
+	if want := (query{Limit: 5, Sort: "a"}); got != want {
+		t.Errorf("Value() = %+v, want %+v", got, want)
+	}
+
+	queries := NewParameter[[]query]("queries", "The queries", true, nil, nil)
+	tests := []struct {
+		name  string
+		param Parameter
+		value any
+		want  string
+	}{
+		{"above maximum", param, map[string]any{"limit": 500, "sort": "a"}, "$.limit: 500 is greater than the maximum of 10"},
+		{"outside enum", param, map[string]any{"limit": 5, "sort": "zzz"}, "$.sort: zzz is not one of [a b]"},
+		{"missing required", param, map[string]any{"limit": 5}, `missing required field "sort"`},
+		{"in a slice", queries, []any{map[string]any{"limit": 0.0, "sort": "b"}}, "$[0].limit: 0 is less than the minimum of 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.param.Value(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Value() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
 // TestParameter_StringFunction tests string conversion
 func TestParameter_StringFunction(t *testing.T) {
 	tests := []struct {
@@ -477,6 +516,29 @@ func TestParametersToJSONSchema(t *testing.T) {
 	}
 }
 
+// TestParametersToJSONSchema_NestedTypes tests that struct parameters are described in full
+func TestParametersToJSONSchema_NestedTypes(t *testing.T) {
+	params := []Parameter{
+		NewParameter[schemaQuery]("query", "The query", true, schemaQuery{}, nil),
+		NewParameter[[]schemaNode]("filters", "Filters", false, nil, nil),
+	}
+
+	schema := ParametersToJSONSchema(params)
+	props := schema["properties"].(map[string]any)
+
+	query := props["query"].(map[string]any)
+	if query["description"] != "The query" || query["properties"].(map[string]any)["limit"] == nil {
+		t.Errorf("expected the struct's fields to be described, got %v", query)
+	}
+	filters := props["filters"].(map[string]any)
+	if filters["items"].(map[string]any)["$ref"] != "#/$defs/schemaNode" {
+		t.Errorf("expected the items to refer to a definition, got %v", filters)
+	}
+	if _, ok := schema["$defs"].(map[string]any)["schemaNode"]; !ok {
+		t.Errorf("expected definitions at the root, got %v", schema["$defs"])
+	}
+}
+
 // TestTypeToJSONSchemaType tests type mapping
 func TestTypeToJSONSchemaType(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,364 @@
+package tool
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TypeToJSONSchema derives a JSON schema from a Go type by reflection,
+// following the encoding/json rules for field names. Struct fields are
+// required unless tagged omitempty, and structs do not allow additional
+// properties. Pointers are described by the type they point to, and
+// time.Time as a date-time string. A type that refers back to itself is
+// described once under $defs and referred to with $ref.
+//
+// Struct fields may be further described with the tags StructParameters
+// reads; tags that cannot be parsed are left out:
+//
+//	type Query struct {
+//		Text  string   `json:"text" description:"What to search for" pattern:"^\\S"`
+//		Sort  string   `json:"sort,omitempty" enum:"relevance,date" default:"relevance"`
+//		Limit int      `json:"limit" minimum:"1" maximum:"100" required:"false"`
+//		Tags  []string `json:"tags" enum:"news,blogs" maxLength:"20"`
+//	}
+func TypeToJSONSchema(t reflect.Type) map[string]any {
+	g := newSchemaGenerator()
+	return g.document(g.schema(t))
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// schemaGenerator derives schemas, collecting those of recursive types
+// to be placed under $defs.
+type schemaGenerator struct {
+	visiting  map[reflect.Type]bool
+	recursive map[reflect.Type]bool
+	names     map[reflect.Type]string
+	defs      map[string]any
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		visiting:  map[reflect.Type]bool{},
+		recursive: map[reflect.Type]bool{},
+		names:     map[reflect.Type]string{},
+		defs:      map[string]any{},
+	}
+}
+
+// document adds the collected $defs to a root schema. A root that is
+// itself a $ref is replaced by the definition it refers to, so that the
+// root always describes its type directly.
+func (g *schemaGenerator) document(schema map[string]any) map[string]any {
+	if len(g.defs) == 0 {
+		return schema
+	}
+	if ref, ok := schema["$ref"].(string); ok && len(schema) == 1 {
+		schema = maps.Clone(g.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any))
+	}
+	schema["$defs"] = g.defs
+	return schema
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Slice, reflect.Array:
+		// []byte is encoded as a base64 string
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string"}
+		}
+		return map[string]any{
+			"type":  "array",
+			"items": g.schema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": g.schema(t.Elem()),
+		}
+	case reflect.Struct:
+		if g.visiting[t] {
+			g.recursive[t] = true
+			return g.ref(t)
+		}
+		if _, ok := g.defs[g.names[t]]; ok && g.recursive[t] {
+			return g.ref(t)
+		}
+		g.visiting[t] = true
+		schema := g.structSchema(t)
+		delete(g.visiting, t)
+		if g.recursive[t] {
+			g.defs[g.names[t]] = schema
+			return g.ref(t)
+		}
+		return schema
+	default:
+		return map[string]any{"type": typeToJSONSchemaType(t.Kind())}
+	}
+}
+
+// ref returns a $ref to t's definition, naming it after the type.
+func (g *schemaGenerator) ref(t reflect.Type) map[string]any {
+	name, ok := g.names[t]
+	if !ok {
+		base := t.Name()
+		if base == "" {
+			base = "object"
+		}
+		name = base
+		for i := 2; slices.Contains(slices.Collect(maps.Values(g.names)), name); i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		g.names[t] = name
+	}
+	return map[string]any{"$ref": "#/$defs/" + name}
+}
+
+// structSchema describes the JSON encoding of a struct; fields of
+// embedded structs are promoted as encoding/json does.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, skip := jsonField(field)
+			if skip {
+				continue
+			}
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+				addFields(fieldType)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			tags := parseFieldTags(field)
+			fieldSchema := g.schema(field.Type)
+			tags.describe(fieldSchema)
+			properties[tags.name] = fieldSchema
+			if tags.required && !slices.Contains(required, tags.name) {
+				required = append(required, tags.name)
+			}
+		}
+	}
+	addFields(t)
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonField returns the JSON name given by a field's tag (empty if
+// none), whether it is omitempty, and whether it is skipped entirely.
+func jsonField(field reflect.StructField) (name string, omitempty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" || option == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+// ValidateJSONSchema checks a decoded JSON value (as produced by
+// json.Unmarshal into an any) against a schema such as those produced by
+// TypeToJSONSchema. It understands the type, enum, properties, required,
+// additionalProperties, items, minimum, maximum, minLength, maxLength,
+// pattern and format (date-time) keywords, and $ref to the schema's own
+// $defs, and reports every mismatch found along with its path, e.g.
+// "$.steps[0].id".
+func ValidateJSONSchema(schema map[string]any, value any) error {
+	var errs []error
+	defs, _ := schema["$defs"].(map[string]any)
+	validateSchema(schema, defs, value, "$", &errs)
+	return errors.Join(errs...)
+}
+
+func validateSchema(schema, defs map[string]any, value any, path string, errs *[]error) {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, ok := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("%s: unknown schema %s", path, ref))
+			return
+		}
+		validateSchema(def, defs, value, path, errs)
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		if !slices.ContainsFunc(types, func(t string) bool { return matchesType(t, value) }) {
+			*errs = append(*errs, fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value)))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(allowed any) bool { return reflect.DeepEqual(allowed, value) }) {
+			*errs = append(*errs, fmt.Errorf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if minimum, ok := toFloat(schema["minimum"]); ok && v < minimum {
+			*errs = append(*errs, fmt.Errorf("%s: %v is less than the minimum of %v", path, v, minimum))
+		}
+		if maximum, ok := toFloat(schema["maximum"]); ok && v > maximum {
+			*errs = append(*errs, fmt.Errorf("%s: %v is greater than the maximum of %v", path, v, maximum))
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if minLength, ok := toFloat(schema["minLength"]); ok && length < minLength {
+			*errs = append(*errs, fmt.Errorf("%s: %q is shorter than the minimum length of %v", path, v, minLength))
+		}
+		if maxLength, ok := toFloat(schema["maxLength"]); ok && length > maxLength {
+			*errs = append(*errs, fmt.Errorf("%s: %q is longer than the maximum length of %v", path, v, maxLength))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if matched, err := regexp.MatchString(pattern, v); err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: invalid pattern %q: %w", path, pattern, err))
+			} else if !matched {
+				*errs = append(*errs, fmt.Errorf("%s: %q does not match the pattern %q", path, v, pattern))
+			}
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				*errs = append(*errs, fmt.Errorf("%s: %q is not an RFC 3339 date-time", path, v))
+			}
+		}
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, fmt.Errorf("%s: missing required field %q", path, name))
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			fieldPath := path + "." + key
+			if property, ok := properties[key].(map[string]any); ok {
+				validateSchema(property, defs, v[key], fieldPath, errs)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					*errs = append(*errs, fmt.Errorf("%s: unexpected field", fieldPath))
+				}
+			case map[string]any:
+				validateSchema(additional, defs, v[key], fieldPath, errs)
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateSchema(items, defs, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	}
+}
+
+// schemaTypes returns the type keyword as a list; it may be a single
+// type or a list of them.
+func schemaTypes(value any) []string {
+	if t, ok := value.(string); ok {
+		return []string{t}
+	}
+	return schemaStrings(value)
+}
+
+// schemaStrings returns a list keyword such as required as strings,
+// whether it was built in Go ([]string) or decoded from JSON ([]any).
+func schemaStrings(value any) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				strs = append(strs, s)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// matchesType reports whether a decoded JSON value is of the JSON schema
+// type t.
+func matchesType(t string, value any) bool {
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == float64(int64(number))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// jsonTypeName names the JSON type of a decoded value for error messages.
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
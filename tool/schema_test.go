@@ -0,0 +1,198 @@
+package tool
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type schemaBase struct {
+	ID string `json:"id"`
+}
+
+type schemaPerson struct {
+	schemaBase
+	Name     string             `json:"name"`
+	Age      int                `json:"age"`
+	Address  *schemaAddress     `json:"address"`
+	Tags     []string           `json:"tags,omitempty"`
+	Scores   map[string]float64 `json:"scores,omitempty"`
+	Friends  []*schemaPerson    `json:"friends,omitempty"`
+	Secret   string             `json:"-"`
+	internal string
+}
+
+func TestTypeToJSONSchema(t *testing.T) {
+	schema := TypeToJSONSchema(reflect.TypeOf(schemaPerson{}))
+
+	if schema["type"] != "object" || schema["additionalProperties"] != false {
+		t.Fatalf("unexpected schema: %v", schema)
+	}
+	properties := schema["properties"].(map[string]any)
+	for _, name := range []string{"id", "name", "age", "address", "tags", "scores", "friends"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("expected property %q", name)
+		}
+	}
+	if _, ok := properties["Secret"]; ok {
+		t.Errorf("expected json:\"-\" fields to be skipped")
+	}
+	if _, ok := properties["internal"]; ok {
+		t.Errorf("expected unexported fields to be skipped")
+	}
+	if required := schema["required"].([]string); !reflect.DeepEqual(required, []string{"id", "name", "age", "address"}) {
+		t.Errorf("unexpected required fields: %v", required)
+	}
+
+	if properties["age"].(map[string]any)["type"] != "integer" {
+		t.Errorf("unexpected age schema: %v", properties["age"])
+	}
+	address := properties["address"].(map[string]any)
+	if address["type"] != "object" || address["required"].([]string)[0] != "city" {
+		t.Errorf("expected pointers to nested structs to be described, got %v", address)
+	}
+	if items := properties["tags"].(map[string]any)["items"].(map[string]any); items["type"] != "string" {
+		t.Errorf("unexpected tags items: %v", items)
+	}
+	if values := properties["scores"].(map[string]any)["additionalProperties"].(map[string]any); values["type"] != "number" {
+		t.Errorf("unexpected scores values: %v", values)
+	}
+	friend := properties["friends"].(map[string]any)["items"].(map[string]any)
+	if friend["$ref"] != "#/$defs/schemaPerson" {
+		t.Errorf("expected recursion to refer to a definition, got %v", friend)
+	}
+	def := schema["$defs"].(map[string]any)["schemaPerson"].(map[string]any)
+	if def["type"] != "object" || def["properties"].(map[string]any)["friends"] == nil {
+		t.Errorf("expected the recursive type to be defined in full, got %v", def)
+	}
+}
+
+type schemaQuery struct {
+	Text    string      `json:"text" description:"What to search for" pattern:"^\\S"`
+	Sort    string      `json:"sort,omitempty" enum:"relevance, date"`
+	Limit   int         `json:"limit" minimum:"1" maximum:"100" required:"false"`
+	Sources []string    `json:"sources,omitempty" enum:"news,blogs" required:"true"`
+	Since   time.Time   `json:"since"`
+	Filter  *schemaNode `json:"filter,omitempty"`
+}
+
+type schemaNode struct {
+	Field string        `json:"field"`
+	And   []*schemaNode `json:"and,omitempty"`
+}
+
+func TestTypeToJSONSchema_Tags(t *testing.T) {
+	schema := TypeToJSONSchema(reflect.TypeOf(schemaQuery{}))
+	properties := schema["properties"].(map[string]any)
+
+	text := properties["text"].(map[string]any)
+	if text["description"] != "What to search for" || text["pattern"] != `^\S` {
+		t.Errorf("unexpected text schema: %v", text)
+	}
+	if sort := properties["sort"].(map[string]any); !reflect.DeepEqual(sort["enum"], []any{"relevance", "date"}) {
+		t.Errorf("unexpected sort schema: %v", sort)
+	}
+	if limit := properties["limit"].(map[string]any); limit["minimum"] != 1.0 || limit["maximum"] != 100.0 {
+		t.Errorf("unexpected limit schema: %v", limit)
+	}
+	sources := properties["sources"].(map[string]any)
+	if items := sources["items"].(map[string]any); !reflect.DeepEqual(items["enum"], []any{"news", "blogs"}) {
+		t.Errorf("expected the enum to apply to the items, got %v", sources)
+	}
+	if since := properties["since"].(map[string]any); since["type"] != "string" || since["format"] != "date-time" {
+		t.Errorf("unexpected since schema: %v", since)
+	}
+	if required := schema["required"].([]string); !reflect.DeepEqual(required, []string{"text", "sources", "since"}) {
+		t.Errorf("unexpected required fields: %v", required)
+	}
+	if filter := properties["filter"].(map[string]any); filter["$ref"] != "#/$defs/schemaNode" {
+		t.Errorf("unexpected filter schema: %v", filter)
+	}
+	if _, ok := schema["$defs"].(map[string]any)["schemaNode"]; !ok {
+		t.Errorf("expected schemaNode to be defined, got %v", schema["$defs"])
+	}
+}
+
+func TestTypeToJSONSchema_RecursiveRoot(t *testing.T) {
+	schema := TypeToJSONSchema(reflect.TypeOf(schemaNode{}))
+	if schema["type"] != "object" || schema["properties"] == nil {
+		t.Fatalf("expected the root to describe the type directly, got %v", schema)
+	}
+	value := map[string]any{"field": "a", "and": []any{map[string]any{"field": "b", "and": []any{map[string]any{"field": 3.0}}}}}
+	err := ValidateJSONSchema(schema, value)
+	if err == nil || err.Error() != "$.and[0].and[0].field: expected string, got number" {
+		t.Errorf("expected nested definitions to be validated, got %v", err)
+	}
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := TypeToJSONSchema(reflect.TypeOf(schemaPerson{}))
+	decode := func(text string) any {
+		var value any
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			t.Fatalf("bad test JSON: %v", err)
+		}
+		return value
+	}
+
+	valid := decode(`{"id": "1", "name": "Ada", "age": 36, "address": {"city": "London"}, "tags": ["math"]}`)
+	if err := ValidateJSONSchema(schema, valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := decode(`{"id": "1", "name": 7, "age": 36.5, "address": {"zip": "N1"}, "tags": ["ok", 2], "extra": true}`)
+	err := ValidateJSONSchema(schema, invalid)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		"$.name: expected string, got number",
+		"$.age: expected integer, got number",
+		`$.address: missing required field "city"`,
+		"$.tags[1]: expected string, got number",
+		"$.extra: unexpected field",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %v", expected, err)
+		}
+	}
+
+	enum := map[string]any{"type": "string", "enum": []any{"pass", "fail"}}
+	if err := ValidateJSONSchema(enum, "maybe"); err == nil {
+		t.Errorf("expected enum to be enforced")
+	}
+}
+
+func TestValidateJSONSchema_Constraints(t *testing.T) {
+	schema := TypeToJSONSchema(reflect.TypeOf(schemaQuery{}))
+	var value any
+	if err := json.Unmarshal([]byte(`{"text": " x", "sort": "oldest", "limit": 0, "sources": ["news", "tv"], "since": "yesterday"}`), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ValidateJSONSchema(schema, value)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := []string{
+		"$.limit: 0 is less than the minimum of 1",
+		`$.since: "yesterday" is not an RFC 3339 date-time`,
+		"$.sort: oldest is not one of [relevance date]",
+		"$.sources[1]: tv is not one of [news blogs]",
+		`$.text: " x" does not match the pattern "^\\S"`,
+	}
+	if got := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors %q, want %q", got, want)
+	}
+
+	if err := ValidateJSONSchema(map[string]any{"maximum": 10}, 11.0); err == nil {
+		t.Errorf("expected maximum to be enforced")
+	}
+}
@@ -15,6 +15,8 @@ type Stats struct {
 	timers   map[string]int64 // stored as nanoseconds
 	counters map[string]int64
 	values   map[string]any
+	// usage of model calls made by this node, by model
+	usage map[string]Usage
 
 	mu sync.RWMutex
 }
@@ -165,6 +167,30 @@ func (s *Stats) Get(name string) any {
 	return s.values[name]
 }
 
+// Usage functions
+
+// RecordUsage adds usage of the named model to this node's stats.
+func (s *Stats) RecordUsage(model string, usage Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.usage == nil {
+		s.usage = make(map[string]Usage)
+	}
+	s.usage[model] = s.usage[model].Add(usage)
+}
+
+// Usage returns a copy of the usage recorded on this node, by model.
+// It does not include the node's children; see Context.UsageReport.
+func (s *Stats) Usage() map[string]Usage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	usage := make(map[string]Usage, len(s.usage))
+	for model, u := range s.usage {
+		usage[model] = u
+	}
+	return usage
+}
+
 // MarshalJSON implements json.Marshaler
 func (s *Stats) MarshalJSON() ([]byte, error) {
 	s.mu.RLock()
@@ -176,12 +202,14 @@ func (s *Stats) MarshalJSON() ([]byte, error) {
 		Timers    map[string]int64 `json:"timers"`
 		Counters  map[string]int64 `json:"counters"`
 		Values    map[string]any   `json:"values"`
+		Usage     map[string]Usage `json:"usage,omitempty"`
 	}{
 		StartTime: s.startTime,
 		EndTime:   s.endTime,
 		Timers:    s.timers,
 		Counters:  s.counters,
 		Values:    s.values,
+		Usage:     s.usage,
 	})
 }
 
@@ -196,6 +224,7 @@ func (s *Stats) UnmarshalJSON(data []byte) error {
 		Timers    map[string]int64 `json:"timers"` // Duration stored as nanoseconds
 		Counters  map[string]int64 `json:"counters"`
 		Values    map[string]any   `json:"values"`
+		Usage     map[string]Usage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(data, &aux); err != nil {
@@ -207,6 +236,7 @@ func (s *Stats) UnmarshalJSON(data []byte) error {
 	s.timers = aux.Timers
 	s.counters = aux.Counters
 	s.values = aux.Values
+	s.usage = aux.Usage
 
 	return nil
 }
@@ -0,0 +1,288 @@
+package tool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StructParameters derives a parameter from each field of a struct type,
+// or pointer to one, in declaration order. Each field encoding/json would
+// encode is a parameter named as it would name it, promoting the fields
+// of embedded structs, and of the field's type, with these tags:
+//
+//	description  what the field is for
+//	required     "true" or "false"; fields are required unless omitempty
+//	default      the value of the field when it is omitted
+//	enum         comma separated values the field may take
+//	minimum      the least number the field may be
+//	maximum      the greatest number the field may be
+//	minLength    the fewest characters the field may have
+//	maxLength    the most characters the field may have
+//	pattern      a regular expression the field must match
+//
+// Values are written as JSON, save for those of strings and of types that
+// unmarshal themselves from text, such as time.Time, which are written as
+// they are. The enum and bound tags of slice and array fields constrain
+// their items. Tags that cannot be parsed as their field's type fail the
+// calls that give the field a value, as a bad ParameterOption does.
+func StructParameters(t reflect.Type) []Parameter {
+	t = baseType(t)
+	var parameters []Parameter
+	seen := map[string]bool{}
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, skip := jsonField(field)
+			if skip {
+				continue
+			}
+			fieldType := field.Type
+			for fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+				addFields(fieldType)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			p := fieldParameter(field)
+			if !seen[p.Name()] {
+				seen[p.Name()] = true
+				parameters = append(parameters, p)
+			}
+		}
+	}
+	addFields(t)
+	return parameters
+}
+
+// FieldParameter derives a parameter from the named field of a struct
+// type as StructParameters does, with further options applied after its
+// tags; e.g. gentool describes fields by their doc comments with
+// WithDescription. It panics if the struct has no such field.
+func FieldParameter(t reflect.Type, field string, options ...ParameterOption) Parameter {
+	f, ok := baseType(t).FieldByName(field)
+	if !ok {
+		panic(fmt.Sprintf("tool: %s has no field %s", t, field))
+	}
+	p := fieldParameter(f)
+	for _, option := range options {
+		option(&p)
+	}
+	return p
+}
+
+// fieldParameter derives a parameter from a struct field's type and tags.
+func fieldParameter(field reflect.StructField) Parameter {
+	tags := parseFieldTags(field)
+	p := Parameter{
+		name:        tags.name,
+		description: tags.description,
+		value_type:  field.Type,
+		required:    tags.required,
+	}
+	if tags.defaultValue != nil {
+		// parseFieldTags has checked that the default converts
+		p.defaultValue, _ = Coerce(tags.defaultValue, field.Type)
+	}
+	item := itemType(field.Type)
+	c := tags.constraints(item)
+	if base := baseType(field.Type); item != base {
+		p.constraints.items = &c
+	} else {
+		p.constraints = c
+	}
+	p.constraints.err = tags.err
+	return p
+}
+
+// fieldTags are the settings a struct field's tags give it; see
+// StructParameters. TypeToJSONSchema, StructParameters and the tools
+// gentool generates all read them here, so that a struct is described
+// and validated the same whichever way it is used.
+type fieldTags struct {
+	name        string
+	required    bool
+	description string
+	// defaultValue and enum are as decoded from JSON, e.g. float64 for
+	// numbers; defaultValue is nil if the field has no default
+	defaultValue any
+	enum         []any
+	minimum      *float64
+	maximum      *float64
+	minLength    *int
+	maxLength    *int
+	pattern      *regexp.Regexp
+	// err holds the tags that could not be parsed; their settings are
+	// left out
+	err error
+}
+
+// parseFieldTags reads the tags of a struct field that encoding/json
+// does not skip.
+func parseFieldTags(field reflect.StructField) fieldTags {
+	name, omitempty, _ := jsonField(field)
+	if name == "" {
+		name = field.Name
+	}
+	tags := fieldTags{
+		name:        name,
+		required:    !omitempty,
+		description: field.Tag.Get("description"),
+	}
+
+	var errs []error
+	switch required := field.Tag.Get("required"); required {
+	case "":
+	case "true", "false":
+		tags.required = required == "true"
+	default:
+		errs = append(errs, fmt.Errorf("required tag must be true or false, not %q", required))
+	}
+
+	if text, ok := field.Tag.Lookup("default"); ok {
+		value, err := parseTagValue(text, field.Type)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("default tag: %w", err))
+		}
+		tags.defaultValue = value
+	}
+
+	item := itemType(field.Type)
+	if enum := field.Tag.Get("enum"); enum != "" {
+		for _, text := range strings.Split(enum, ",") {
+			value, err := parseTagValue(strings.TrimSpace(text), item)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("enum tag: %w", err))
+				continue
+			}
+			tags.enum = append(tags.enum, value)
+		}
+	}
+	for _, bound := range []struct {
+		tag   string
+		value **float64
+	}{
+		{"minimum", &tags.minimum},
+		{"maximum", &tags.maximum},
+	} {
+		if text, ok := field.Tag.Lookup(bound.tag); ok {
+			number, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s tag: %q is not a number", bound.tag, text))
+				continue
+			}
+			*bound.value = &number
+		}
+	}
+	for _, length := range []struct {
+		tag   string
+		value **int
+	}{
+		{"minLength", &tags.minLength},
+		{"maxLength", &tags.maxLength},
+	} {
+		if text, ok := field.Tag.Lookup(length.tag); ok {
+			n, err := strconv.Atoi(text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s tag: %q is not an integer", length.tag, text))
+				continue
+			}
+			*length.value = &n
+		}
+	}
+	if pattern, ok := field.Tag.Lookup("pattern"); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pattern tag: %w", err))
+		} else {
+			tags.pattern = re
+		}
+	}
+
+	tags.err = errors.Join(errs...)
+	return tags
+}
+
+// describe adds the tags' settings to the schema of their field; enum
+// and bounds go to the items of arrays.
+func (tags fieldTags) describe(schema map[string]any) {
+	if tags.description != "" {
+		schema["description"] = tags.description
+	}
+	if tags.defaultValue != nil {
+		schema["default"] = tags.defaultValue
+	}
+	constrained := schema
+	if items, ok := schema["items"].(map[string]any); ok {
+		constrained = items
+	}
+	tags.constraints(nil).describe(constrained)
+}
+
+// constraints returns the constraints the tags place on a field's values,
+// or on its items if it is a slice or array, with the enum converted to
+// t, the type of those values, if given.
+func (tags fieldTags) constraints(t reflect.Type) constraints {
+	c := constraints{
+		enum:      tags.enum,
+		minimum:   tags.minimum,
+		maximum:   tags.maximum,
+		minLength: tags.minLength,
+		maxLength: tags.maxLength,
+		pattern:   tags.pattern,
+	}
+	if t != nil && len(tags.enum) > 0 {
+		c.enum = nil
+		for _, value := range tags.enum {
+			// parseTagValue has checked that the values convert
+			v, _ := Coerce(value, t)
+			c.enum = append(c.enum, v)
+		}
+	}
+	return c
+}
+
+// parseTagValue parses a tag's value as a value of type t, as decoded
+// from JSON: strings, and types that unmarshal themselves from text such
+// as time.Time, are written as they are and everything else as JSON.
+func parseTagValue(text string, t reflect.Type) (any, error) {
+	base := baseType(t)
+	var value any = text
+	if base.Kind() != reflect.String && !reflect.PointerTo(base).Implements(textUnmarshalerType) {
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return nil, fmt.Errorf("%q is not a valid %s", text, t)
+		}
+	}
+	if _, err := Coerce(value, t); err != nil {
+		return nil, fmt.Errorf("%q is not a valid %s", text, t)
+	}
+	return value, nil
+}
+
+// baseType returns the type a pointer type ultimately points to, or t.
+func baseType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// itemType returns the type whose values a field's enum and bounds
+// constrain: that of its items if it is a slice or array, save for
+// []byte which is encoded as a string.
+func itemType(t reflect.Type) reflect.Type {
+	t = baseType(t)
+	if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 {
+		return itemType(t.Elem())
+	}
+	return t
+}
@@ -0,0 +1,133 @@
+package tool
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type tagsUnit string
+
+type tagsRequest struct {
+	City  string     `json:"city" minLength:"2" pattern:"^[A-Z]"`
+	Unit  tagsUnit   `json:"unit,omitempty" enum:"celsius,fahrenheit" default:"celsius"`
+	Scale tagsUnit   `json:"scale,omitempty" enum:"celsius,fahrenheit"`
+	Days  int        `json:"days,omitempty" minimum:"1" maximum:"14"`
+	Tags  []tagsUnit `json:"tags,omitempty" enum:"celsius,fahrenheit" maxLength:"10"`
+	Limit *int       `json:"limit,omitempty" minimum:"1"`
+}
+
+func TestStructParameters_OptionalConstrainedFields(t *testing.T) {
+	tool := NewTool[int]("forecast", "", StructParameters(Type[tagsRequest]()), func(ctx *Context, args Arguments) (int, error) {
+		return len(args), nil
+	})
+
+	// Omitted optional fields are not checked against their constraints
+	result := tool.Execute(nil, Arguments{"city": "Boston"})
+	if result.Errored() {
+		t.Fatalf("Execute() errored unexpectedly: %v", result.GetError())
+	}
+	// city and the unit default
+	if result.GetResult() != 2 {
+		t.Errorf("got %v arguments, want 2", result.GetResult())
+	}
+}
+
+func TestFieldParameter_NamedTypeDefault(t *testing.T) {
+	unit := FieldParameter(Type[tagsRequest](), "Unit")
+	if unit.Required() || unit.Default() != tagsUnit("celsius") {
+		t.Errorf("unit: required = %v, default = %#v", unit.Required(), unit.Default())
+	}
+	if got, err := unit.Value(nil); err != nil || got != tagsUnit("celsius") {
+		t.Errorf("Value(nil) = %#v, %v, want celsius", got, err)
+	}
+
+	city := FieldParameter(Type[tagsRequest](), "City", WithDescription("The city"))
+	if city.Description() != "The city" || !city.Required() || city.Default() != nil {
+		t.Errorf("city: description = %q, required = %v, default = %v", city.Description(), city.Required(), city.Default())
+	}
+}
+
+func TestStructParameters_Constraints(t *testing.T) {
+	params := map[string]Parameter{}
+	for _, p := range StructParameters(Type[tagsRequest]()) {
+		params[p.Name()] = p
+	}
+
+	tests := []struct {
+		param string
+		value any
+		want  string
+	}{
+		{"city", "boston", "does not match the pattern"},
+		{"city", "B", "shorter than the minimum length of 2"},
+		{"scale", "kelvin", "kelvin is not one of [celsius fahrenheit]"},
+		{"days", 20.0, "greater than the maximum of 14"},
+		{"tags", []any{"celsius", "kelvin"}, "$[1]: kelvin is not one of [celsius fahrenheit]"},
+		{"limit", 0.0, "less than the minimum of 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			param := params[tt.param]
+			_, err := param.Value(tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Value(%v) error = %v, want it to contain %q", tt.value, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructParameters_MatchesTypeToJSONSchema(t *testing.T) {
+	typeSchema := TypeToJSONSchema(Type[tagsRequest]())
+	paramSchema := ParametersToJSONSchema(StructParameters(Type[tagsRequest]()))
+
+	if !reflect.DeepEqual(paramSchema["required"], typeSchema["required"]) {
+		t.Errorf("required = %v, want %v", paramSchema["required"], typeSchema["required"])
+	}
+	typeProps := typeSchema["properties"].(map[string]any)
+	paramProps := paramSchema["properties"].(map[string]any)
+	for name, property := range typeProps {
+		for keyword, want := range property.(map[string]any) {
+			got := paramProps[name].(map[string]any)[keyword]
+			if keyword == "enum" || keyword == "default" || keyword == "items" {
+				// Parameters hold values of their types
+				got, want = jsonValue(t, got), jsonValue(t, want)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %s = %v, want %v", name, keyword, got, want)
+			}
+		}
+	}
+}
+
+func jsonValue(t *testing.T, value any) any {
+	t.Helper()
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded(t, string(data))
+}
+
+func TestStructParameters_BadTags(t *testing.T) {
+	type request struct {
+		Days int    `json:"days" default:"three" maximum:"lots"`
+		Sort string `json:"sort" required:"maybe" pattern:"("`
+	}
+	for _, p := range StructParameters(Type[request]()) {
+		err := p.Validate("x")
+		if err == nil {
+			t.Errorf("%s: expected the bad tags to be reported", p.Name())
+			continue
+		}
+		for _, want := range map[string][]string{
+			"days": {`default tag: "three" is not a valid int`, `maximum tag: "lots" is not a number`},
+			"sort": {`required tag must be true or false, not "maybe"`, "pattern tag"},
+		}[p.Name()] {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error = %v, want it to contain %q", p.Name(), err, want)
+			}
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package tool
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 
 	"github.com/hlfshell/gotonomy/utils/semver"
 )
@@ -97,13 +99,15 @@ func (t *tool) Parameters() []Parameter {
 }
 
 // validateArguments validates arguments against declared parameters.
-// It returns validated arguments and an error if validation fails.
+// It returns validated arguments and an error if validation fails,
+// reporting every argument at fault so the caller can fix them at once.
 func validateArguments(
 	args Arguments,
 	parametersOrdered []Parameter,
 	parametersByName map[string]Parameter,
 ) (Arguments, error) {
 	validated := make(Arguments, len(args))
+	var errs []error
 
 	// Validate against declared parameters using Parameter.Value (defaults + type checking)
 	for _, param := range parametersOrdered {
@@ -115,28 +119,49 @@ func validateArguments(
 		finalValue, err := param.Value(raw)
 		if err != nil {
 			// Preserve a clear error message per parameter
-			return nil, fmt.Errorf("argument %s: %w", name, err)
+			errs = append(errs, fmt.Errorf("argument %s: %w", name, err))
+			continue
 		}
 		// Only set if explicitly provided or a default applied
 		if finalValue != nil {
 			validated[name] = finalValue
 		} else if param.Required() {
 			// Defensive: should be unreachable due to Value/TypeCheck semantics
-			return nil, fmt.Errorf("missing required argument: %s", name)
+			errs = append(errs, fmt.Errorf("missing required argument: %s", name))
 		}
 	}
 
 	// Reject any extra, undeclared arguments to prevent silent typos/misuse
-	for name, value := range args {
-		_ = value
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
 		if _, known := parametersByName[name]; !known {
-			return nil, fmt.Errorf("unknown argument: %s", name)
+			errs = append(errs, fmt.Errorf("unknown argument: %s", name))
 		}
 	}
 
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 	return validated, nil
 }
 
+// ValidateArguments checks args against parameters as a tool's Execute
+// does before calling its handler: applying defaults, coercing values to
+// their parameters' types, checking their constraints and rejecting
+// unknown arguments. Tools that implement Tool themselves, such as those
+// generated by gentool, use it to validate their arguments the same way.
+func ValidateArguments(args Arguments, parameters []Parameter) (Arguments, error) {
+	byName := make(map[string]Parameter, len(parameters))
+	for _, p := range parameters {
+		byName[p.Name()] = p
+	}
+	return validateArguments(args, parameters, byName)
+}
+
 // Execute runs the internal handler w/ protections and validation, acting
 // as the exposure of the interface for tool.
 func (t *tool) Execute(ctx *Context, args Arguments) ResultInterface {
@@ -144,6 +169,13 @@ func (t *tool) Execute(ctx *Context, args Arguments) ResultInterface {
 	ctx.Stats().MarkStarted()
 	defer ctx.Stats().MarkFinished()
 
+	// Don't start work for an execution that has already been cancelled
+	if err := ctx.Err(); err != nil {
+		e := NewError(err)
+		ctx.SetOutput(e)
+		return e
+	}
+
 	validated, err := validateArguments(args, t.parametersOrdered, t.parametersByName)
 	if err != nil {
 		e := NewError(err)
@@ -0,0 +1,146 @@
+package tool
+
+import "sort"
+
+// Usage is the tokens used, and what they cost, over one or more model
+// calls.
+type Usage struct {
+	Calls        int64   `json:"calls"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+}
+
+// Add returns the sum of this and the other Usage.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		Calls:        u.Calls + other.Calls,
+		InputTokens:  u.InputTokens + other.InputTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+		Cost:         u.Cost + other.Cost,
+	}
+}
+
+// TotalTokens returns the total number of tokens used.
+func (u Usage) TotalTokens() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+// UsageReport breaks down the model usage of a context and everything
+// beneath it in the execution tree.
+type UsageReport struct {
+	ID   ContextID `json:"id"`
+	Tool string    `json:"tool"`
+
+	// Self is the usage of the calls made by this context itself.
+	Self Usage `json:"self"`
+	// Total is the usage of the whole subtree, this context included.
+	Total Usage `json:"total"`
+	// ByModel sums the subtree's usage by model.
+	ByModel map[string]Usage `json:"by_model"`
+	// ByTool sums the subtree's usage by the name of the tool whose
+	// context made the calls.
+	ByTool map[string]Usage `json:"by_tool"`
+
+	// Children reports each child context's subtree, in the order the
+	// children were created.
+	Children []*UsageReport `json:"children,omitempty"`
+}
+
+// UsageReport returns the model usage of the whole execution, rolled up
+// from its root. It returns nil if the execution has no root yet.
+func (e *Execution) UsageReport() *UsageReport {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	root, ok := e.ctxs[e.root]
+	if !ok {
+		return nil
+	}
+	return e.usageReport(root)
+}
+
+// UsageReport returns the model usage of this context and its
+// descendants. On a context not yet part of an execution it reports the
+// context's own usage alone.
+func (c *Context) UsageReport() *UsageReport {
+	if c.execution == nil {
+		return newUsageReport(c)
+	}
+	c.execution.mu.RLock()
+	defer c.execution.mu.RUnlock()
+	return c.execution.usageReport(c)
+}
+
+// usageReport builds c's report; e.mu must be held.
+func (e *Execution) usageReport(c *Context) *UsageReport {
+	report := newUsageReport(c)
+	for _, id := range c.children {
+		child, ok := e.ctxs[id]
+		if !ok {
+			continue
+		}
+		childReport := e.usageReport(child)
+		report.Total = report.Total.Add(childReport.Total)
+		addUsage(report.ByModel, childReport.ByModel)
+		addUsage(report.ByTool, childReport.ByTool)
+		report.Children = append(report.Children, childReport)
+	}
+	return report
+}
+
+// newUsageReport reports the usage of c alone.
+func newUsageReport(c *Context) *UsageReport {
+	c.mu.RLock()
+	report := &UsageReport{
+		ID:      c.id,
+		Tool:    c.toolName,
+		ByModel: c.stats.Usage(),
+		ByTool:  map[string]Usage{},
+	}
+	c.mu.RUnlock()
+
+	for _, usage := range report.ByModel {
+		report.Self = report.Self.Add(usage)
+	}
+	report.Total = report.Self
+	if report.Self.Calls > 0 {
+		report.ByTool[report.Tool] = report.Self
+	}
+	return report
+}
+
+// Models returns the names of the models used in the subtree, sorted.
+func (r *UsageReport) Models() []string {
+	if r == nil {
+		return nil
+	}
+	models := make([]string, 0, len(r.ByModel))
+	for model := range r.ByModel {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
+
+// Find returns the report for the context with the given ID within this
+// subtree, or nil if it is not present.
+func (r *UsageReport) Find(id ContextID) *UsageReport {
+	if r == nil {
+		return nil
+	}
+	if r.ID == id {
+		return r
+	}
+	for _, child := range r.Children {
+		if found := child.Find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func addUsage(into, from map[string]Usage) {
+	for key, usage := range from {
+		into[key] = into[key].Add(usage)
+	}
+}
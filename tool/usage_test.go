@@ -0,0 +1,102 @@
+package tool
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUsage_Add(t *testing.T) {
+	a := Usage{Calls: 1, InputTokens: 10, OutputTokens: 5, Cost: 0.5}
+	b := Usage{Calls: 2, InputTokens: 20, OutputTokens: 10, Cost: 1.25}
+	sum := a.Add(b)
+	if sum != (Usage{Calls: 3, InputTokens: 30, OutputTokens: 15, Cost: 1.75}) {
+		t.Errorf("unexpected sum %+v", sum)
+	}
+	if sum.TotalTokens() != 45 {
+		t.Errorf("expected 45 total tokens, got %d", sum.TotalTokens())
+	}
+}
+
+func TestStats_RecordUsage(t *testing.T) {
+	stats := &Stats{}
+	stats.RecordUsage("gpt", Usage{Calls: 1, InputTokens: 10, OutputTokens: 5, Cost: 1})
+	stats.RecordUsage("gpt", Usage{Calls: 1, InputTokens: 20, OutputTokens: 5, Cost: 2})
+	stats.RecordUsage("claude", Usage{Calls: 1, InputTokens: 1, OutputTokens: 1})
+
+	usage := stats.Usage()
+	if usage["gpt"] != (Usage{Calls: 2, InputTokens: 30, OutputTokens: 10, Cost: 3}) {
+		t.Errorf("unexpected gpt usage %+v", usage["gpt"])
+	}
+	if usage["claude"].Calls != 1 {
+		t.Errorf("unexpected claude usage %+v", usage["claude"])
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var restored Stats
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if restored.Usage()["gpt"] != usage["gpt"] {
+		t.Errorf("usage lost in round trip: %+v", restored.Usage())
+	}
+}
+
+func TestExecution_UsageReport(t *testing.T) {
+	e, root := NewExecution(&mockTool{name: "planner"}, Arguments{})
+	worker := PrepareContext(root, &mockTool{name: "worker"}, Arguments{})
+	search := PrepareContext(worker, &mockTool{name: "search"}, Arguments{})
+	other := PrepareContext(root, &mockTool{name: "worker"}, Arguments{})
+
+	root.Stats().RecordUsage("big", Usage{Calls: 1, InputTokens: 100, OutputTokens: 10, Cost: 1})
+	worker.Stats().RecordUsage("small", Usage{Calls: 2, InputTokens: 50, OutputTokens: 20, Cost: 0.1})
+	search.Stats().RecordUsage("small", Usage{Calls: 1, InputTokens: 10, OutputTokens: 2, Cost: 0.01})
+	other.Stats().RecordUsage("big", Usage{Calls: 1, InputTokens: 30, OutputTokens: 3, Cost: 0.5})
+
+	report := e.UsageReport()
+	if report == nil {
+		t.Fatal("expected a report")
+	}
+	if report.ID != root.ID() || report.Tool != "planner" {
+		t.Errorf("expected the root's report, got %s (%s)", report.ID, report.Tool)
+	}
+	if report.Total.Calls != 5 || report.Total.InputTokens != 190 || report.Total.OutputTokens != 35 {
+		t.Errorf("unexpected total %+v", report.Total)
+	}
+	if report.Self.Calls != 1 {
+		t.Errorf("expected the root's own usage, got %+v", report.Self)
+	}
+	if got := report.ByModel["big"]; got.Calls != 2 || got.Cost != 1.5 {
+		t.Errorf("unexpected usage of big: %+v", got)
+	}
+	if got := report.ByTool["worker"]; got.Calls != 3 || got.InputTokens != 80 {
+		t.Errorf("expected both workers summed, got %+v", got)
+	}
+	if got := report.Models(); len(got) != 2 || got[0] != "big" || got[1] != "small" {
+		t.Errorf("unexpected models %v", got)
+	}
+	if len(report.Children) != 2 {
+		t.Fatalf("expected 2 children, got %d", len(report.Children))
+	}
+
+	sub := report.Find(worker.ID())
+	if sub == nil {
+		t.Fatal("expected to find the worker's subtree")
+	}
+	if sub.Total.Calls != 3 || sub.ByTool["search"].Calls != 1 {
+		t.Errorf("unexpected worker subtree %+v", sub)
+	}
+	if direct := worker.UsageReport(); direct.Total != sub.Total {
+		t.Errorf("expected the context's report to match the rollup, got %+v", direct.Total)
+	}
+}
+
+func TestExecution_UsageReportEmpty(t *testing.T) {
+	_, root := NewExecution(&mockTool{name: "idle"}, Arguments{})
+	report := root.UsageReport()
+	if report.Total != (Usage{}) || len(report.ByModel) != 0 || len(report.ByTool) != 0 {
+		t.Errorf("expected no usage, got %+v", report)
+	}
+}